@@ -1,7 +1,9 @@
 package main
 
 import (
+	"Nietzsche/internal/config"
 	"Nietzsche/internal/server"
+	"flag"
 	"log"
 	"net/http"
 	_ "net/http/pprof" // for profiling
@@ -12,20 +14,40 @@ import (
 )
 
 func main() {
+	if err := config.Load(os.Args[0], os.Args[1:]); err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
 	var signals = make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(1)
 
-	go server.RunIoMultiplexingServer(&wg) // single-threaded
-	//s := server.NewServer()
-	//go s.StartSingleListener(&wg)
-	//go s.StartMultiListeners(&wg)
-	go server.WaitForSignal(&wg, signals)
+	log.Printf("starting the server in %s mode", config.IOMode)
+	switch config.IOMode {
+	case "single":
+		go server.RunIoMultiplexingServer(&wg) // single-threaded
+	case "handlers":
+		s := server.NewServer()
+		go s.StartSingleListener(&wg)
+	case "reuseport":
+		s := server.NewServer()
+		go s.StartMultiListeners(&wg)
+	}
+	go server.WaitForSignal(signals)
 
 	// Expose the /debug/pprof endpoints on a separate goroutine
-	go func() {
-		log.Println(http.ListenAndServe("localhost:6060", nil))
-	}()
+	if config.PprofAddress != "" {
+		go func() {
+			log.Println(http.ListenAndServe(config.PprofAddress, nil))
+		}()
+	}
+	// the server returns once its clients are closed
 	wg.Wait()
+	if err := server.FinishShutdown(); err != nil {
+		log.Fatal("Failed to take the final snapshot: ", err)
+	}
+	log.Println("Bye")
 }
@@ -1,14 +1,70 @@
 package config
 
+import (
+	"net"
+	"strconv"
+)
+
 var Protocol = "tcp"
-var Port = ":3000"
-var MaxConnection = 20000
+var Bind = ""
+var Port = 3000
+
+// MaxEventsPerLoop is the maximum number of events an I/O multiplexer returns per wait
+var MaxEventsPerLoop = 20000
+
 var MaxKeyNumber int = 1000000
 var EvictionRatio = 0.1
 
+// ProtoMaxBulkLen is the maximum size of a bulk string sent by a client, and of a string value
+var ProtoMaxBulkLen = 512 * 1024 * 1024
+
+// ClientQueryBufferLimit is the maximum size of the unprocessed input of a client, the connection
+// is closed once it is exceeded. It is read by every I/O handler, so it cannot be set at runtime.
+var ClientQueryBufferLimit = 1024 * 1024 * 1024
+
 var EvictionPolicy string = "allkeys-lru"
 
+// A hash is stored as a listpack while it has at most HashMaxListpackEntries fields and
+// all its fields and values are at most HashMaxListpackValue bytes long
+var HashMaxListpackEntries = 128
+var HashMaxListpackValue = 64
+
+// ListMaxListpackSize is the size of the listpacks of a list: a positive value is a number of
+// elements, a negative one a size in bytes, -1 for 4 KB, -2 for 8 KB... up to -5 for 64 KB
+var ListMaxListpackSize = -2
+
+// A stream stores its entries in listpacks of at most StreamNodeMaxEntries entries and
+// StreamNodeMaxBytes bytes, 0 meaning no limit
+var StreamNodeMaxBytes = 4096
+var StreamNodeMaxEntries = 100
+
 var EpoolMaxSize = 16
 var EpoolLruSampleSize = 5
 
 var ListenerNumber int = 2
+
+// IOMode is the architecture of the server:
+//   - single: a single thread runs the event loop and the commands
+//   - handlers: a listener hands the connections to I/O handlers, which send the commands to the workers
+//   - reuseport: like handlers, with several SO_REUSEPORT listeners
+var IOMode = "single"
+
+// WorkerNumber and IOHandlerNumber are the number of workers and I/O handlers of the multi-threaded
+// modes, 0 means half the number of CPUs
+var WorkerNumber = 0
+var IOHandlerNumber = 0
+
+// EdgeTriggered makes the I/O multiplexers report readiness changes only (EPOLLET / EV_CLEAR)
+var EdgeTriggered = false
+
+// ShutdownTimeout is the number of seconds a graceful shutdown waits for the pending replies
+// to be written before the clients are closed
+var ShutdownTimeout = 10
+
+// PprofAddress is the address of the /debug/pprof endpoints, profiling is disabled if empty
+var PprofAddress = "localhost:6060"
+
+// Address returns the address the server listens on
+func Address() string {
+	return net.JoinHostPort(Bind, strconv.Itoa(Port))
+}
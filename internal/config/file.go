@@ -0,0 +1,179 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// File is the path of the configuration file the server was started with, if any.
+// CONFIG REWRITE saves the current configuration to it.
+var File = ""
+
+// Load configures the server from the command-line arguments: the configuration file given by
+// -config (or as the first argument, like redis-server), then the parameter flags,
+// e.g. -port 3001 -maxkeys 5000, which take precedence over the file.
+func Load(program string, args []string) error {
+	fs := flag.NewFlagSet(program, flag.ContinueOnError)
+	configFile := fs.String("config", "", "path of the configuration file")
+	var overrides [][2]string
+	for _, name := range Names() {
+		p := params[name]
+		fs.Func(name, fmt.Sprintf("%s (default %q)", p.usage, p.defaultValue), func(value string) error {
+			overrides = append(overrides, [2]string{p.name, value})
+			return nil
+		})
+	}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		*configFile = args[0]
+		args = args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if *configFile != "" {
+		if err := LoadFile(*configFile); err != nil {
+			return err
+		}
+	}
+	for _, override := range overrides {
+		if err := params[override[0]].set(override[1]); err != nil {
+			return fmt.Errorf("invalid value %q for flag -%s: %v", override[1], override[0], err)
+		}
+	}
+	return nil
+}
+
+// LoadFile reads a redis.conf style file: one "name value" directive per line, blank lines
+// and lines starting with '#' are ignored, values with spaces are double-quoted.
+func LoadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(content), "\n") {
+		tokens, err := splitLine(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		p := params[strings.ToLower(tokens[0])]
+		if p == nil || len(tokens) != 2 {
+			return fmt.Errorf("%s:%d: bad directive or wrong number of arguments: %s", path, i+1, line)
+		}
+		if err := p.set(tokens[1]); err != nil {
+			return fmt.Errorf("%s:%d: invalid value for '%s': %v", path, i+1, p.name, err)
+		}
+	}
+	File = path
+	return nil
+}
+
+// Rewrite saves the current configuration to the file the server was started with. The comments
+// and the order of the directives are kept, a parameter missing from the file is appended
+// if its value is not the default one.
+func Rewrite() error {
+	if File == "" {
+		return errors.New("the server is running without a config file")
+	}
+	content, err := os.ReadFile(File)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	written := make(map[string]bool)
+	var existing []string
+	if len(content) > 0 {
+		existing = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	}
+	for _, line := range existing {
+		tokens, _ := splitLine(line)
+		if len(tokens) == 0 || params[strings.ToLower(tokens[0])] == nil {
+			lines = append(lines, line)
+			continue
+		}
+		name := strings.ToLower(tokens[0])
+		if !written[name] {
+			// a directive appearing several times is written once, where it first appeared
+			lines = append(lines, directive(params[name]))
+			written[name] = true
+		}
+	}
+	for _, name := range Names() {
+		if p := params[name]; !written[name] && p.get() != p.defaultValue {
+			lines = append(lines, directive(p))
+		}
+	}
+
+	// write to a temporary file first so that a crash never leaves a truncated config file
+	tmp, err := os.CreateTemp(filepath.Dir(File), filepath.Base(File)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), File)
+}
+
+func directive(p *param) string {
+	value := p.get()
+	if value == "" || strings.ContainsAny(value, " \t\"#") {
+		value = strconv.Quote(value)
+	}
+	return p.name + " " + value
+}
+
+// splitLine splits a config file line into its tokens, a token can be double-quoted
+func splitLine(line string) ([]string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+	var tokens []string
+	for line != "" {
+		if line[0] != '"' {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			tokens = append(tokens, line[:end])
+			line = strings.TrimLeft(line[end:], " \t")
+			continue
+		}
+		// find the closing quote, skipping the escaped characters
+		end := 1
+		for end < len(line) && line[end] != '"' {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(line) {
+			return nil, errors.New("unbalanced quotes")
+		}
+		token, err := strconv.Unquote(line[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+		line = strings.TrimLeft(line[end+1:], " \t")
+	}
+	return tokens, nil
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"Nietzsche/internal/glob"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// param is a configuration parameter that can be set in the config file, with a command-line
+// flag or, if it is mutable, at runtime with CONFIG SET
+type param struct {
+	name         string
+	usage        string
+	mutable      bool
+	get          func() string
+	set          func(value string) error
+	defaultValue string
+}
+
+var params = map[string]*param{}
+
+func register(p *param) {
+	p.defaultValue = p.get()
+	params[p.name] = p
+}
+
+func intParam(name string, v *int, min, max int, mutable bool, usage string) {
+	register(&param{
+		name:    name,
+		usage:   usage,
+		mutable: mutable,
+		get:     func() string { return strconv.Itoa(*v) },
+		set: func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.New("argument couldn't be parsed into an integer")
+			}
+			if n < min || n > max {
+				return fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			*v = n
+			return nil
+		},
+	})
+}
+
+func floatParam(name string, v *float64, min, max float64, mutable bool, usage string) {
+	register(&param{
+		name:    name,
+		usage:   usage,
+		mutable: mutable,
+		get:     func() string { return strconv.FormatFloat(*v, 'f', -1, 64) },
+		set: func(value string) error {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return errors.New("argument couldn't be parsed into a float")
+			}
+			if f <= min || f > max {
+				return fmt.Errorf("argument must be greater than %g and at most %g", min, max)
+			}
+			*v = f
+			return nil
+		},
+	})
+}
+
+func boolParam(name string, v *bool, mutable bool, usage string) {
+	register(&param{
+		name:    name,
+		usage:   usage,
+		mutable: mutable,
+		get: func() string {
+			if *v {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(value string) error {
+			switch strings.ToLower(value) {
+			case "yes":
+				*v = true
+			case "no":
+				*v = false
+			default:
+				return errors.New("argument must be 'yes' or 'no'")
+			}
+			return nil
+		},
+	})
+}
+
+// stringParam registers a string parameter, restricted to the given values if any
+func stringParam(name string, v *string, values []string, mutable bool, usage string) {
+	register(&param{
+		name:    name,
+		usage:   usage,
+		mutable: mutable,
+		get:     func() string { return *v },
+		set: func(value string) error {
+			if len(values) == 0 {
+				*v = value
+				return nil
+			}
+			for _, allowed := range values {
+				if strings.EqualFold(value, allowed) {
+					*v = allowed
+					return nil
+				}
+			}
+			return fmt.Errorf("argument(s) must be one of the following: %s", strings.Join(values, ", "))
+		},
+	})
+}
+
+func init() {
+	stringParam("bind", &Bind, nil, false, "interface to listen on, all interfaces if empty")
+	intParam("port", &Port, 1, 65535, false, "TCP port to listen on")
+	intParam("io-max-events", &MaxEventsPerLoop, 1, 1<<20, false, "maximum number of events handled per I/O loop iteration")
+	stringParam("io-mode", &IOMode, []string{"single", "handlers", "reuseport"}, false, "architecture of the server")
+	intParam("workers", &WorkerNumber, 0, 1024, false, "number of workers, 0 for half the number of CPUs")
+	intParam("io-handlers", &IOHandlerNumber, 0, 1024, false, "number of I/O handlers, 0 for half the number of CPUs")
+	intParam("listeners", &ListenerNumber, 1, 1024, false, "number of SO_REUSEPORT listeners")
+	intParam("shutdown-timeout", &ShutdownTimeout, 0, 3600, false, "seconds a shutdown waits for the pending replies")
+	boolParam("edge-triggered", &EdgeTriggered, false, "use edge-triggered I/O multiplexers")
+	stringParam("pprof-address", &PprofAddress, nil, false, "address of the /debug/pprof endpoints, disabled if empty")
+	intParam("proto-max-bulk-len", &ProtoMaxBulkLen, 1<<20, math.MaxInt, false, "maximum size of a bulk string and of a string value")
+	intParam("client-query-buffer-limit", &ClientQueryBufferLimit, 1<<20, math.MaxInt, false, "maximum size of the unprocessed input of a client")
+	intParam("maxkeys", &MaxKeyNumber, 1, 1<<31-1, true, "number of keys of a keyspace triggering an eviction")
+	floatParam("eviction-ratio", &EvictionRatio, 0, 1, true, "ratio of maxkeys evicted at once")
+	stringParam("eviction-policy", &EvictionPolicy, []string{"allkeys-lru", "allkeys-random"}, true, "eviction policy")
+	intParam("eviction-pool-size", &EpoolMaxSize, 1, 1024, true, "size of the LRU eviction pool")
+	intParam("eviction-sample-size", &EpoolLruSampleSize, 1, 1024, true, "number of keys sampled to populate the eviction pool")
+	intParam("hash-max-listpack-entries", &HashMaxListpackEntries, 0, 1<<31-1, true, "maximum number of fields of a listpack encoded hash")
+	intParam("hash-max-listpack-value", &HashMaxListpackValue, 0, 1<<31-1, true, "maximum size of a field or value of a listpack encoded hash")
+	intParam("list-max-listpack-size", &ListMaxListpackSize, -5, 1<<15, true, "maximum number of elements, or size class if negative, of a listpack of a list")
+	intParam("stream-node-max-bytes", &StreamNodeMaxBytes, 0, 1<<31-1, true, "maximum size of a listpack of a stream, 0 for no limit")
+	intParam("stream-node-max-entries", &StreamNodeMaxEntries, 0, 1<<31-1, true, "maximum number of entries of a listpack of a stream, 0 for no limit")
+}
+
+// Names returns the sorted names of the parameters
+func Names() []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the name and value of the parameters matching any of the glob-style patterns
+func Get(patterns ...string) [][2]string {
+	var res [][2]string
+	for _, name := range Names() {
+		for _, pattern := range patterns {
+			if glob.MatchNoCase(pattern, name) {
+				res = append(res, [2]string{name, params[name].get()})
+				break
+			}
+		}
+	}
+	return res
+}
+
+// Set changes the values of the given parameters at runtime. Either all values are applied or,
+// if one of them is invalid, none of them.
+func Set(pairs [][2]string) error {
+	seen := make(map[string]bool)
+	for _, pair := range pairs {
+		name := strings.ToLower(pair[0])
+		p := params[name]
+		if p == nil {
+			return fmt.Errorf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", pair[0])
+		}
+		if !p.mutable {
+			return fmt.Errorf("ERR CONFIG SET failed (possibly related to argument '%s') - can't set immutable config", pair[0])
+		}
+		if seen[name] {
+			return fmt.Errorf("ERR CONFIG SET failed (possibly related to argument '%s') - duplicate parameter", pair[0])
+		}
+		seen[name] = true
+	}
+
+	previous := make([]string, 0, len(pairs))
+	for i, pair := range pairs {
+		p := params[strings.ToLower(pair[0])]
+		previous = append(previous, p.get())
+		if err := p.set(pair[1]); err != nil {
+			// restore the parameters already applied
+			for j := i - 1; j >= 0; j-- {
+				params[strings.ToLower(pairs[j][0])].set(previous[j])
+			}
+			return fmt.Errorf("ERR CONFIG SET failed (possibly related to argument '%s') - %v", pair[0], err)
+		}
+	}
+	return nil
+}
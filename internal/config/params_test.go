@@ -0,0 +1,121 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// saveParams restores the parameters changed by a test
+func saveParams(t *testing.T) {
+	values := make(map[string]string)
+	for name, p := range params {
+		values[name] = p.get()
+	}
+	file := File
+	t.Cleanup(func() {
+		for name, value := range values {
+			params[name].set(value)
+		}
+		File = file
+	})
+}
+
+func TestGet(t *testing.T) {
+	assert.EqualValues(t, [][2]string{{"port", "3000"}}, Get("port"))
+	assert.EqualValues(t, [][2]string{{"maxkeys", "1000000"}, {"port", "3000"}}, Get("PORT", "maxkeys"))
+	assert.Len(t, Get("eviction-*"), 4)
+	assert.Len(t, Get("*"), len(params))
+	assert.Len(t, Get("nope"), 0)
+}
+
+func TestSet(t *testing.T) {
+	saveParams(t)
+	assert.Nil(t, Set([][2]string{{"maxkeys", "100"}, {"eviction-policy", "ALLKEYS-RANDOM"}}))
+	assert.EqualValues(t, 100, MaxKeyNumber)
+	assert.EqualValues(t, "allkeys-random", EvictionPolicy)
+
+	assert.EqualError(t, Set([][2]string{{"port", "3001"}}),
+		"ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config")
+	assert.EqualError(t, Set([][2]string{{"client-query-buffer-limit", "2097152"}}),
+		"ERR CONFIG SET failed (possibly related to argument 'client-query-buffer-limit') - can't set immutable config")
+	assert.EqualError(t, Set([][2]string{{"nope", "1"}}),
+		"ERR Unknown option or number of arguments for CONFIG SET - 'nope'")
+	assert.EqualError(t, Set([][2]string{{"eviction-ratio", "2"}}),
+		"ERR CONFIG SET failed (possibly related to argument 'eviction-ratio') - argument must be greater than 0 and at most 1")
+}
+
+func TestSet_AllOrNothing(t *testing.T) {
+	saveParams(t)
+	err := Set([][2]string{{"maxkeys", "100"}, {"eviction-pool-size", "64"}, {"eviction-sample-size", "x"}})
+	assert.EqualError(t, err,
+		"ERR CONFIG SET failed (possibly related to argument 'eviction-sample-size') - argument couldn't be parsed into an integer")
+	assert.EqualValues(t, 1000000, MaxKeyNumber)
+	assert.EqualValues(t, 16, EpoolMaxSize)
+
+	assert.NotNil(t, Set([][2]string{{"maxkeys", "100"}, {"MAXKEYS", "200"}}))
+	assert.EqualValues(t, 1000000, MaxKeyNumber)
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "nietzsche.conf")
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	saveParams(t)
+	path := writeFile(t, "# comment\n\nport 4000\nmaxkeys 10\nbind \"\"\nedge-triggered yes\n")
+	assert.Nil(t, Load("nzc", []string{"-config", path, "-maxkeys", "20"}))
+	assert.EqualValues(t, 4000, Port)
+	// flags take precedence over the file
+	assert.EqualValues(t, 20, MaxKeyNumber)
+	assert.True(t, EdgeTriggered)
+	assert.EqualValues(t, ":4000", Address())
+	assert.EqualValues(t, path, File)
+
+	assert.Nil(t, Load("nzc", []string{path}))
+	assert.EqualValues(t, 10, MaxKeyNumber)
+	assert.Nil(t, Load("nzc", []string{path, "-maxkeys", "30"}))
+	assert.EqualValues(t, 30, MaxKeyNumber)
+
+	assert.NotNil(t, Load("nzc", []string{"-port", "0"}))
+	assert.NotNil(t, Load("nzc", []string{"-config", path, "extra"}))
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	saveParams(t)
+	path := writeFile(t, "port 1\nnope 2\n")
+	assert.EqualError(t, LoadFile(path), path+":2: bad directive or wrong number of arguments: nope 2")
+	for _, content := range []string{"nope 1", "port", "port 1 2", "port abc", "bind \"unbalanced"} {
+		assert.NotNil(t, LoadFile(writeFile(t, content)), content)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	saveParams(t)
+	assert.NotNil(t, Rewrite())
+
+	path := writeFile(t, "# my config\nmaxkeys 10\nport 4000\nmaxkeys 30\n")
+	assert.Nil(t, LoadFile(path))
+	assert.Nil(t, Set([][2]string{{"maxkeys", "50"}, {"eviction-policy", "allkeys-random"}}))
+	PprofAddress = ""
+	assert.Nil(t, Rewrite())
+
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "# my config\nmaxkeys 50\nport 4000\n"+
+		"eviction-policy allkeys-random\npprof-address \"\"\n", string(content))
+
+	// the rewritten file is loaded back to the same configuration
+	Set([][2]string{{"maxkeys", "1"}})
+	assert.Nil(t, LoadFile(path))
+	assert.EqualValues(t, 50, MaxKeyNumber)
+	assert.EqualValues(t, "", PprofAddress)
+}
+
+func TestGet_GlobPatterns(t *testing.T) {
+	assert.EqualValues(t, [][2]string{{"port", "3000"}}, Get("P[N-P]RT"))
+	assert.EqualValues(t, [][2]string{{"port", "3000"}}, Get("po?t"))
+}
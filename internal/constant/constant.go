@@ -3,6 +3,7 @@ package constant
 import "time"
 
 var RespNil = []byte("$-1\r\n")
+var RespNilArray = []byte("*-1\r\n")
 var RespOk = []byte("+OK\r\n")
 var RespZero = []byte(":0\r\n")
 var RespOne = []byte(":1\r\n")
@@ -11,11 +12,14 @@ var TtlKeyExistNoExpire = []byte(":-1\r\n")
 var ActiveExpireFrequency = 100 * time.Millisecond
 var ActiveExpireSampleSize = 20
 var ActiveExpireThreshold = 0.1
+
+// ActiveExpireFieldsPerHash bounds the number of expired fields of a hash deleted at once by
+// the active expire cycle, so that a big hash does not block the server
+var ActiveExpireFieldsPerHash = 1000
 var DefaultBPlusTreeDegree = 4
 
 const BfDefaultInitCapacity = 100
 const BfDefaultErrRate = 0.01
 
-const ServerStatusIdle = 1
-const ServerStatusBusy = 2
-const ServerStatusShuttingDown = 3
+// IOBufferSize is the size of a single read from a client socket
+const IOBufferSize = 16 * 1024
@@ -0,0 +1,268 @@
+package core
+
+import (
+	"Nietzsche/internal/constant"
+	"Nietzsche/internal/data_structure"
+	"container/heap"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// A blocking command, like BLPOP, that finds none of its keys ready does not reply: its handler
+// calls block, and the client is parked in the storage owning the keys with the BlockedClient
+// given to ExecuteBlocking. Like in Redis, after every write command the clients blocked on the
+// keys it wrote are served in the order they blocked, by running their command again, until the
+// key has nothing left for them. A client that is not served before its timeout receives a nil
+// array. The timeouts are checked by the loop running the active expire cycle, see
+// UnblockTimedOutClients.
+
+// BlockedClient receives the reply of the blocking commands of a client. It blocks one command
+// at a time, and can be used again once the reply of this command is received.
+type BlockedClient struct {
+	reply func(res []byte)
+	// the command waiting for its keys, spec is nil while the client is not blocked
+	spec    *CommandSpec
+	args    []string
+	keys    []string
+	objType uint8
+	// deadline is zero if the client waits forever, otherwise timeoutIndex is its position
+	// in the timeouts heap
+	deadline     time.Time
+	timeoutIndex int
+}
+
+// NewBlockedClient returns a BlockedClient calling reply with the reply of a blocked command once
+// it is served or timed out. reply is called by the goroutine running the storage, so it must not
+// wait for the client.
+func NewBlockedClient(reply func(res []byte)) *BlockedClient {
+	return &BlockedClient{reply: reply}
+}
+
+// blockRequest is set by block, for the executor to block the client once the handler returns
+type blockRequest struct {
+	args    []string // the arguments to run the command with once a key is ready, nil to keep them
+	keys    []string
+	objType uint8
+	timeout time.Duration
+}
+
+type blockingState struct {
+	waiters map[string][]*BlockedClient // clients blocked on a key, in the order they blocked
+	// ready are the keys with waiters written by a command, they are served by serveBlockedClients
+	ready    []string
+	isReady  map[string]bool
+	timeouts timeoutHeap
+	request  *blockRequest
+}
+
+func newBlockingState() blockingState {
+	return blockingState{
+		waiters: make(map[string][]*BlockedClient),
+		isReady: make(map[string]bool),
+	}
+}
+
+// timeoutHeap orders the blocked clients having a timeout by deadline
+type timeoutHeap []*BlockedClient
+
+func (h timeoutHeap) Len() int           { return len(h) }
+func (h timeoutHeap) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
+func (h timeoutHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].timeoutIndex = i
+	h[j].timeoutIndex = j
+}
+func (h *timeoutHeap) Push(x any) {
+	bc := x.(*BlockedClient)
+	bc.timeoutIndex = len(*h)
+	*h = append(*h, bc)
+}
+func (h *timeoutHeap) Pop() any {
+	old := *h
+	bc := old[len(old)-1]
+	*h = old[:len(old)-1]
+	bc.timeoutIndex = -1
+	return bc
+}
+
+// parseBlockTimeout parses the timeout of a blocking command, in seconds. 0 means forever.
+func parseBlockTimeout(arg string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, errors.New("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, errors.New("ERR timeout is negative")
+	}
+	if seconds > float64(math.MaxInt64/time.Second) {
+		return 0, errors.New("ERR timeout is out of range")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// block is called by the handler of a blocking command finding none of its keys ready, instead of
+// replying: the client waits until one of the keys is written and holds a value of objType, or
+// until the timeout if it is not 0. The handler returns the nil reply of block.
+func (s *Storage) block(keys []string, objType uint8, timeout time.Duration) []byte {
+	s.blocking.request = &blockRequest{keys: keys, objType: objType, timeout: timeout}
+	return nil
+}
+
+// blockRewriting is block for a command whose arguments depend on the keyspace at the time it
+// blocks, like the $ ID of XREAD: the command is run again with args once a key is ready
+func (s *Storage) blockRewriting(args, keys []string, objType uint8, timeout time.Duration) []byte {
+	s.block(keys, objType, timeout)
+	s.blocking.request.args = args
+	return nil
+}
+
+// takeBlockRequest returns the request of the last handler to block its client, if any
+func (s *Storage) takeBlockRequest() *blockRequest {
+	req := s.blocking.request
+	s.blocking.request = nil
+	return req
+}
+
+func (s *Storage) blockClient(bc *BlockedClient, spec *CommandSpec, args []string, req *blockRequest) {
+	if req.args != nil {
+		args = req.args
+	}
+	bc.spec, bc.args, bc.objType = spec, args, req.objType
+	bc.keys = slices.Compact(slices.Sorted(slices.Values(req.keys)))
+	for _, key := range bc.keys {
+		s.blocking.waiters[key] = append(s.blocking.waiters[key], bc)
+	}
+	bc.deadline, bc.timeoutIndex = time.Time{}, -1
+	if req.timeout > 0 {
+		bc.deadline = time.Now().Add(req.timeout)
+		heap.Push(&s.blocking.timeouts, bc)
+	}
+}
+
+// unblock removes the client from the queues of its keys and from the timeouts
+func (s *Storage) unblock(bc *BlockedClient) {
+	for _, key := range bc.keys {
+		waiters := slices.DeleteFunc(s.blocking.waiters[key], func(w *BlockedClient) bool { return w == bc })
+		if len(waiters) == 0 {
+			delete(s.blocking.waiters, key)
+		} else {
+			s.blocking.waiters[key] = waiters
+		}
+	}
+	if bc.timeoutIndex >= 0 {
+		heap.Remove(&s.blocking.timeouts, bc.timeoutIndex)
+	}
+	bc.spec, bc.args, bc.keys = nil, nil, nil
+}
+
+// UnblockClient removes a blocked client without replying, e.g. when it disconnects.
+// It does nothing if the client is not blocked.
+func (s *Storage) UnblockClient(bc *BlockedClient) {
+	if bc.spec != nil {
+		s.unblock(bc)
+	}
+}
+
+// signalKeysAsReady marks the keys written by the command that have blocked clients,
+// so that these clients are served once the command returns
+func (s *Storage) signalKeysAsReady(spec *CommandSpec, args []string) {
+	if len(s.blocking.waiters) == 0 || !spec.HasFlag(FlagWrite) {
+		return
+	}
+	for _, i := range spec.KeyIndexes(args) {
+		key := args[i]
+		if len(s.blocking.waiters[key]) > 0 && !s.blocking.isReady[key] {
+			s.blocking.isReady[key] = true
+			s.blocking.ready = append(s.blocking.ready, key)
+		}
+	}
+}
+
+// serveBlockedClients serves the clients blocked on the ready keys. Serving a client can make
+// other keys ready, e.g. the destination of BLMOVE, which are served in turn.
+func (s *Storage) serveBlockedClients() {
+	for len(s.blocking.ready) > 0 {
+		key := s.blocking.ready[0]
+		s.blocking.ready = s.blocking.ready[1:]
+		delete(s.blocking.isReady, key)
+		s.serveKey(key)
+	}
+}
+
+// serveKey runs the commands of the clients blocked on the key in FIFO order, while the key holds
+// a value of the type they wait for
+func (s *Storage) serveKey(key string) {
+	for _, bc := range slices.Clone(s.blocking.waiters[key]) {
+		if bc.spec == nil {
+			// unblocked by the reply of a client served before
+			continue
+		}
+		obj := s.dictStore.Get(key)
+		if obj == nil || obj.Type != bc.objType || isEmptyCollection(obj) {
+			return
+		}
+		res := bc.spec.Handler(s, bc.args)
+		if s.takeBlockRequest() != nil {
+			// the command found nothing for this client, it keeps waiting
+			continue
+		}
+		spec, args := bc.spec, bc.args
+		s.unblock(bc)
+		s.signalKeysAsReady(spec, args)
+		bc.reply(res)
+	}
+}
+
+// isEmptyCollection reports whether the object is a list or a sorted set without elements, which a
+// failed command can leave behind and which has nothing to serve
+func isEmptyCollection(obj *data_structure.Obj) bool {
+	switch v := obj.Value.(type) {
+	case *data_structure.Quicklist:
+		return v.Len() == 0
+	case *data_structure.ZSet:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// UnblockTimedOutClients replies a nil array to the blocked clients whose timeout has passed
+func (s *Storage) UnblockTimedOutClients() {
+	now := time.Now()
+	for len(s.blocking.timeouts) > 0 && !s.blocking.timeouts[0].deadline.After(now) {
+		bc := s.blocking.timeouts[0]
+		s.unblock(bc)
+		bc.reply(constant.RespNilArray)
+	}
+}
+
+// NextBlockedTimeout returns the earliest deadline of the blocked clients, ok is false if no
+// client waits with a timeout
+func (s *Storage) NextBlockedTimeout() (deadline time.Time, ok bool) {
+	if len(s.blocking.timeouts) == 0 {
+		return time.Time{}, false
+	}
+	return s.blocking.timeouts[0].deadline, true
+}
+
+// ExecuteBlocking runs a command on the keyspace of the single-threaded server, see Storage.ExecuteBlocking
+func ExecuteBlocking(cmd *Command, bc *BlockedClient) []byte {
+	return defaultStorage.ExecuteBlocking(cmd, bc)
+}
+
+// UnblockClient removes a blocked client from the keyspace of the single-threaded server
+func UnblockClient(bc *BlockedClient) {
+	defaultStorage.UnblockClient(bc)
+}
+
+// UnblockTimedOutClients times out the blocked clients of the single-threaded server
+func UnblockTimedOutClients() {
+	defaultStorage.UnblockTimedOutClients()
+}
+
+// NextBlockedTimeout returns the earliest deadline of the blocked clients of the single-threaded server
+func NextBlockedTimeout() (time.Time, bool) {
+	return defaultStorage.NextBlockedTimeout()
+}
@@ -0,0 +1,207 @@
+package core_test
+
+import (
+	"Nietzsche/internal/core"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// blockedClient records the replies received by a core.BlockedClient
+type blockedClient struct {
+	bc      *core.BlockedClient
+	replies []interface{}
+}
+
+func newBlockedClient() *blockedClient {
+	c := &blockedClient{}
+	c.bc = core.NewBlockedClient(func(res []byte) {
+		reply, _ := core.Decode(res)
+		c.replies = append(c.replies, reply)
+	})
+	return c
+}
+
+// runBlocking runs the command for the client, it returns false if the client is blocked
+func (c *blockedClient) run(s *core.Storage, cmd string, args ...string) (interface{}, bool) {
+	res := s.ExecuteBlocking(&core.Command{Cmd: cmd, Args: args}, c.bc)
+	if res == nil {
+		return nil, false
+	}
+	reply, _ := core.Decode(res)
+	return reply, true
+}
+
+func TestBlocking_ServedInOrder(t *testing.T) {
+	s := core.NewStorage()
+	c1, c2, c3 := newBlockedClient(), newBlockedClient(), newBlockedClient()
+	_, ok := c1.run(s, "BLPOP", "a", "b", "0")
+	assert.False(t, ok)
+	_, ok = c2.run(s, "BLPOP", "b", "0")
+	assert.False(t, ok)
+	_, ok = c3.run(s, "BRPOP", "b", "a", "0")
+	assert.False(t, ok)
+
+	// c1 blocked first on b, then c2, then c3
+	assert.EqualValues(t, 2, run(s, "RPUSH", "b", "x", "y"))
+	assert.EqualValues(t, []interface{}{[]interface{}{"b", "x"}}, c1.replies)
+	assert.EqualValues(t, []interface{}{[]interface{}{"b", "y"}}, c2.replies)
+	assert.Empty(t, c3.replies)
+	assert.EqualValues(t, 0, run(s, "EXISTS", "b"))
+
+	// c1 is not blocked on a anymore
+	run(s, "LPUSH", "a", "z")
+	assert.EqualValues(t, []interface{}{"a", "z"}, c3.replies[0])
+	assert.Len(t, c1.replies, 1)
+	assert.EqualValues(t, 0, run(s, "EXISTS", "a"))
+}
+
+func TestBlocking_ReadyKeyNotBlocking(t *testing.T) {
+	s := core.NewStorage()
+	c := newBlockedClient()
+	run(s, "RPUSH", "b", "x")
+	res, ok := c.run(s, "BLPOP", "a", "b", "1")
+	assert.True(t, ok)
+	assert.EqualValues(t, []interface{}{"b", "x"}, res)
+
+	// Execute never blocks
+	assert.Nil(t, run(s, "BLPOP", "a", "0"))
+	run(s, "RPUSH", "a", "y")
+	assert.EqualValues(t, 1, run(s, "LLEN", "a"))
+
+	run(s, "SET", "str", "v")
+	res, _ = c.run(s, "BLPOP", "str", "0")
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", res)
+	res, _ = c.run(s, "BLPOP", "a", "-1")
+	assert.EqualValues(t, "ERR timeout is negative", res)
+	res, _ = c.run(s, "BLPOP", "a", "x")
+	assert.EqualValues(t, "ERR timeout is not a float or out of range", res)
+}
+
+func TestBlocking_Timeout(t *testing.T) {
+	s := core.NewStorage()
+	c1, c2 := newBlockedClient(), newBlockedClient()
+	c1.run(s, "BLPOP", "a", "0.05")
+	c2.run(s, "BZPOPMIN", "z", "0")
+	deadline, ok := s.NextBlockedTimeout()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(50*time.Millisecond), deadline, 20*time.Millisecond)
+
+	s.UnblockTimedOutClients()
+	assert.Empty(t, c1.replies)
+	time.Sleep(60 * time.Millisecond)
+	s.UnblockTimedOutClients()
+	assert.EqualValues(t, []interface{}{nil}, c1.replies)
+	_, ok = s.NextBlockedTimeout()
+	assert.False(t, ok, "c2 waits forever")
+
+	// a timed out client is not served
+	run(s, "RPUSH", "a", "x")
+	assert.Len(t, c1.replies, 1)
+	assert.EqualValues(t, 1, run(s, "LLEN", "a"))
+}
+
+func TestBlocking_UnblockClient(t *testing.T) {
+	s := core.NewStorage()
+	c1, c2 := newBlockedClient(), newBlockedClient()
+	c1.run(s, "BLPOP", "a", "10")
+	c2.run(s, "BLPOP", "a", "10")
+	s.UnblockClient(c1.bc)
+	s.UnblockClient(c1.bc)
+	run(s, "RPUSH", "a", "x")
+	assert.Empty(t, c1.replies)
+	assert.EqualValues(t, []interface{}{[]interface{}{"a", "x"}}, c2.replies)
+	_, ok := s.NextBlockedTimeout()
+	assert.False(t, ok)
+
+	// the client can block again once served
+	c2.run(s, "BLPOP", "a", "0")
+	run(s, "RPUSH", "a", "y")
+	assert.EqualValues(t, []interface{}{"a", "y"}, c2.replies[1])
+}
+
+func TestBlocking_FlushAll(t *testing.T) {
+	s := core.NewStorage()
+	c1, c2, c3 := newBlockedClient(), newBlockedClient(), newBlockedClient()
+	c1.run(s, "BLPOP", "a", "0.02")
+	c2.run(s, "BLPOP", "a", "10")
+	c3.run(s, "BLPOP", "a", "0")
+	assert.EqualValues(t, "OK", run(s, "FLUSHALL"))
+
+	// the blocked clients survive the flush
+	time.Sleep(30 * time.Millisecond)
+	s.UnblockTimedOutClients()
+	assert.EqualValues(t, []interface{}{nil}, c1.replies)
+	s.UnblockClient(c2.bc)
+	_, ok := s.NextBlockedTimeout()
+	assert.False(t, ok)
+	run(s, "RPUSH", "a", "x")
+	assert.Empty(t, c2.replies)
+	assert.EqualValues(t, []interface{}{[]interface{}{"a", "x"}}, c3.replies)
+}
+
+func TestBlocking_WrongTypeKeepsWaiting(t *testing.T) {
+	s := core.NewStorage()
+	c := newBlockedClient()
+	c.run(s, "BLPOP", "k", "0")
+	run(s, "SET", "k", "v")
+	assert.Empty(t, c.replies)
+	run(s, "DEL", "k")
+	run(s, "RPUSH", "k", "x")
+	assert.EqualValues(t, []interface{}{[]interface{}{"k", "x"}}, c.replies)
+}
+
+func TestBLMOVE(t *testing.T) {
+	s := core.NewStorage()
+	c1, c2 := newBlockedClient(), newBlockedClient()
+	run(s, "RPUSH", "src", "a")
+	res, ok := c1.run(s, "BLMOVE", "src", "dst", "LEFT", "RIGHT", "0")
+	assert.True(t, ok)
+	assert.EqualValues(t, "a", res)
+
+	// a chain: c1 moves from src to dst, c2 from dst to dst2 and c3 pops dst2
+	c3 := newBlockedClient()
+	run(s, "DEL", "dst")
+	_, ok = c1.run(s, "BLMOVE", "src", "dst", "LEFT", "RIGHT", "0")
+	assert.False(t, ok)
+	_, ok = c2.run(s, "BLMOVE", "dst", "dst2", "RIGHT", "LEFT", "0")
+	assert.False(t, ok)
+	_, ok = c3.run(s, "BLPOP", "dst2", "0")
+	assert.False(t, ok)
+	run(s, "RPUSH", "src", "b")
+	assert.EqualValues(t, []interface{}{"b"}, c1.replies)
+	assert.EqualValues(t, []interface{}{"b"}, c2.replies)
+	assert.EqualValues(t, []interface{}{[]interface{}{"dst2", "b"}}, c3.replies)
+	for _, key := range []string{"src", "dst", "dst2"} {
+		assert.EqualValues(t, 0, run(s, "EXISTS", key))
+	}
+
+	res, _ = c1.run(s, "BLMOVE", "src", "dst", "LEFT", "UP", "0")
+	assert.EqualValues(t, "ERR syntax error", res)
+}
+
+func TestBZPOPMIN(t *testing.T) {
+	s := core.NewStorage()
+	c := newBlockedClient()
+	run(s, "ZADD", "z", "2", "b", "1", "a")
+	res, ok := c.run(s, "BZPOPMIN", "missing", "z", "0")
+	assert.True(t, ok)
+	assert.EqualValues(t, []interface{}{"z", "a", "1.000000"}, res)
+	c.run(s, "BZPOPMIN", "z", "0")
+	assert.EqualValues(t, 0, run(s, "EXISTS", "z"))
+
+	_, ok = c.run(s, "BZPOPMIN", "z", "0")
+	assert.False(t, ok)
+	run(s, "ZADD", "z", "5", "e")
+	assert.EqualValues(t, []interface{}{[]interface{}{"z", "e", "5.000000"}}, c.replies)
+	assert.Nil(t, run(s, "BZPOPMIN", "z", "0"))
+
+	// a failed ZADD does not serve an empty sorted set
+	_, ok = c.run(s, "BZPOPMIN", "z2", "0")
+	assert.False(t, ok)
+	run(s, "ZADD", "z2", "notafloat", "m")
+	assert.Len(t, c.replies, 1)
+	assert.Nil(t, run(s, "BZPOPMIN", "z2", "0"))
+	run(s, "ZADD", "z2", "1", "m")
+	assert.EqualValues(t, []interface{}{"z2", "m", "1.000000"}, c.replies[1])
+}
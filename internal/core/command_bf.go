@@ -8,7 +8,16 @@ import (
 	"strconv"
 )
 
-func cmdBFRESERVE(args []string) []byte {
+// getBloom returns the Bloom filter of the key, nil if it does not exist
+func (s *Storage) getBloom(key string) (*data_structure.Bloom, error) {
+	obj, err := s.lookup(key, data_structure.ObjTypeBloom)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.Bloom), nil
+}
+
+func (s *Storage) cmdBFRESERVE(args []string) []byte {
 	if !(len(args) == 3 || len(args) == 5) {
 		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.RESERVE' command"), false)
 	}
@@ -21,24 +30,27 @@ func cmdBFRESERVE(args []string) []byte {
 	if err != nil {
 		return Encode(errors.New(fmt.Sprintf("capacity must be an integer number %s", args[2])), false)
 	}
-	_, exist := bloomStore[key]
-	if exist {
+	bloom, err := s.getBloom(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom != nil {
 		return Encode(errors.New(fmt.Sprintf("Bloom filter with key '%s' already exist", key)), false)
 	}
-	bloomStore[key] = data_structure.CreateBloomFilter(capacity, errRate)
+	s.dictStore.Set(key, data_structure.NewBloomObj(data_structure.CreateBloomFilter(capacity, errRate)))
 	return constant.RespOk
 }
 
-func cmdBFMADD(args []string) []byte {
-	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.MADD' command"), false)
-	}
+func (s *Storage) cmdBFMADD(args []string) []byte {
 	key := args[0]
-	bloom, exist := bloomStore[key]
-	if !exist {
+	bloom, err := s.getBloom(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom == nil {
 		bloom = data_structure.CreateBloomFilter(constant.BfDefaultInitCapacity,
 			constant.BfDefaultErrRate)
-		bloomStore[key] = bloom
+		s.dictStore.Set(key, data_structure.NewBloomObj(bloom))
 	}
 	var res []string
 	for i := 1; i < len(args); i++ {
@@ -49,13 +61,13 @@ func cmdBFMADD(args []string) []byte {
 	return Encode(res, false)
 }
 
-func cmdBFEXISTS(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'BF.EXISTS' command"), false)
-	}
+func (s *Storage) cmdBFEXISTS(args []string) []byte {
 	key, item := args[0], args[1]
-	bloom, exist := bloomStore[key]
-	if !exist {
+	bloom, err := s.getBloom(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if bloom == nil {
 		return constant.RespZero
 	}
 	if !bloom.Exist(item) {
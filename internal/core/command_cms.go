@@ -9,10 +9,16 @@ import (
 	"strconv"
 )
 
-func cmdCMSINITBYDIM(args []string) []byte {
-	if len(args) != 3 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INITBYDIM' command"), false)
+// getCMS returns the Count-Min Sketch of the key, nil if it does not exist
+func (s *Storage) getCMS(key string) (*data_structure.CMS, error) {
+	obj, err := s.lookup(key, data_structure.ObjTypeCMS)
+	if obj == nil {
+		return nil, err
 	}
+	return obj.Value.(*data_structure.CMS), nil
+}
+
+func (s *Storage) cmdCMSINITBYDIM(args []string) []byte {
 	key := args[0]
 	width, err := strconv.ParseUint(args[1], 10, 32)
 	if err != nil {
@@ -22,18 +28,14 @@ func cmdCMSINITBYDIM(args []string) []byte {
 	if err != nil {
 		return Encode(errors.New(fmt.Sprintf("height must be a integer number %s", args[1])), false)
 	}
-	_, exist := cmsStore[key]
-	if exist {
+	if s.keyExists(key) {
 		return Encode(errors.New("CMS: key already exists"), false)
 	}
-	cmsStore[key] = data_structure.CreateCMS(uint32(width), uint32(height))
+	s.dictStore.Set(key, data_structure.NewCMSObj(data_structure.CreateCMS(uint32(width), uint32(height))))
 	return constant.RespOk
 }
 
-func cmdCMSINITBYPROB(args []string) []byte {
-	if len(args) != 3 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INITBYPROB' command"), false)
-	}
+func (s *Storage) cmdCMSINITBYPROB(args []string) []byte {
 	key := args[0]
 	errRate, err := strconv.ParseFloat(args[1], 64)
 	if err != nil {
@@ -49,22 +51,24 @@ func cmdCMSINITBYPROB(args []string) []byte {
 	if probability >= 1 || probability <= 0 {
 		return Encode(errors.New("CMS: invalid prob value"), false)
 	}
-	_, exist := cmsStore[key]
-	if exist {
+	if s.keyExists(key) {
 		return Encode(errors.New("CMS: key already exists"), false)
 	}
 	w, h := data_structure.CalcCMSDim(errRate, probability)
-	cmsStore[key] = data_structure.CreateCMS(w, h)
+	s.dictStore.Set(key, data_structure.NewCMSObj(data_structure.CreateCMS(w, h)))
 	return constant.RespOk
 }
 
-func cmdCMSINCRBY(args []string) []byte {
-	if len(args) < 3 || len(args)%2 == 0 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.INCBY' command"), false)
+func (s *Storage) cmdCMSINCRBY(args []string) []byte {
+	if len(args)%2 == 0 {
+		return Encode(errors.New("ERR wrong number of arguments for 'cms.incrby' command"), false)
 	}
 	key := args[0]
-	cms, exist := cmsStore[key]
-	if !exist {
+	cms, err := s.getCMS(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms == nil {
 		return Encode(errors.New("CMS: key does not exist"), false)
 	}
 	var res []string
@@ -84,13 +88,13 @@ func cmdCMSINCRBY(args []string) []byte {
 	return Encode(res, false)
 }
 
-func cmdCMSQUERY(args []string) []byte {
-	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'CMS.QUERY' command"), false)
-	}
+func (s *Storage) cmdCMSQUERY(args []string) []byte {
 	key := args[0]
-	cms, exist := cmsStore[key]
-	if !exist {
+	cms, err := s.getCMS(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if cms == nil {
 		return Encode(errors.New("CMS: key does not exist"), false)
 	}
 	var res []string
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commandInfo returns the reply of COMMAND INFO for one command, in the Redis 7 layout:
+// name, arity, flags, first key, last key, step, ACL categories, tips, key specs, subcommands
+func commandInfo(spec *CommandSpec) []interface{} {
+	return []interface{}{
+		spec.Name,
+		spec.Arity,
+		stringsToInterfaces(spec.FlagNames()),
+		spec.FirstKey,
+		spec.LastKey,
+		spec.Step,
+		stringsToInterfaces(spec.AclCategories()),
+		stringsToInterfaces(spec.Tips),
+		[]interface{}{},
+		[]interface{}{},
+	}
+}
+
+// commandDocs returns the documentation map of one command as a flat array
+func commandDocs(spec *CommandSpec) []interface{} {
+	return []interface{}{
+		"summary", spec.Summary,
+		"since", spec.Since,
+		"group", spec.Group,
+	}
+}
+
+func stringsToInterfaces(values []string) []interface{} {
+	res := make([]interface{}, len(values))
+	for i, v := range values {
+		res[i] = v
+	}
+	return res
+}
+
+// specsOf returns the specs of the given command names, all commands if names is empty.
+// Unknown names are mapped to nil.
+func specsOf(names []string) []*CommandSpec {
+	if len(names) == 0 {
+		names = commandNames()
+	}
+	specs := make([]*CommandSpec, len(names))
+	for i, name := range names {
+		specs[i] = LookupCommand(name)
+	}
+	return specs
+}
+
+func (s *Storage) cmdCOMMAND(args []string) []byte {
+	if len(args) == 0 {
+		return s.cmdCOMMANDINFO(nil)
+	}
+	subcommand := strings.ToUpper(args[0])
+	switch subcommand {
+	case "COUNT":
+		if len(args) != 1 {
+			return Encode(errWrongArity("command|count"), false)
+		}
+		return Encode(len(commandTable), false)
+	case "INFO":
+		return s.cmdCOMMANDINFO(args[1:])
+	case "DOCS":
+		return s.cmdCOMMANDDOCS(args[1:])
+	case "LIST":
+		if len(args) != 1 {
+			return Encode(errSyntax, false)
+		}
+		return Encode(commandNames(), false)
+	}
+	return Encode(fmt.Errorf("ERR unknown subcommand '%s'. Try COMMAND HELP.", args[0]), false)
+}
+
+// cmdCOMMANDINFO returns the details of the given commands, nil for an unknown one
+func (s *Storage) cmdCOMMANDINFO(names []string) []byte {
+	specs := specsOf(names)
+	res := make([]interface{}, len(specs))
+	for i, spec := range specs {
+		if spec != nil {
+			res[i] = commandInfo(spec)
+		}
+	}
+	return Encode(res, false)
+}
+
+// cmdCOMMANDDOCS returns a map from command name to its documentation, unknown commands are skipped
+func (s *Storage) cmdCOMMANDDOCS(names []string) []byte {
+	var res []interface{}
+	for _, spec := range specsOf(names) {
+		if spec != nil {
+			res = append(res, spec.Name, commandDocs(spec))
+		}
+	}
+	if res == nil {
+		res = []interface{}{}
+	}
+	return Encode(res, false)
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"Nietzsche/internal/config"
+	"Nietzsche/internal/constant"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func (s *Storage) cmdCONFIG(args []string) []byte {
+	subcommand := strings.ToUpper(args[0])
+	switch subcommand {
+	case "GET":
+		if len(args) < 2 {
+			return Encode(errWrongArity("config|get"), false)
+		}
+		var res []string
+		for _, pair := range config.Get(args[1:]...) {
+			res = append(res, pair[0], pair[1])
+		}
+		if res == nil {
+			res = []string{}
+		}
+		return Encode(res, false)
+	case "SET":
+		if len(args) < 3 || len(args)%2 == 0 {
+			return Encode(errWrongArity("config|set"), false)
+		}
+		pairs := make([][2]string, 0, len(args)/2)
+		for i := 1; i < len(args); i += 2 {
+			pairs = append(pairs, [2]string{args[i], args[i+1]})
+		}
+		if err := config.Set(pairs); err != nil {
+			return Encode(err, false)
+		}
+		return constant.RespOk
+	case "REWRITE":
+		if len(args) != 1 {
+			return Encode(errWrongArity("config|rewrite"), false)
+		}
+		if err := config.Rewrite(); err != nil {
+			return Encode(errors.New("ERR "+err.Error()), false)
+		}
+		return constant.RespOk
+	}
+	return Encode(fmt.Errorf("ERR unknown subcommand '%s'. Try CONFIG HELP.", args[0]), false)
+}
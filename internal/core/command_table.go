@@ -0,0 +1,522 @@
+package core
+
+import (
+	"Nietzsche/internal/constant"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Command flags, same meaning as the flags reported by Redis COMMAND
+const (
+	FlagWrite    = 1 << iota // may modify the keyspace
+	FlagReadonly             // only reads the keyspace
+	FlagDenyOOM              // may increase memory usage
+	FlagAdmin                // administrative command
+	FlagNoScript             // not allowed in scripts
+	FlagLoading              // allowed while loading the database
+	FlagStale                // allowed while a replica has stale data
+	FlagFast                 // O(1) or O(log(N)) command
+	FlagBlocking             // may block the client until a key is ready
+)
+
+var flagNames = []struct {
+	flag int
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadonly, "readonly"},
+	{FlagDenyOOM, "denyoom"},
+	{FlagAdmin, "admin"},
+	{FlagNoScript, "noscript"},
+	{FlagLoading, "loading"},
+	{FlagStale, "stale"},
+	{FlagFast, "fast"},
+	{FlagBlocking, "blocking"},
+}
+
+// CommandSpec describes a command of the command table
+type CommandSpec struct {
+	Name    string // lower case, as reported by COMMAND
+	Handler func(s *Storage, args []string) []byte
+	// Arity is the number of tokens including the command name.
+	// A negative arity -N means at least N tokens.
+	Arity int
+	Flags int
+	// Positions of the keys in the tokens (the command name is at 0): the first key,
+	// the last key (negative counts from the end) and the step between two keys.
+	// They are all 0 for a command without key.
+	FirstKey int
+	LastKey  int
+	Step     int
+	// KeysFunc returns the indexes of the keys in args, for a command whose keys can not be
+	// located by FirstKey, LastKey and Step, like XREAD. It is nil for the other commands.
+	KeysFunc func(args []string) []int
+	Group    string // data type or area of the command, e.g. "string", "server"
+	Summary  string
+	Since    string
+	// Tips are hints for clients and proxies, e.g. "request_policy:all_shards"
+	Tips []string
+}
+
+// HasFlag reports whether the command has all the given flags
+func (spec *CommandSpec) HasFlag(flag int) bool {
+	return spec.Flags&flag == flag
+}
+
+// FlagNames returns the names of the command flags
+func (spec *CommandSpec) FlagNames() []string {
+	names := make([]string, 0)
+	for _, f := range flagNames {
+		if spec.HasFlag(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	if spec.KeysFunc != nil {
+		names = append(names, "movablekeys")
+	}
+	return names
+}
+
+// AclCategories returns the ACL categories of the command, derived from its flags and group
+func (spec *CommandSpec) AclCategories() []string {
+	var categories []string
+	if spec.HasFlag(FlagWrite) {
+		categories = append(categories, "@write")
+	}
+	if spec.HasFlag(FlagReadonly) {
+		categories = append(categories, "@read")
+	}
+	if spec.HasFlag(FlagAdmin) {
+		categories = append(categories, "@admin", "@dangerous")
+	}
+	categories = append(categories, "@"+strings.ReplaceAll(spec.Group, "-", ""))
+	if spec.HasFlag(FlagFast) {
+		categories = append(categories, "@fast")
+	} else {
+		categories = append(categories, "@slow")
+	}
+	if spec.HasFlag(FlagBlocking) {
+		categories = append(categories, "@blocking")
+	}
+	return categories
+}
+
+// Tip returns the value of a tip, e.g. "all_shards" for "request_policy", or "" if the command has no such tip
+func (spec *CommandSpec) Tip(name string) string {
+	for _, tip := range spec.Tips {
+		if strings.HasPrefix(tip, name+":") {
+			return tip[len(name)+1:]
+		}
+	}
+	return ""
+}
+
+// CheckArity reports whether args, the tokens after the command name, match the arity
+func (spec *CommandSpec) CheckArity(args []string) bool {
+	tokens := len(args) + 1
+	if spec.Arity >= 0 {
+		return tokens == spec.Arity
+	}
+	return tokens >= -spec.Arity
+}
+
+// KeyIndexes returns the indexes in args, the tokens after the command name, holding a key
+func (spec *CommandSpec) KeyIndexes(args []string) []int {
+	if spec.KeysFunc != nil {
+		return spec.KeysFunc(args)
+	}
+	if spec.FirstKey == 0 || spec.FirstKey > len(args) {
+		return nil
+	}
+	last := spec.LastKey
+	if last < 0 {
+		last = len(args) + 1 + last
+	}
+	if last > len(args) {
+		last = len(args)
+	}
+	var indexes []int
+	for i := spec.FirstKey; i <= last; i += spec.Step {
+		indexes = append(indexes, i-1)
+	}
+	return indexes
+}
+
+// commandTable maps upper case command names to their spec
+var commandTable = make(map[string]*CommandSpec)
+
+func init() {
+	for _, spec := range []*CommandSpec{
+		// connection
+		{Name: "ping", Handler: (*Storage).cmdPING, Arity: -1, Flags: FlagFast, Group: "connection",
+			Summary: "Returns the server's liveliness response.", Since: "1.0.0"},
+		// server
+		{Name: "info", Handler: (*Storage).cmdINFO, Arity: -1, Flags: FlagLoading | FlagStale, Group: "server",
+			Summary: "Returns information and statistics about the server.", Since: "1.0.0",
+			Tips: []string{"nondeterministic_output", "request_policy:all_shards", "response_policy:special"}},
+		{Name: "command", Handler: (*Storage).cmdCOMMAND, Arity: -1, Flags: FlagLoading | FlagStale, Group: "server",
+			Summary: "Returns detailed information about all commands.", Since: "2.8.13",
+			Tips: []string{"nondeterministic_output_order"}},
+		{Name: "dbsize", Handler: (*Storage).cmdDBSIZE, Arity: 1, Flags: FlagReadonly | FlagFast, Group: "server",
+			Summary: "Returns the number of keys in the database.", Since: "1.0.0",
+			Tips: []string{"request_policy:all_shards", "response_policy:agg_sum"}},
+		{Name: "config", Handler: (*Storage).cmdCONFIG, Arity: -2, Flags: FlagAdmin | FlagNoScript | FlagLoading | FlagStale, Group: "server",
+			Summary: "Gets, sets or rewrites the configuration parameters.", Since: "2.0.0"},
+		{Name: "flushall", Handler: (*Storage).cmdFLUSHALL, Arity: -1, Flags: FlagWrite, Group: "server",
+			Summary: "Removes all keys from all databases.", Since: "1.0.0",
+			Tips: []string{"request_policy:all_shards", "response_policy:all_succeeded"}},
+		{Name: "shutdown", Handler: (*Storage).cmdSHUTDOWN, Arity: -1, Flags: FlagAdmin | FlagNoScript | FlagLoading | FlagStale,
+			Group: "server", Summary: "Synchronously saves the database(s) to disk and shuts down the server.", Since: "1.0.0"},
+		{Name: "keypartition", Handler: (*Storage).cmdKEYPARTITION, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "server", Summary: "Returns the id of the worker owning a key, keys with the same {hash tag} have the same owner.", Since: "0.1.0"},
+		// string
+		{Name: "set", Handler: (*Storage).cmdSET, Arity: -3, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.", Since: "1.0.0"},
+		{Name: "get", Handler: (*Storage).cmdGET, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the string value of a key.", Since: "1.0.0"},
+		{Name: "mget", Handler: (*Storage).cmdMGET, Arity: -2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "string", Summary: "Atomically returns the string values of one or more keys.", Since: "1.0.0",
+			Tips: []string{"request_policy:multi_shard"}},
+		{Name: "mset", Handler: (*Storage).cmdMSET, Arity: -3, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: -1, Step: 2,
+			Group: "string", Summary: "Atomically creates or modifies the string values of one or more keys.", Since: "1.0.1",
+			Tips: []string{"request_policy:multi_shard", "response_policy:all_succeeded"}},
+		{Name: "msetnx", Handler: (*Storage).cmdMSETNX, Arity: -3, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: -1, Step: 2,
+			Group: "string", Summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.", Since: "1.0.1",
+			Tips: []string{"request_policy:multi_shard", "response_policy:agg_min"}},
+		{Name: "setnx", Handler: (*Storage).cmdSETNX, Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Set the string value of a key only when the key doesn't exist.", Since: "1.0.0"},
+		{Name: "setex", Handler: (*Storage).cmdSETEX, Arity: 4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Sets the string value and expiration time of a key. Creates the key if it doesn't exist.", Since: "2.0.0"},
+		{Name: "psetex", Handler: (*Storage).cmdPSETEX, Arity: 4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist.", Since: "2.6.0"},
+		{Name: "getset", Handler: (*Storage).cmdGETSET, Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the previous string value of a key after setting it to a new value.", Since: "1.0.0"},
+		{Name: "getdel", Handler: (*Storage).cmdGETDEL, Arity: 2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the string value of a key after deleting the key.", Since: "6.2.0"},
+		{Name: "getex", Handler: (*Storage).cmdGETEX, Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the string value of a key after setting its expiration time.", Since: "6.2.0"},
+		{Name: "incr", Handler: (*Storage).cmdINCR, Arity: 2, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0"},
+		{Name: "decr", Handler: (*Storage).cmdDECR, Arity: 2, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0"},
+		{Name: "incrby", Handler: (*Storage).cmdINCRBY, Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0"},
+		{Name: "decrby", Handler: (*Storage).cmdDECRBY, Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.", Since: "1.0.0"},
+		{Name: "incrbyfloat", Handler: (*Storage).cmdINCRBYFLOAT, Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.", Since: "2.6.0"},
+		{Name: "append", Handler: (*Storage).cmdAPPEND, Arity: 3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.", Since: "2.0.0"},
+		{Name: "strlen", Handler: (*Storage).cmdSTRLEN, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns the length of a string value.", Since: "2.2.0"},
+		{Name: "getrange", Handler: (*Storage).cmdGETRANGE, Arity: 4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns a substring of the string stored at a key.", Since: "2.4.0"},
+		{Name: "substr", Handler: (*Storage).cmdGETRANGE, Arity: 4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Returns a substring from a string value.", Since: "1.0.0"},
+		{Name: "setrange", Handler: (*Storage).cmdSETRANGE, Arity: 4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "string", Summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.", Since: "2.2.0"},
+		{Name: "lcs", Handler: (*Storage).cmdLCS, Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "string", Summary: "Finds the longest common substring.", Since: "7.0.0"},
+		// hash
+		{Name: "hset", Handler: (*Storage).cmdHSET, Arity: -4, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Creates or modifies the value of a field in a hash.", Since: "2.0.0"},
+		{Name: "hsetnx", Handler: (*Storage).cmdHSETNX, Arity: 4, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Sets the value of a field in a hash only when the field doesn't exist.", Since: "2.0.0"},
+		{Name: "hget", Handler: (*Storage).cmdHGET, Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns the value of a field in a hash.", Since: "2.0.0"},
+		{Name: "hmget", Handler: (*Storage).cmdHMGET, Arity: -3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns the values of all fields in a hash.", Since: "2.0.0"},
+		{Name: "hdel", Handler: (*Storage).cmdHDEL, Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.", Since: "2.0.0"},
+		{Name: "hexists", Handler: (*Storage).cmdHEXISTS, Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Determines whether a field exists in a hash.", Since: "2.0.0"},
+		{Name: "hlen", Handler: (*Storage).cmdHLEN, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns the number of fields in a hash.", Since: "2.0.0"},
+		{Name: "hstrlen", Handler: (*Storage).cmdHSTRLEN, Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns the length of the value of a field.", Since: "3.2.0"},
+		{Name: "hkeys", Handler: (*Storage).cmdHKEYS, Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns all fields in a hash.", Since: "2.0.0",
+			Tips: []string{"nondeterministic_output_order"}},
+		{Name: "hvals", Handler: (*Storage).cmdHVALS, Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns all values in a hash.", Since: "2.0.0",
+			Tips: []string{"nondeterministic_output_order"}},
+		{Name: "hgetall", Handler: (*Storage).cmdHGETALL, Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns all fields and values in a hash.", Since: "2.0.0",
+			Tips: []string{"nondeterministic_output_order"}},
+		{Name: "hincrby", Handler: (*Storage).cmdHINCRBY, Arity: 4, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.", Since: "2.0.0"},
+		{Name: "hincrbyfloat", Handler: (*Storage).cmdHINCRBYFLOAT, Arity: 4, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist.", Since: "2.6.0"},
+		{Name: "hrandfield", Handler: (*Storage).cmdHRANDFIELD, Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns one or more random fields from a hash.", Since: "6.2.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "hscan", Handler: (*Storage).cmdHSCAN, Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Iterates over fields and values of a hash.", Since: "2.8.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "hexpire", Handler: (*Storage).cmdHEXPIRE, Arity: -6, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Set expiry for hash field using relative time to expire (seconds).", Since: "7.4.0"},
+		{Name: "hpexpire", Handler: (*Storage).cmdHPEXPIRE, Arity: -6, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Set expiry for hash field using relative time to expire (milliseconds).", Since: "7.4.0"},
+		{Name: "hexpireat", Handler: (*Storage).cmdHEXPIREAT, Arity: -6, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Set expiry for hash field using an absolute Unix timestamp (seconds).", Since: "7.4.0"},
+		{Name: "hpexpireat", Handler: (*Storage).cmdHPEXPIREAT, Arity: -6, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Set expiry for hash field using an absolute Unix timestamp (milliseconds).", Since: "7.4.0"},
+		{Name: "httl", Handler: (*Storage).cmdHTTL, Arity: -5, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns the TTL in seconds of a hash field.", Since: "7.4.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "hpttl", Handler: (*Storage).cmdHPTTL, Arity: -5, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Returns the TTL in milliseconds of a hash field.", Since: "7.4.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "hpersist", Handler: (*Storage).cmdHPERSIST, Arity: -5, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "hash", Summary: "Removes the expiration time for each specified field.", Since: "7.4.0"},
+		// list
+		{Name: "lpush", Handler: (*Storage).cmdLPUSH, Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0"},
+		{Name: "rpush", Handler: (*Storage).cmdRPUSH, Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.", Since: "1.0.0"},
+		{Name: "lpushx", Handler: (*Storage).cmdLPUSHX, Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Prepends one or more elements to a list only when the list exists.", Since: "2.2.0"},
+		{Name: "rpushx", Handler: (*Storage).cmdRPUSHX, Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Appends an element to a list only when the list exists.", Since: "2.2.0"},
+		{Name: "lpop", Handler: (*Storage).cmdLPOP, Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.", Since: "1.0.0"},
+		{Name: "rpop", Handler: (*Storage).cmdRPOP, Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns and removes the last elements of a list. Deletes the list if the last element was popped.", Since: "1.0.0"},
+		{Name: "llen", Handler: (*Storage).cmdLLEN, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns the length of a list.", Since: "1.0.0"},
+		{Name: "lrange", Handler: (*Storage).cmdLRANGE, Arity: 4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns a range of elements from a list.", Since: "1.0.0"},
+		{Name: "lindex", Handler: (*Storage).cmdLINDEX, Arity: 3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns an element from a list by its index.", Since: "1.0.0"},
+		{Name: "lset", Handler: (*Storage).cmdLSET, Arity: 4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Sets the value of an element in a list by its index.", Since: "1.0.0"},
+		{Name: "linsert", Handler: (*Storage).cmdLINSERT, Arity: 5, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Inserts an element before or after another element in a list.", Since: "2.2.0"},
+		{Name: "lrem", Handler: (*Storage).cmdLREM, Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Removes elements from a list. Deletes the list if the last element was removed.", Since: "1.0.0"},
+		{Name: "ltrim", Handler: (*Storage).cmdLTRIM, Arity: 4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.", Since: "1.0.0"},
+		{Name: "lpos", Handler: (*Storage).cmdLPOS, Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "list", Summary: "Returns the index of matching elements in a list.", Since: "6.0.6"},
+		{Name: "lmove", Handler: (*Storage).cmdLMOVE, Arity: 5, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "list", Summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.", Since: "6.2.0"},
+		{Name: "blpop", Handler: (*Storage).cmdBLPOP, Arity: -3, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1,
+			Group: "list", Summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", Since: "2.0.0"},
+		{Name: "brpop", Handler: (*Storage).cmdBRPOP, Arity: -3, Flags: FlagWrite | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1,
+			Group: "list", Summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.", Since: "2.0.0"},
+		{Name: "blmove", Handler: (*Storage).cmdBLMOVE, Arity: 6, Flags: FlagWrite | FlagDenyOOM | FlagBlocking, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "list", Summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved.", Since: "6.2.0"},
+		// stream
+		{Name: "xadd", Handler: (*Storage).cmdXADD, Arity: -5, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Appends a new message to a stream. Creates the key if it doesn't exist.", Since: "5.0.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "xlen", Handler: (*Storage).cmdXLEN, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Return the number of messages in a stream.", Since: "5.0.0"},
+		{Name: "xrange", Handler: (*Storage).cmdXRANGE, Arity: -4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Returns the messages from a stream within a range of IDs.", Since: "5.0.0"},
+		{Name: "xrevrange", Handler: (*Storage).cmdXREVRANGE, Arity: -4, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Returns the messages from a stream within a range of IDs in reverse order.", Since: "5.0.0"},
+		{Name: "xdel", Handler: (*Storage).cmdXDEL, Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Returns the number of messages after removing them from a stream.", Since: "5.0.0"},
+		{Name: "xtrim", Handler: (*Storage).cmdXTRIM, Arity: -4, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "stream", Summary: "Deletes messages from the beginning of a stream.", Since: "5.0.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "xread", Handler: (*Storage).cmdXREAD, Arity: -4, Flags: FlagReadonly | FlagBlocking, KeysFunc: xreadKeys,
+			Group: "stream", Summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.", Since: "5.0.0"},
+		// bitmap
+		{Name: "setbit", Handler: (*Storage).cmdSETBIT, Arity: 4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.", Since: "2.2.0"},
+		{Name: "getbit", Handler: (*Storage).cmdGETBIT, Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Returns a bit value by offset.", Since: "2.2.0"},
+		{Name: "bitcount", Handler: (*Storage).cmdBITCOUNT, Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Counts the number of set bits (population counting) in a string.", Since: "2.6.0"},
+		{Name: "bitpos", Handler: (*Storage).cmdBITPOS, Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Finds the first set (1) or clear (0) bit in a string.", Since: "2.8.7"},
+		{Name: "bitop", Handler: (*Storage).cmdBITOP, Arity: -4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 2, LastKey: -1, Step: 1,
+			Group: "bitmap", Summary: "Performs bitwise operations on multiple strings, and stores the result.", Since: "2.6.0"},
+		{Name: "bitfield", Handler: (*Storage).cmdBITFIELD, Arity: -2, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Performs arbitrary bitfield integer operations on strings.", Since: "3.2.0"},
+		{Name: "bitfield_ro", Handler: (*Storage).cmdBITFIELD_RO, Arity: -2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bitmap", Summary: "Performs arbitrary read-only bitfield integer operations on strings.", Since: "6.0.0"},
+		// generic
+		{Name: "ttl", Handler: (*Storage).cmdTTL, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Returns the expiration time in seconds of a key.", Since: "1.0.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "pttl", Handler: (*Storage).cmdPTTL, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Returns the expiration time in milliseconds of a key.", Since: "2.6.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "expiretime", Handler: (*Storage).cmdEXPIRETIME, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Returns the expiration time of a key as a Unix timestamp.", Since: "7.0.0"},
+		{Name: "pexpiretime", Handler: (*Storage).cmdPEXPIRETIME, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Returns the expiration time of a key as a Unix milliseconds timestamp.", Since: "7.0.0"},
+		{Name: "expire", Handler: (*Storage).cmdEXPIRE, Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Sets the expiration time of a key in seconds.", Since: "1.0.0"},
+		{Name: "pexpire", Handler: (*Storage).cmdPEXPIRE, Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Sets the expiration time of a key in milliseconds.", Since: "2.6.0"},
+		{Name: "expireat", Handler: (*Storage).cmdEXPIREAT, Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Sets the expiration time of a key to a Unix timestamp.", Since: "1.2.0"},
+		{Name: "pexpireat", Handler: (*Storage).cmdPEXPIREAT, Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.", Since: "2.6.0"},
+		{Name: "persist", Handler: (*Storage).cmdPERSIST, Arity: 2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Removes the expiration time of a key.", Since: "2.2.0"},
+		{Name: "del", Handler: (*Storage).cmdDEL, Arity: -2, Flags: FlagWrite, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "generic", Summary: "Deletes one or more keys.", Since: "1.0.0",
+			Tips: []string{"request_policy:multi_shard", "response_policy:agg_sum"}},
+		{Name: "unlink", Handler: (*Storage).cmdDEL, Arity: -2, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "generic", Summary: "Asynchronously deletes one or more keys.", Since: "4.0.0",
+			Tips: []string{"request_policy:multi_shard", "response_policy:agg_sum"}},
+		{Name: "exists", Handler: (*Storage).cmdEXISTS, Arity: -2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "generic", Summary: "Determines whether one or more keys exist.", Since: "1.0.0",
+			Tips: []string{"request_policy:multi_shard", "response_policy:agg_sum"}},
+		{Name: "touch", Handler: (*Storage).cmdTOUCH, Arity: -2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "generic", Summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.",
+			Since: "3.2.1", Tips: []string{"request_policy:multi_shard", "response_policy:agg_sum"}},
+		{Name: "type", Handler: (*Storage).cmdTYPE, Arity: 2, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "generic", Summary: "Determines the type of value stored at a key.", Since: "1.0.0"},
+		{Name: "rename", Handler: (*Storage).cmdRENAME, Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "generic", Summary: "Renames a key and overwrites the destination.", Since: "1.0.0"},
+		{Name: "renamenx", Handler: (*Storage).cmdRENAMENX, Arity: 3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "generic", Summary: "Renames a key only when the target key name doesn't exist.", Since: "1.0.0"},
+		{Name: "copy", Handler: (*Storage).cmdCOPY, Arity: -3, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 2, Step: 1,
+			Group: "generic", Summary: "Copies the value of a key to a new key.", Since: "6.2.0"},
+		{Name: "keys", Handler: (*Storage).cmdKEYS, Arity: 2, Flags: FlagReadonly, Group: "generic",
+			Summary: "Returns all key names that match a pattern.", Since: "1.0.0",
+			Tips: []string{"request_policy:all_shards", "nondeterministic_output_order"}},
+		{Name: "scan", Handler: (*Storage).cmdSCAN, Arity: -2, Flags: FlagReadonly, Group: "generic",
+			Summary: "Iterates over the key names in the database.", Since: "2.8.0",
+			Tips: []string{"nondeterministic_output", "request_policy:special", "response_policy:special"}},
+		{Name: "randomkey", Handler: (*Storage).cmdRANDOMKEY, Arity: 1, Flags: FlagReadonly, Group: "generic",
+			Summary: "Returns a random key name from the database.", Since: "1.0.0",
+			Tips: []string{"request_policy:all_shards", "response_policy:special", "nondeterministic_output"}},
+		// sorted set
+		{Name: "zadd", Handler: (*Storage).cmdZADD, Arity: -4, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Adds one or more members to a sorted set, or updates their scores.", Since: "1.2.0"},
+		{Name: "zscore", Handler: (*Storage).cmdZSCORE, Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns the score of a member in a sorted set.", Since: "1.2.0"},
+		{Name: "zrank", Handler: (*Storage).cmdZRANK, Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Returns the index of a member in a sorted set ordered by ascending scores.", Since: "2.0.0"},
+		{Name: "zscan", Handler: (*Storage).cmdZSCAN, Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "sorted-set", Summary: "Iterates over members and scores of a sorted set.", Since: "2.8.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "bzpopmin", Handler: (*Storage).cmdBZPOPMIN, Arity: -3, Flags: FlagWrite | FlagFast | FlagBlocking, FirstKey: 1, LastKey: -2, Step: 1,
+			Group: "sorted-set", Summary: "Removes and returns the member with the lowest score from one or more sorted sets. Blocks until a member is available otherwise. Deletes the sorted set if the last element was popped.", Since: "5.0.0"},
+		// set
+		{Name: "sadd", Handler: (*Storage).cmdSADD, Arity: -3, Flags: FlagWrite | FlagDenyOOM | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Adds one or more members to a set.", Since: "1.0.0"},
+		{Name: "srem", Handler: (*Storage).cmdSREM, Arity: -3, Flags: FlagWrite | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Removes one or more members from a set.", Since: "1.0.0"},
+		{Name: "smembers", Handler: (*Storage).cmdSMEMBERS, Arity: 2, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Returns all members of a set.", Since: "1.0.0",
+			Tips: []string{"nondeterministic_output_order"}},
+		{Name: "sismember", Handler: (*Storage).cmdSISMEMBER, Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Determines whether a member belongs to a set.", Since: "1.0.0"},
+		{Name: "sscan", Handler: (*Storage).cmdSSCAN, Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "set", Summary: "Iterates over members of a set.", Since: "2.8.0",
+			Tips: []string{"nondeterministic_output"}},
+		{Name: "sinter", Handler: (*Storage).cmdSINTER, Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Summary: "Returns the intersect of multiple sets.", Since: "1.0.0",
+			Tips: []string{"nondeterministic_output_order"}},
+		{Name: "sunion", Handler: (*Storage).cmdSUNION, Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Summary: "Returns the union of multiple sets.", Since: "1.0.0",
+			Tips: []string{"nondeterministic_output_order"}},
+		{Name: "sdiff", Handler: (*Storage).cmdSDIFF, Arity: -2, Flags: FlagReadonly, FirstKey: 1, LastKey: -1, Step: 1,
+			Group: "set", Summary: "Returns the difference of multiple sets.", Since: "1.0.0",
+			Tips: []string{"nondeterministic_output_order"}},
+		// Count-Min Sketch
+		{Name: "cms.initbydim", Handler: (*Storage).cmdCMSINITBYDIM, Arity: 4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Summary: "Initializes a Count-Min Sketch to dimensions specified by user.", Since: "2.0.0"},
+		{Name: "cms.initbyprob", Handler: (*Storage).cmdCMSINITBYPROB, Arity: 4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Summary: "Initializes a Count-Min Sketch to accommodate requested tolerances.", Since: "2.0.0"},
+		{Name: "cms.incrby", Handler: (*Storage).cmdCMSINCRBY, Arity: -4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Summary: "Increases the count of one or more items by increment.", Since: "2.0.0"},
+		{Name: "cms.query", Handler: (*Storage).cmdCMSQUERY, Arity: -3, Flags: FlagReadonly, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "cms", Summary: "Returns the count for one or more items in a sketch.", Since: "2.0.0"},
+		// Bloom filter
+		{Name: "bf.reserve", Handler: (*Storage).cmdBFRESERVE, Arity: -4, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Summary: "Creates a new Bloom Filter.", Since: "1.0.0"},
+		{Name: "bf.madd", Handler: (*Storage).cmdBFMADD, Arity: -3, Flags: FlagWrite | FlagDenyOOM, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Summary: "Adds one or more items to a Bloom Filter. A filter will be created if it does not exist.", Since: "1.0.0"},
+		{Name: "bf.exists", Handler: (*Storage).cmdBFEXISTS, Arity: 3, Flags: FlagReadonly | FlagFast, FirstKey: 1, LastKey: 1, Step: 1,
+			Group: "bf", Summary: "Checks whether an item exists in a Bloom Filter.", Since: "1.0.0"},
+	} {
+		commandTable[strings.ToUpper(spec.Name)] = spec
+	}
+}
+
+// LookupCommand returns the spec of a command, or nil if the command does not exist
+func LookupCommand(name string) *CommandSpec {
+	return commandTable[strings.ToUpper(name)]
+}
+
+// commandNames returns the names of all commands in alphabetical order
+func commandNames() []string {
+	names := make([]string, 0, len(commandTable))
+	for _, spec := range commandTable {
+		names = append(names, spec.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func errUnknownCommand(cmd *Command) error {
+	var args strings.Builder
+	for _, arg := range cmd.Args {
+		args.WriteString(fmt.Sprintf("'%s' ", arg))
+	}
+	return fmt.Errorf("ERR unknown command '%s', with args beginning with: %s", cmd.Cmd, args.String())
+}
+
+func errWrongArity(name string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", name)
+}
+
+var errSyntax = errors.New("ERR syntax error")
+var errNotInteger = errors.New("ERR value is not an integer or out of range")
+var errOutOfRange = errors.New("ERR value is out of range")
+var errNoSuchKey = errors.New("ERR no such key")
+var errInvalidCursor = errors.New("ERR invalid cursor")
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+func errInvalidExpireTime(name string) error {
+	return fmt.Errorf("ERR invalid expire time in '%s' command", name)
+}
+
+// Execute looks the command up in the command table, checks its arity and runs it on s.
+// It returns the RESP encoded response. A blocking command never blocks, it replies as if it
+// timed out when none of its keys is ready.
+func (s *Storage) Execute(cmd *Command) []byte {
+	return s.ExecuteBlocking(cmd, nil)
+}
+
+// ExecuteBlocking runs the command like Execute, but a blocking command finding none of its keys
+// ready blocks bc: the reply is then nil, and bc receives it once the command is served or times
+// out, see blocking.go. The clients blocked on the keys written by the command are served before
+// ExecuteBlocking returns.
+func (s *Storage) ExecuteBlocking(cmd *Command, bc *BlockedClient) []byte {
+	spec := LookupCommand(cmd.Cmd)
+	if spec == nil {
+		return Encode(errUnknownCommand(cmd), false)
+	}
+	if !spec.CheckArity(cmd.Args) {
+		return Encode(errWrongArity(spec.Name), false)
+	}
+	res := spec.Handler(s, cmd.Args)
+	if req := s.takeBlockRequest(); req != nil {
+		if bc == nil {
+			return constant.RespNilArray
+		}
+		s.blockClient(bc, spec, cmd.Args, req)
+		return nil
+	}
+	s.signalKeysAsReady(spec, cmd.Args)
+	s.serveBlockedClients()
+	return res
+}
+
+// Execute given a Command, executes it on the keyspace of the single-threaded server
+// and returns the RESP encoded response
+func Execute(cmd *Command) []byte {
+	return defaultStorage.Execute(cmd)
+}
@@ -0,0 +1,94 @@
+package core_test
+
+import (
+	"Nietzsche/internal/core"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCommandSpec_CheckArity(t *testing.T) {
+	get := core.LookupCommand("get")
+	assert.True(t, get.CheckArity([]string{"k"}))
+	assert.False(t, get.CheckArity([]string{}))
+	assert.False(t, get.CheckArity([]string{"k", "x"}))
+
+	sadd := core.LookupCommand("SADD")
+	assert.False(t, sadd.CheckArity([]string{"k"}))
+	assert.True(t, sadd.CheckArity([]string{"k", "a"}))
+	assert.True(t, sadd.CheckArity([]string{"k", "a", "b", "c"}))
+}
+
+func TestCommandSpec_KeyIndexes(t *testing.T) {
+	assert.EqualValues(t, []int{0}, core.LookupCommand("zadd").KeyIndexes([]string{"k", "1", "a"}))
+	assert.Nil(t, core.LookupCommand("ping").KeyIndexes([]string{"hello"}))
+	spec := &core.CommandSpec{Name: "mset", Arity: -3, FirstKey: 1, LastKey: -1, Step: 2}
+	assert.EqualValues(t, []int{0, 2, 4}, spec.KeyIndexes([]string{"a", "1", "b", "2", "c", "3"}))
+}
+
+func TestStorage_Execute(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, "+OK\r\n", s.Execute(&core.Command{Cmd: "SET", Args: []string{"k", "v"}}))
+	assert.EqualValues(t, "$1\r\nv\r\n", s.Execute(&core.Command{Cmd: "GET", Args: []string{"k"}}))
+	assert.EqualValues(t, "-ERR wrong number of arguments for 'get' command\r\n",
+		s.Execute(&core.Command{Cmd: "GET", Args: []string{}}))
+	assert.EqualValues(t, "-ERR unknown command 'NOPE', with args beginning with: 'a' \r\n",
+		s.Execute(&core.Command{Cmd: "NOPE", Args: []string{"a"}}))
+
+	// every Storage is an independent keyspace
+	other := core.NewStorage()
+	assert.EqualValues(t, "$-1\r\n", other.Execute(&core.Command{Cmd: "GET", Args: []string{"k"}}))
+}
+
+func TestCommandIntrospection(t *testing.T) {
+	s := core.NewStorage()
+	count, _ := core.Decode(s.Execute(&core.Command{Cmd: "COMMAND", Args: []string{"COUNT"}}))
+	all, _ := core.Decode(s.Execute(&core.Command{Cmd: "COMMAND"}))
+	assert.EqualValues(t, count, len(all.([]interface{})))
+
+	info, _ := core.Decode(s.Execute(&core.Command{Cmd: "COMMAND", Args: []string{"INFO", "get", "nope"}}))
+	assert.Len(t, info, 2)
+	get := info.([]interface{})[0].([]interface{})
+	assert.Len(t, get, 10)
+	assert.EqualValues(t, "get", get[0])
+	assert.EqualValues(t, 2, get[1])
+	assert.EqualValues(t, []interface{}{"readonly", "fast"}, get[2])
+	assert.EqualValues(t, []interface{}{int64(1), int64(1), int64(1)}, get[3:6])
+	assert.Nil(t, info.([]interface{})[1])
+
+	docs, _ := core.Decode(s.Execute(&core.Command{Cmd: "COMMAND", Args: []string{"DOCS", "ping"}}))
+	assert.EqualValues(t, "ping", docs.([]interface{})[0])
+}
+
+func TestConfigCommand(t *testing.T) {
+	s := core.NewStorage()
+	run := func(args ...string) interface{} {
+		res, _ := core.Decode(s.Execute(&core.Command{Cmd: "CONFIG", Args: args}))
+		return res
+	}
+	t.Cleanup(func() { run("SET", "maxkeys", "1000000") })
+
+	assert.EqualValues(t, []interface{}{"port", "3000"}, run("GET", "port"))
+	assert.EqualValues(t, "OK", run("SET", "maxkeys", "5000"))
+	assert.EqualValues(t, []interface{}{"maxkeys", "5000"}, run("get", "maxk*"))
+	assert.EqualValues(t, []interface{}{}, run("GET", "nope"))
+	assert.Contains(t, run("SET", "port", "1"), "can't set immutable config")
+	assert.EqualValues(t, "ERR wrong number of arguments for 'config|set' command", run("SET", "maxkeys"))
+	assert.EqualValues(t, "ERR the server is running without a config file", run("REWRITE"))
+	assert.Contains(t, run("NOPE"), "unknown subcommand 'NOPE'")
+}
+
+func TestShutdownCommand(t *testing.T) {
+	var modes []core.ShutdownMode
+	core.ShutdownHandler = func(mode core.ShutdownMode) { modes = append(modes, mode) }
+	t.Cleanup(func() { core.ShutdownHandler = nil })
+
+	s := core.NewStorage()
+	for _, args := range [][]string{{}, {"save"}, {"NOSAVE"}} {
+		// there is no reply, the connection is closed by the server
+		assert.Len(t, s.Execute(&core.Command{Cmd: "SHUTDOWN", Args: args}), 0)
+	}
+	assert.EqualValues(t, []core.ShutdownMode{core.ShutdownDefault, core.ShutdownSave, core.ShutdownNoSave}, modes)
+	assert.EqualValues(t, "-ERR syntax error\r\n", s.Execute(&core.Command{Cmd: "SHUTDOWN", Args: []string{"NOW"}}))
+	assert.EqualValues(t, "-ERR syntax error\r\n", s.Execute(&core.Command{Cmd: "SHUTDOWN", Args: []string{"SAVE", "NOSAVE"}}))
+	assert.Len(t, modes, 3)
+}
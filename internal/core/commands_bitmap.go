@@ -0,0 +1,478 @@
+package core
+
+import (
+	"Nietzsche/internal/config"
+	"Nietzsche/internal/constant"
+	"Nietzsche/internal/data_structure"
+	"errors"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errBitOffset = errors.New("ERR bit offset is not an integer or out of range")
+
+// parseBitOffset parses the offset of SETBIT and GETBIT. It is limited so that the string
+// holding the bit is not longer than proto-max-bulk-len.
+func parseBitOffset(value string) (uint64, error) {
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errBitOffset
+	}
+	return checkBitOffset(offset)
+}
+
+func checkBitOffset(offset int64) (uint64, error) {
+	if offset < 0 || offset>>3 >= int64(config.ProtoMaxBulkLen) {
+		return 0, errBitOffset
+	}
+	return uint64(offset), nil
+}
+
+// parseBit parses a bit argument, it returns false if it is not 0 or 1
+func parseBit(value string) (int, bool) {
+	bit, err := strconv.ParseInt(value, 10, 64)
+	return int(bit), err == nil && (bit == 0 || bit == 1)
+}
+
+// bitmapOf returns the bytes of the string value of the key, nil if it does not exist.
+// The value is converted to a raw []byte once, so that the next bit commands do not copy it.
+func (s *Storage) bitmapOf(key string) ([]byte, error) {
+	obj, err := s.getStringObj(key)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.MutableBytes(), nil
+}
+
+// bitmapObjForWrite returns the string object of the key, creating an empty one if it does not exist
+func (s *Storage) bitmapObjForWrite(key string) (*data_structure.Obj, error) {
+	obj, err := s.getStringObj(key)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		obj = data_structure.NewStringObj("")
+		s.dictStore.Set(key, obj)
+	}
+	return obj, nil
+}
+
+// bitRange converts the start and end offsets of BITCOUNT and BITPOS, in bytes or in bits if isBit,
+// to the range of bytes [first, last] of a string of length bytes. Negative offsets are relative
+// to the end of the string. firstMask and lastMask are the bits of the first and last bytes that
+// are out of the range, empty is true if the range holds no bit.
+func bitRange(length, start, end int64, isBit bool) (first, last int64, firstMask, lastMask byte, empty bool) {
+	total := length
+	if isBit {
+		total <<= 3
+	}
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	start, end = max(start, 0), min(max(end, 0), total-1)
+	if start > end {
+		return 0, 0, 0, 0, true
+	}
+	if isBit {
+		firstMask = ^byte(0xff >> (start & 7))
+		lastMask = byte(0xff >> (end&7 + 1))
+		start >>= 3
+		end >>= 3
+	}
+	return start, end, firstMask, lastMask, false
+}
+
+// parseBitUnit parses the BYTE or BIT unit of the offsets of BITCOUNT and BITPOS
+func parseBitUnit(value string) (isBit bool, err error) {
+	switch strings.ToUpper(value) {
+	case "BYTE":
+		return false, nil
+	case "BIT":
+		return true, nil
+	}
+	return false, errSyntax
+}
+
+func (s *Storage) cmdSETBIT(args []string) []byte {
+	key := args[0]
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	bit, ok := parseBit(args[2])
+	if !ok {
+		return Encode(errors.New("ERR bit is not an integer or out of range"), false)
+	}
+	obj, err := s.bitmapObjForWrite(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	b, old := data_structure.SetBit(obj.MutableBytes(), offset, bit == 1)
+	obj.SetBytes(b)
+	return Encode(old, false)
+}
+
+func (s *Storage) cmdGETBIT(args []string) []byte {
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	b, err := s.bitmapOf(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(data_structure.GetBit(b, offset), false)
+}
+
+// cmdBITCOUNT counts the bits set to 1 in the value, or in the range [start, end] of bytes or bits
+func (s *Storage) cmdBITCOUNT(args []string) []byte {
+	var start, end int64
+	isBit, ranged := false, false
+	switch len(args) {
+	case 1:
+	case 3, 4:
+		var err1, err2 error
+		start, err1 = strconv.ParseInt(args[1], 10, 64)
+		end, err2 = strconv.ParseInt(args[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			return Encode(errNotInteger, false)
+		}
+		if len(args) == 4 {
+			var err error
+			if isBit, err = parseBitUnit(args[3]); err != nil {
+				return Encode(err, false)
+			}
+		}
+		ranged = true
+	default:
+		return Encode(errSyntax, false)
+	}
+
+	b, err := s.bitmapOf(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !ranged {
+		return Encode(data_structure.BitCount(b), false)
+	}
+	first, last, firstMask, lastMask, empty := bitRange(int64(len(b)), start, end, isBit)
+	if empty {
+		return constant.RespZero
+	}
+	count := data_structure.BitCount(b[first : last+1])
+	count -= int64(bits.OnesCount8(b[first]&firstMask) + bits.OnesCount8(b[last]&lastMask))
+	return Encode(count, false)
+}
+
+// cmdBITPOS returns the position of the first bit equal to bit, in the whole value or from the
+// start offset to the end offset. Looking for a 0 without an end offset finds the padding zero
+// bits after the end of the value.
+func (s *Storage) cmdBITPOS(args []string) []byte {
+	bit, ok := parseBit(args[1])
+	if !ok {
+		return Encode(errors.New("ERR The bit argument must be 1 or 0."), false)
+	}
+	if len(args) > 5 {
+		return Encode(errSyntax, false)
+	}
+	var start, end int64
+	endGiven, isBit := false, false
+	var err error
+	if len(args) >= 3 {
+		if start, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+			return Encode(errNotInteger, false)
+		}
+	}
+	if len(args) >= 4 {
+		if end, err = strconv.ParseInt(args[3], 10, 64); err != nil {
+			return Encode(errNotInteger, false)
+		}
+		endGiven = true
+	}
+	if len(args) == 5 {
+		if isBit, err = parseBitUnit(args[4]); err != nil {
+			return Encode(err, false)
+		}
+	}
+
+	b, err := s.bitmapOf(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if b == nil {
+		// a missing key is an empty string, followed by zero bits
+		if bit == 1 {
+			return Encode(-1, false)
+		}
+		return constant.RespZero
+	}
+	if !endGiven {
+		end = -1
+	}
+	first, last, firstMask, lastMask, empty := bitRange(int64(len(b)), start, end, isBit)
+	if empty {
+		return Encode(-1, false)
+	}
+	window := b[first : last+1]
+	if firstMask|lastMask != 0 {
+		// the bits out of the range are set to the opposite of the bit looked for
+		window = slices.Clone(window)
+		if bit == 1 {
+			window[0] &^= firstMask
+			window[len(window)-1] &^= lastMask
+		} else {
+			window[0] |= firstMask
+			window[len(window)-1] |= lastMask
+		}
+	}
+	pos := data_structure.BitPos(window, bit)
+	if endGiven && bit == 0 && pos == int64(len(window))*8 {
+		return Encode(-1, false)
+	}
+	if pos != -1 {
+		pos += first * 8
+	}
+	return Encode(pos, false)
+}
+
+// cmdBITOP stores the result of AND, OR, XOR or NOT of the source keys in the destination key,
+// and returns its length. An empty result deletes the destination key.
+func (s *Storage) cmdBITOP(args []string) []byte {
+	op, dest, keys := strings.ToUpper(args[0]), args[1], args[2:]
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(keys) != 1 {
+			return Encode(errors.New("ERR BITOP NOT must be called with a single source key."), false)
+		}
+	default:
+		return Encode(errSyntax, false)
+	}
+	sources := make([][]byte, len(keys))
+	for i, key := range keys {
+		b, err := s.bitmapOf(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		sources[i] = b
+	}
+
+	res := data_structure.BitOp(op, sources)
+	if len(res) == 0 {
+		s.delKey(dest)
+		return constant.RespZero
+	}
+	obj := data_structure.NewStringObj("")
+	obj.SetBytes(res)
+	s.dictStore.DelExpiry(dest)
+	s.dictStore.Set(dest, obj)
+	return Encode(len(res), false)
+}
+
+var errBitfieldType = errors.New("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+
+// bitfieldOp is a GET, SET or INCRBY subcommand of BITFIELD on a signed or unsigned integer
+// of width bits. overflow is the OVERFLOW behavior in effect for SET and INCRBY.
+type bitfieldOp struct {
+	op       string
+	signed   bool
+	width    int
+	offset   uint64
+	value    int64
+	overflow string
+}
+
+// parseBitfieldType parses an integer type like i16 or u8, up to i64 and u63
+func parseBitfieldType(value string) (signed bool, width int, err error) {
+	if len(value) < 2 {
+		return false, 0, errBitfieldType
+	}
+	switch value[0] {
+	case 'i', 'I':
+		signed = true
+	case 'u', 'U':
+	default:
+		return false, 0, errBitfieldType
+	}
+	n, err := strconv.Atoi(value[1:])
+	if err != nil || n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, errBitfieldType
+	}
+	return signed, n, nil
+}
+
+// parseBitfieldOffset parses a bit offset, or with a # prefix a number of integers of width bits
+func parseBitfieldOffset(value string, width int) (uint64, error) {
+	multiplier := int64(1)
+	if rest, ok := strings.CutPrefix(value, "#"); ok {
+		value, multiplier = rest, int64(width)
+	}
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 || offset > math.MaxInt64/multiplier {
+		return 0, errBitOffset
+	}
+	return checkBitOffset(offset * multiplier)
+}
+
+// parseBitfieldOps parses the subcommands of BITFIELD, readonly only accepts GET like BITFIELD_RO
+func parseBitfieldOps(args []string, readonly bool) ([]bitfieldOp, error) {
+	var ops []bitfieldOp
+	overflow := "WRAP"
+	for i := 0; i < len(args); {
+		op := strings.ToUpper(args[i])
+		remaining := len(args) - i - 1
+		switch {
+		case op == "OVERFLOW" && remaining >= 1:
+			overflow = strings.ToUpper(args[i+1])
+			if overflow != "WRAP" && overflow != "SAT" && overflow != "FAIL" {
+				return nil, errors.New("ERR Invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		case op == "GET" && remaining >= 2:
+		case (op == "SET" || op == "INCRBY") && remaining >= 3:
+		default:
+			return nil, errSyntax
+		}
+
+		signed, width, err := parseBitfieldType(args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		offset, err := parseBitfieldOffset(args[i+2], width)
+		if err != nil {
+			return nil, err
+		}
+		o := bitfieldOp{op: op, signed: signed, width: width, offset: offset, overflow: overflow}
+		i += 3
+		if op != "GET" {
+			if o.value, err = strconv.ParseInt(args[i], 10, 64); err != nil {
+				return nil, errNotInteger
+			}
+			i++
+		}
+		if readonly && op != "GET" {
+			return nil, errors.New("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+		ops = append(ops, o)
+	}
+	return ops, nil
+}
+
+// add returns value + incr as an integer of the type of the operation, applying its overflow
+// behavior: WRAP keeps the low bits, SAT clamps to the bounds of the type and FAIL returns false.
+// An unsigned value is the uint64 with the same bits as value.
+func (o *bitfieldOp) add(value, incr int64) (int64, bool) {
+	var minValue, maxValue int64
+	overflow := 0
+	if o.signed {
+		maxValue = math.MaxInt64
+		if o.width < 64 {
+			maxValue = 1<<(o.width-1) - 1
+		}
+		minValue = -maxValue - 1
+		switch {
+		case value > maxValue || (incr > 0 && value > maxValue-incr):
+			overflow = 1
+		case value < minValue || (incr < 0 && value < minValue-incr):
+			overflow = -1
+		}
+	} else {
+		maxValue = 1<<o.width - 1
+		u := uint64(value)
+		switch {
+		case u > uint64(maxValue) || (incr > 0 && uint64(incr) > uint64(maxValue)-u):
+			overflow = 1
+		case incr < 0 && uint64(-incr) > u:
+			overflow = -1
+		}
+	}
+
+	if overflow != 0 {
+		switch o.overflow {
+		case "FAIL":
+			return 0, false
+		case "SAT":
+			if overflow > 0 {
+				return maxValue, true
+			}
+			return minValue, true
+		}
+	}
+	// wrap the sum, and sign extend it from its highest bit for signed integers
+	sum := uint64(value) + uint64(incr)
+	if !o.signed {
+		return int64(sum & uint64(maxValue)), true
+	}
+	return int64(sum<<(64-o.width)) >> (64 - o.width), true
+}
+
+// bitfield runs the subcommands of BITFIELD in order and replies with an array of their results.
+// SET replies with the previous value, INCRBY with the new one and an operation failing with
+// OVERFLOW FAIL with nil.
+func (s *Storage) bitfield(args []string, readonly bool) []byte {
+	key := args[0]
+	ops, err := parseBitfieldOps(args[1:], readonly)
+	if err != nil {
+		return Encode(err, false)
+	}
+	write := slices.ContainsFunc(ops, func(o bitfieldOp) bool { return o.op != "GET" })
+
+	var obj *data_structure.Obj
+	var b []byte
+	if write {
+		obj, err = s.bitmapObjForWrite(key)
+		if obj != nil {
+			b = obj.MutableBytes()
+		}
+	} else {
+		b, err = s.bitmapOf(key)
+	}
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	replies := make([][]byte, len(ops))
+	for i, o := range ops {
+		old := data_structure.GetBitfield(b, o.offset, o.width, o.signed)
+		if o.op == "GET" {
+			replies[i] = Encode(old, false)
+			continue
+		}
+		var value int64
+		var ok bool
+		if o.op == "SET" {
+			value, ok = o.add(o.value, 0)
+		} else {
+			value, ok = o.add(old, o.value)
+		}
+		if !ok {
+			replies[i] = constant.RespNil
+			continue
+		}
+		b = data_structure.SetBitfield(b, o.offset, o.width, value)
+		if o.op == "SET" {
+			replies[i] = Encode(old, false)
+		} else {
+			replies[i] = Encode(value, false)
+		}
+	}
+	if write {
+		obj.SetBytes(b)
+	}
+	return EncodeRawArray(replies)
+}
+
+func (s *Storage) cmdBITFIELD(args []string) []byte {
+	return s.bitfield(args, false)
+}
+
+func (s *Storage) cmdBITFIELD_RO(args []string) []byte {
+	return s.bitfield(args, true)
+}
@@ -0,0 +1,147 @@
+package core_test
+
+import (
+	"Nietzsche/internal/core"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestSETBIT_GETBIT(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, 0, run(s, "SETBIT", "k", "7", "1"))
+	assert.EqualValues(t, 1, run(s, "SETBIT", "k", "7", "0"))
+	assert.EqualValues(t, 0, run(s, "SETBIT", "k", "15", "1"))
+	assert.EqualValues(t, "\x00\x01", run(s, "GET", "k"))
+	assert.EqualValues(t, 1, run(s, "GETBIT", "k", "15"))
+	assert.EqualValues(t, 0, run(s, "GETBIT", "k", "100"))
+	assert.EqualValues(t, 0, run(s, "GETBIT", "missing", "0"))
+
+	// the bits of a string value are the bits of its characters
+	run(s, "SET", "str", "1")
+	assert.EqualValues(t, 1, run(s, "GETBIT", "str", "2"))
+	assert.EqualValues(t, 0, run(s, "SETBIT", "str", "6", "1"))
+	assert.EqualValues(t, "3", run(s, "GET", "str"))
+
+	assert.EqualValues(t, "ERR bit offset is not an integer or out of range", run(s, "SETBIT", "k", "-1", "1"))
+	assert.EqualValues(t, "ERR bit offset is not an integer or out of range", run(s, "SETBIT", "k", "4294967296", "1"))
+	assert.EqualValues(t, "ERR bit is not an integer or out of range", run(s, "SETBIT", "k", "1", "2"))
+	run(s, "SADD", "set", "a")
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", run(s, "SETBIT", "set", "1", "1"))
+}
+
+func TestBITCOUNT(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "k", "foobar")
+	assert.EqualValues(t, 26, run(s, "BITCOUNT", "k"))
+	assert.EqualValues(t, 4, run(s, "BITCOUNT", "k", "0", "0"))
+	assert.EqualValues(t, 6, run(s, "BITCOUNT", "k", "1", "1"))
+	assert.EqualValues(t, 6, run(s, "BITCOUNT", "k", "1", "1", "BYTE"))
+	assert.EqualValues(t, 17, run(s, "BITCOUNT", "k", "5", "30", "BIT"))
+	assert.EqualValues(t, 7, run(s, "BITCOUNT", "k", "-2", "-1"))
+	assert.EqualValues(t, 0, run(s, "BITCOUNT", "k", "3", "1"))
+	assert.EqualValues(t, 0, run(s, "BITCOUNT", "missing"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "BITCOUNT", "k", "0"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "BITCOUNT", "k", "0", "1", "BITS"))
+}
+
+func TestBITPOS(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "k", "\xff\xf0\x00")
+	assert.EqualValues(t, 12, run(s, "BITPOS", "k", "0"))
+	run(s, "SET", "k", "\x00\xff\xf0")
+	assert.EqualValues(t, 8, run(s, "BITPOS", "k", "1", "0"))
+	assert.EqualValues(t, 16, run(s, "BITPOS", "k", "1", "2"))
+	assert.EqualValues(t, 16, run(s, "BITPOS", "k", "1", "2", "-1", "BYTE"))
+	assert.EqualValues(t, 8, run(s, "BITPOS", "k", "1", "7", "15", "BIT"))
+	assert.EqualValues(t, 7, run(s, "BITPOS", "k", "0", "7", "15", "BIT"))
+
+	run(s, "SET", "ones", "\xff\xff\xff")
+	assert.EqualValues(t, 24, run(s, "BITPOS", "ones", "0"))
+	assert.EqualValues(t, -1, run(s, "BITPOS", "ones", "0", "0", "-1"))
+	assert.EqualValues(t, -1, run(s, "BITPOS", "ones", "0", "2", "1"))
+
+	assert.EqualValues(t, -1, run(s, "BITPOS", "missing", "1"))
+	assert.EqualValues(t, 0, run(s, "BITPOS", "missing", "0"))
+	assert.EqualValues(t, "ERR The bit argument must be 1 or 0.", run(s, "BITPOS", "k", "2"))
+}
+
+func TestBITOP(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "a", "foobar")
+	run(s, "SET", "b", "abcdef")
+	assert.EqualValues(t, 6, run(s, "BITOP", "AND", "dest", "a", "b"))
+	assert.EqualValues(t, "`bc`ab", run(s, "GET", "dest"))
+	assert.EqualValues(t, 6, run(s, "BITOP", "OR", "dest", "a", "b"))
+	assert.EqualValues(t, "goofev", run(s, "GET", "dest"))
+	assert.EqualValues(t, 6, run(s, "BITOP", "XOR", "dest", "a", "b", "missing"))
+	assert.EqualValues(t, "\x07\x0d\x0c\x06\x04\x14", run(s, "GET", "dest"))
+	assert.EqualValues(t, 6, run(s, "BITOP", "NOT", "dest", "a"))
+	assert.EqualValues(t, 26, run(s, "BITCOUNT", "a"))
+	assert.EqualValues(t, 48-26, run(s, "BITCOUNT", "dest"))
+
+	run(s, "EXPIRE", "dest", "100")
+	assert.EqualValues(t, 0, run(s, "BITOP", "AND", "dest", "missing"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "dest"))
+	assert.EqualValues(t, "ERR BITOP NOT must be called with a single source key.", run(s, "BITOP", "NOT", "dest", "a", "b"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "BITOP", "NAND", "dest", "a"))
+}
+
+func TestBITFIELD(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, []interface{}{int64(1), int64(0)}, run(s, "BITFIELD", "k", "INCRBY", "i5", "100", "1", "GET", "u4", "0"))
+	assert.EqualValues(t, []interface{}{int64(0), int64(100), int64(100)},
+		run(s, "BITFIELD", "n", "SET", "i8", "#1", "100", "GET", "i8", "8", "get", "u8", "#1"))
+	assert.EqualValues(t, []interface{}{int64(100), int64(-56)}, run(s, "BITFIELD", "n", "SET", "u8", "8", "200", "GET", "i8", "#1"))
+
+	// GET does not create the key, GET past the end of the value reads zero bits
+	assert.EqualValues(t, []interface{}{int64(0)}, run(s, "BITFIELD", "missing", "GET", "u8", "100"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "missing"))
+	assert.EqualValues(t, []interface{}{}, run(s, "BITFIELD", "missing"))
+
+	run(s, "SET", "hello", "Hello world")
+	assert.EqualValues(t, []interface{}{int64(108)}, run(s, "BITFIELD_RO", "hello", "GET", "i8", "16"))
+	assert.EqualValues(t, "ERR BITFIELD_RO only supports the GET subcommand", run(s, "BITFIELD_RO", "hello", "SET", "i8", "16", "1"))
+
+	assert.EqualValues(t, "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.",
+		run(s, "BITFIELD", "k", "GET", "u64", "0"))
+	assert.EqualValues(t, "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.",
+		run(s, "BITFIELD", "k", "GET", "i0", "0"))
+	assert.EqualValues(t, "ERR bit offset is not an integer or out of range", run(s, "BITFIELD", "k", "GET", "u8", "-1"))
+	assert.EqualValues(t, "ERR bit offset is not an integer or out of range", run(s, "BITFIELD", "k", "GET", "u8", "#536870912"))
+	// the multiplied offset must not wrap around
+	assert.EqualValues(t, "ERR bit offset is not an integer or out of range", run(s, "BITFIELD", "b", "SET", "u8", "#2305843009213693952", "255"))
+	assert.EqualValues(t, "ERR bit offset is not an integer or out of range", run(s, "BITFIELD", "b", "GET", "i64", "#-9223372036854775808"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "b"))
+	assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "BITFIELD", "k", "SET", "u8", "0", "x"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "BITFIELD", "k", "SET", "u8", "0"))
+	assert.EqualValues(t, "ERR Invalid OVERFLOW type specified", run(s, "BITFIELD", "k", "OVERFLOW", "CLAMP"))
+	run(s, "SADD", "set", "a")
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", run(s, "BITFIELD", "set", "GET", "u8", "0"))
+}
+
+func TestBITFIELD_Overflow(t *testing.T) {
+	s := core.NewStorage()
+	// the example of the Redis documentation, WRAP is the default
+	replies := [][]interface{}{{int64(1), int64(1)}, {int64(2), int64(2)}, {int64(3), int64(3)}, {int64(0), int64(3)}}
+	for _, want := range replies {
+		assert.EqualValues(t, want, run(s, "BITFIELD", "k", "INCRBY", "u2", "100", "1", "OVERFLOW", "SAT", "INCRBY", "u2", "102", "1"))
+	}
+
+	assert.EqualValues(t, []interface{}{int64(-128), int64(127), nil, int64(127)},
+		run(s, "BITFIELD", "i", "INCRBY", "i8", "0", "-128", "OVERFLOW", "SAT", "INCRBY", "i8", "0", "1000",
+			"OVERFLOW", "FAIL", "INCRBY", "i8", "0", "1", "GET", "i8", "0"))
+	// the value of SET is subject to the overflow behavior too
+	assert.EqualValues(t, []interface{}{int64(0), int64(0), nil, int64(255), int64(255)},
+		run(s, "BITFIELD", "u", "SET", "u8", "0", "256", "OVERFLOW", "SAT", "SET", "u8", "0", "-1",
+			"OVERFLOW", "FAIL", "SET", "u8", "0", "300", "OVERFLOW", "WRAP", "SET", "u8", "0", "511", "GET", "u8", "0"))
+	assert.EqualValues(t, []interface{}{int64(-1)}, run(s, "BITFIELD", "u", "GET", "i8", "0"))
+
+	// i64 and u63 wrap and saturate at the bounds of int64
+	assert.EqualValues(t, []interface{}{int64(math.MaxInt64), int64(math.MinInt64)},
+		run(s, "BITFIELD", "w", "INCRBY", "i64", "0", "9223372036854775807", "INCRBY", "i64", "0", "1"))
+	assert.EqualValues(t, []interface{}{int64(math.MinInt64)}, run(s, "BITFIELD", "w", "OVERFLOW", "SAT", "INCRBY", "i64", "0", "-1"))
+	assert.EqualValues(t, []interface{}{int64(math.MaxInt64), int64(0), int64(0), int64(0)},
+		run(s, "BITFIELD", "v", "INCRBY", "u63", "0", "9223372036854775807", "INCRBY", "u63", "0", "1",
+			"OVERFLOW", "SAT", "INCRBY", "u63", "0", "-1", "INCRBY", "u63", "0", "-9223372036854775808"))
+}
@@ -0,0 +1,445 @@
+package core
+
+import (
+	"Nietzsche/internal/constant"
+	"Nietzsche/internal/data_structure"
+	"Nietzsche/internal/glob"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// delKey removes the key, it reports whether the key existed
+func (s *Storage) delKey(key string) bool {
+	return s.keyExists(key) && s.dictStore.Del(key)
+}
+
+// keyExists reports whether the key holds a value of any type that has not expired
+func (s *Storage) keyExists(key string) bool {
+	return s.dictStore.Get(key) != nil
+}
+
+// AnyKeyExists reports whether at least one of the keys exists.
+// It is used by the coordinator to check the keys of MSETNX owned by several workers.
+func (s *Storage) AnyKeyExists(keys []string) bool {
+	for _, key := range keys {
+		if s.keyExists(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// dbSize returns the number of keys of every type
+func (s *Storage) dbSize() int64 {
+	return s.dictStore.Stat().Key
+}
+
+func (s *Storage) cmdDEL(args []string) []byte {
+	count := 0
+	for _, key := range args {
+		if s.delKey(key) {
+			count++
+		}
+	}
+	return Encode(count, false)
+}
+
+// countExisting returns the number of keys that exist, a key given twice is counted twice
+func (s *Storage) countExisting(keys []string) int {
+	count := 0
+	for _, key := range keys {
+		if s.keyExists(key) {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Storage) cmdEXISTS(args []string) []byte {
+	return Encode(s.countExisting(args), false)
+}
+
+// cmdTOUCH counts the existing keys like EXISTS, looking them up updates their last access time
+func (s *Storage) cmdTOUCH(args []string) []byte {
+	return Encode(s.countExisting(args), false)
+}
+
+func (s *Storage) cmdTYPE(args []string) []byte {
+	obj := s.dictStore.Get(args[0])
+	if obj == nil {
+		return Encode("none", true)
+	}
+	return Encode(obj.TypeName(), true)
+}
+
+// moveKey moves the value and the expiry of key to newKey, replacing the value of newKey if any
+func (s *Storage) moveKey(key, newKey string) {
+	obj := s.dictStore.Get(key)
+	expireAt, isExpirySet := s.dictStore.GetExpiry(key)
+	s.dictStore.Del(key)
+	s.dictStore.Del(newKey)
+	s.dictStore.Set(newKey, obj)
+	if isExpirySet {
+		s.dictStore.SetExpiryAt(newKey, expireAt)
+	}
+}
+
+func (s *Storage) cmdRENAME(args []string) []byte {
+	key, newKey := args[0], args[1]
+	if !s.keyExists(key) {
+		return Encode(errNoSuchKey, false)
+	}
+	if key != newKey {
+		s.moveKey(key, newKey)
+	}
+	return constant.RespOk
+}
+
+func (s *Storage) cmdRENAMENX(args []string) []byte {
+	key, newKey := args[0], args[1]
+	if !s.keyExists(key) {
+		return Encode(errNoSuchKey, false)
+	}
+	if s.keyExists(newKey) {
+		return constant.RespZero
+	}
+	s.moveKey(key, newKey)
+	return constant.RespOne
+}
+
+// cmdCOPY copies the value and the expiry of source to destination,
+// only the database 0 exists so DB can only be 0
+func (s *Storage) cmdCOPY(args []string) []byte {
+	source, destination := args[0], args[1]
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			if i+1 == len(args) {
+				return Encode(errSyntax, false)
+			}
+			db, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return Encode(errNotInteger, false)
+			}
+			if db != 0 {
+				return Encode(errors.New("ERR DB index is out of range"), false)
+			}
+			i++
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	if source == destination {
+		return Encode(errors.New("ERR source and destination objects are the same"), false)
+	}
+
+	obj := s.dictStore.Get(source)
+	if obj == nil {
+		return constant.RespZero
+	}
+	if s.keyExists(destination) {
+		if !replace {
+			return constant.RespZero
+		}
+		s.dictStore.Del(destination)
+	}
+	s.dictStore.Set(destination, obj.Clone())
+	if expireAt, isExpirySet := s.dictStore.GetExpiry(source); isExpirySet {
+		s.dictStore.SetExpiryAt(destination, expireAt)
+	}
+	return constant.RespOne
+}
+
+// cmdKEYS returns the keys matching the pattern. It iterates over the whole keyspace,
+// unless the pattern matches a single key.
+func (s *Storage) cmdKEYS(args []string) []byte {
+	pattern := args[0]
+	keys := make([]string, 0)
+	if prefix, literal := glob.LiteralPrefix(pattern); literal {
+		if s.dictStore.Peek(prefix) != nil {
+			keys = append(keys, prefix)
+		}
+		return Encode(keys, false)
+	}
+	all := pattern == "*"
+	for key := range s.dictStore.GetDictStore() {
+		// like SCAN, KEYS does not count as an access but deletes the expired keys
+		if (all || glob.Match(pattern, key)) && s.dictStore.Peek(key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	return Encode(keys, false)
+}
+
+// scanOptions are the options of SCAN cursor [MATCH pattern] [COUNT count] [TYPE type],
+// SSCAN, ZSCAN and HSCAN have the same options except TYPE, and HSCAN also accepts NOVALUES
+type scanOptions struct {
+	cursor   uint64
+	pattern  string // "" matches everything
+	count    int
+	objType  int  // -1 for any type
+	noValues bool // HSCAN only returns the fields
+}
+
+// parseScanOptions parses the cursor and the options of the SCAN commands. extra is the option
+// specific to the command, TYPE for SCAN and NOVALUES for HSCAN, "" if there is none.
+func parseScanOptions(args []string, extra string) (*scanOptions, error) {
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	opts := &scanOptions{cursor: cursor, count: 10, objType: -1}
+	for i := 1; i < len(args); i += 2 {
+		option := strings.ToUpper(args[i])
+		if option == "NOVALUES" && extra == option {
+			opts.noValues = true
+			i--
+			continue
+		}
+		if i+1 == len(args) {
+			return nil, errSyntax
+		}
+		switch option {
+		case "MATCH":
+			opts.pattern = args[i+1]
+			if opts.pattern == "*" {
+				opts.pattern = ""
+			}
+		case "COUNT":
+			count, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return nil, errNotInteger
+			}
+			if count < 1 {
+				return nil, errSyntax
+			}
+			opts.count = int(min(count, math.MaxInt32))
+		case "TYPE":
+			if extra != option {
+				return nil, errSyntax
+			}
+			objType, ok := data_structure.ObjTypeByName(args[i+1])
+			if !ok {
+				return nil, fmt.Errorf("ERR unknown type name '%s'", args[i+1])
+			}
+			opts.objType = int(objType)
+		default:
+			return nil, errSyntax
+		}
+	}
+	return opts, nil
+}
+
+func (opts *scanOptions) match(s string) bool {
+	return opts.pattern == "" || glob.Match(opts.pattern, s)
+}
+
+// encodeScanReply encodes the reply of the SCAN commands, the cursor is sent as a bulk string
+func encodeScanReply(cursor uint64, elements []string) []byte {
+	return Encode([]interface{}{strconv.FormatUint(cursor, 10), elements}, false)
+}
+
+// cmdSCAN returns the keys from the cursor. The cursor is the hash of the keys to resume from,
+// see data_structure.ScanIndex, so the keys that exist during the whole iteration are returned
+// at least once, and a call costs O(count) whatever the size of the keyspace.
+func (s *Storage) cmdSCAN(args []string) []byte {
+	opts, err := parseScanOptions(args, "TYPE")
+	if err != nil {
+		return Encode(err, false)
+	}
+	keys, next := s.dictStore.Scan(opts.cursor, opts.count)
+	res := make([]string, 0, len(keys))
+	for _, key := range keys {
+		// scanning does not count as an access for the LRU eviction, but deletes the expired keys
+		obj := s.dictStore.Peek(key)
+		if obj == nil || (opts.objType >= 0 && int(obj.Type) != opts.objType) || !opts.match(key) {
+			continue
+		}
+		res = append(res, key)
+	}
+	return encodeScanReply(next, res)
+}
+
+// cmdRANDOMKEY returns a key that has not expired, or nil if the keyspace is empty
+func (s *Storage) cmdRANDOMKEY(args []string) []byte {
+	// the iteration order of a map is random, expired keys met on the way are deleted
+	for key := range s.dictStore.GetDictStore() {
+		if s.keyExists(key) {
+			return Encode(key, false)
+		}
+	}
+	return constant.RespNil
+}
+
+func (s *Storage) cmdDBSIZE(args []string) []byte {
+	return Encode(s.dbSize(), false)
+}
+
+func (s *Storage) cmdFLUSHALL(args []string) []byte {
+	if len(args) > 1 || (len(args) == 1 && strings.ToUpper(args[0]) != "SYNC" && strings.ToUpper(args[0]) != "ASYNC") {
+		return Encode(errSyntax, false)
+	}
+	// the clients blocked on the keys keep waiting for them
+	s.dictStore = data_structure.CreateDict()
+	return Encode("OK", true)
+}
+
+// expiry returns the expiry of the key as a unix time in milliseconds.
+// reply is set instead if the key does not exist or has no expiry.
+func (s *Storage) expiry(key string) (expireAt int64, reply []byte) {
+	if !s.keyExists(key) {
+		return 0, constant.TtlKeyNotExist
+	}
+	exp, isExpirySet := s.dictStore.GetExpiry(key)
+	if !isExpirySet {
+		return 0, constant.TtlKeyExistNoExpire
+	}
+	return int64(exp), nil
+}
+
+// ttl implements TTL and PTTL, unitMs is the number of milliseconds in the unit of the reply
+func (s *Storage) ttl(key string, unitMs int64) []byte {
+	expireAt, reply := s.expiry(key)
+	if reply != nil {
+		return reply
+	}
+	remainMs := max(0, expireAt-time.Now().UnixMilli())
+	return Encode((remainMs+unitMs/2)/unitMs, false)
+}
+
+func (s *Storage) cmdTTL(args []string) []byte {
+	return s.ttl(args[0], 1000)
+}
+
+func (s *Storage) cmdPTTL(args []string) []byte {
+	return s.ttl(args[0], 1)
+}
+
+// expireTime implements EXPIRETIME and PEXPIRETIME, unitMs is the number of milliseconds in the unit of the reply
+func (s *Storage) expireTime(key string, unitMs int64) []byte {
+	expireAt, reply := s.expiry(key)
+	if reply != nil {
+		return reply
+	}
+	return Encode(expireAt/unitMs, false)
+}
+
+func (s *Storage) cmdEXPIRETIME(args []string) []byte {
+	return s.expireTime(args[0], 1000)
+}
+
+func (s *Storage) cmdPEXPIRETIME(args []string) []byte {
+	return s.expireTime(args[0], 1)
+}
+
+// expireOptions are the options of EXPIRE key time [NX | XX | GT | LT]
+type expireOptions struct {
+	nx, xx, gt, lt bool
+}
+
+func parseExpireOptions(args []string) (*expireOptions, error) {
+	opts := &expireOptions{}
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "NX":
+			opts.nx = true
+		case "XX":
+			opts.xx = true
+		case "GT":
+			opts.gt = true
+		case "LT":
+			opts.lt = true
+		default:
+			return nil, fmt.Errorf("ERR Unsupported option %s", arg)
+		}
+	}
+	if opts.nx && (opts.xx || opts.gt || opts.lt) {
+		return nil, errors.New("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if opts.gt && opts.lt {
+		return nil, errors.New("ERR GT and LT options at the same time are not compatible")
+	}
+	return opts, nil
+}
+
+// allow reports whether the options allow to replace the expiry current of a key by expireAt,
+// current is -1 if the key has no expiry, which is treated as an infinite time to live
+func (opts *expireOptions) allow(current, expireAt int64) bool {
+	switch {
+	case opts.nx:
+		return current == -1
+	case opts.xx && current == -1:
+		return false
+	case opts.gt:
+		return current != -1 && expireAt > current
+	case opts.lt:
+		return current == -1 || expireAt < current
+	}
+	return true
+}
+
+// expireGeneric implements EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT key time [NX | XX | GT | LT].
+// option is the SET option with the same unit as time: EX, PX, EXAT or PXAT.
+// A time in the past deletes the key.
+func (s *Storage) expireGeneric(args []string, option, name string) []byte {
+	key := args[0]
+	opts, err := parseExpireOptions(args[2:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	expireAt, ok := toUnixMilli(option, n)
+	if !ok {
+		return Encode(errInvalidExpireTime(name), false)
+	}
+
+	if !s.keyExists(key) {
+		return constant.RespZero
+	}
+	current := int64(-1)
+	if exp, isExpirySet := s.dictStore.GetExpiry(key); isExpirySet {
+		current = int64(exp)
+	}
+	if !opts.allow(current, expireAt) {
+		return constant.RespZero
+	}
+	if expireAt <= time.Now().UnixMilli() {
+		s.delKey(key)
+		return constant.RespOne
+	}
+	s.dictStore.SetExpiryAt(key, uint64(expireAt))
+	return constant.RespOne
+}
+
+func (s *Storage) cmdEXPIRE(args []string) []byte {
+	return s.expireGeneric(args, "EX", "expire")
+}
+
+func (s *Storage) cmdPEXPIRE(args []string) []byte {
+	return s.expireGeneric(args, "PX", "pexpire")
+}
+
+func (s *Storage) cmdEXPIREAT(args []string) []byte {
+	return s.expireGeneric(args, "EXAT", "expireat")
+}
+
+func (s *Storage) cmdPEXPIREAT(args []string) []byte {
+	return s.expireGeneric(args, "PXAT", "pexpireat")
+}
+
+func (s *Storage) cmdPERSIST(args []string) []byte {
+	if !s.keyExists(args[0]) || !s.dictStore.DelExpiry(args[0]) {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
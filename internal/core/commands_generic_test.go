@@ -0,0 +1,301 @@
+package core_test
+
+import (
+	"Nietzsche/internal/core"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEXPIRE_Variants(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, 0, run(s, "EXPIRE", "k", "10"))
+	run(s, "SET", "k", "v")
+	assert.EqualValues(t, -1, run(s, "TTL", "k"))
+	assert.EqualValues(t, -1, run(s, "EXPIRETIME", "k"))
+
+	assert.EqualValues(t, 1, run(s, "EXPIRE", "k", "10"))
+	assert.EqualValues(t, 10, run(s, "TTL", "k"))
+	assert.InDelta(t, 10000, run(s, "PTTL", "k"), 100)
+
+	assert.EqualValues(t, 1, run(s, "PEXPIRE", "k", "20000"))
+	assert.EqualValues(t, 20, run(s, "TTL", "k"))
+
+	at := time.Now().Unix() + 100
+	assert.EqualValues(t, 1, run(s, "EXPIREAT", "k", strconv.FormatInt(at, 10)))
+	assert.EqualValues(t, at, run(s, "EXPIRETIME", "k"))
+	assert.EqualValues(t, at*1000, run(s, "PEXPIRETIME", "k"))
+
+	assert.EqualValues(t, 1, run(s, "PEXPIREAT", "k", strconv.FormatInt(at*1000+500, 10)))
+	assert.EqualValues(t, at*1000+500, run(s, "PEXPIRETIME", "k"))
+
+	assert.EqualValues(t, 1, run(s, "PERSIST", "k"))
+	assert.EqualValues(t, 0, run(s, "PERSIST", "k"))
+	assert.EqualValues(t, -1, run(s, "PTTL", "k"))
+	assert.EqualValues(t, 0, run(s, "PERSIST", "missing"))
+	assert.EqualValues(t, -2, run(s, "PTTL", "missing"))
+	assert.EqualValues(t, -2, run(s, "PEXPIRETIME", "missing"))
+}
+
+func TestEXPIRE_PastTimeDeletesKey(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "k", "v")
+	assert.EqualValues(t, 1, run(s, "EXPIRE", "k", "-1"))
+	assert.Nil(t, run(s, "GET", "k"))
+	assert.EqualValues(t, 0, run(s, "DBSIZE"))
+
+	run(s, "SET", "k", "v")
+	assert.EqualValues(t, 1, run(s, "EXPIREAT", "k", "1"))
+	assert.EqualValues(t, -2, run(s, "TTL", "k"))
+}
+
+func TestEXPIRE_ExpiredKey(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "k", "v", "PX", "1")
+	time.Sleep(5 * time.Millisecond)
+	assert.EqualValues(t, -2, run(s, "TTL", "k"))
+	assert.EqualValues(t, -2, run(s, "PTTL", "k"))
+	assert.EqualValues(t, 0, run(s, "EXPIRE", "k", "10"))
+}
+
+func TestEXPIRE_Conditions(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "k", "v")
+	assert.EqualValues(t, 0, run(s, "EXPIRE", "k", "10", "XX"))
+	assert.EqualValues(t, 0, run(s, "EXPIRE", "k", "10", "GT"))
+	assert.EqualValues(t, 1, run(s, "EXPIRE", "k", "10", "NX"))
+	assert.EqualValues(t, 0, run(s, "EXPIRE", "k", "20", "NX"))
+	assert.EqualValues(t, 0, run(s, "EXPIRE", "k", "5", "GT"))
+	assert.EqualValues(t, 1, run(s, "EXPIRE", "k", "20", "GT"))
+	assert.EqualValues(t, 0, run(s, "EXPIRE", "k", "30", "LT"))
+	assert.EqualValues(t, 1, run(s, "EXPIRE", "k", "5", "XX", "LT"))
+	assert.EqualValues(t, 5, run(s, "TTL", "k"))
+
+	run(s, "PERSIST", "k")
+	assert.EqualValues(t, 1, run(s, "EXPIRE", "k", "100", "LT"))
+
+	assert.EqualValues(t, "ERR NX and XX, GT or LT options at the same time are not compatible",
+		run(s, "EXPIRE", "k", "10", "NX", "GT"))
+	assert.EqualValues(t, "ERR GT and LT options at the same time are not compatible",
+		run(s, "EXPIRE", "k", "10", "GT", "LT"))
+	assert.EqualValues(t, "ERR Unsupported option foo", run(s, "EXPIRE", "k", "10", "foo"))
+	assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "EXPIRE", "k", "ten"))
+	assert.EqualValues(t, "ERR invalid expire time in 'expire' command", run(s, "EXPIRE", "k", "9223372036854775807"))
+}
+
+func TestINFO_Expires(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "a", "1", "EX", "100")
+	run(s, "SET", "b", "2")
+	run(s, "EXPIRE", "b", "100")
+	run(s, "SET", "c", "3")
+	assert.Contains(t, run(s, "INFO"), "db0:keys=3,expires=2,")
+
+	run(s, "PERSIST", "a")
+	run(s, "SET", "b", "4")
+	assert.Contains(t, run(s, "INFO"), "db0:keys=3,expires=0,")
+
+	run(s, "EXPIRE", "c", "100")
+	run(s, "DEL", "c")
+	assert.Contains(t, run(s, "INFO"), "db0:keys=2,expires=0,")
+}
+
+func TestTypedKeyspace(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "str", "v")
+	run(s, "SADD", "set", "a")
+	run(s, "ZADD", "zset", "1", "a")
+	run(s, "CMS.INITBYDIM", "cms", "10", "2")
+	run(s, "BF.MADD", "bf", "x")
+	for key, typeName := range map[string]string{"str": "string", "set": "set", "zset": "zset",
+		"cms": "CMSk-TYPE", "bf": "MBbloom--", "missing": "none"} {
+		assert.EqualValues(t, typeName, run(s, "TYPE", key), key)
+	}
+	assert.EqualValues(t, 5, run(s, "DBSIZE"))
+	assert.EqualValues(t, 3, run(s, "EXISTS", "str", "set", "missing", "set"))
+	assert.EqualValues(t, 2, run(s, "TOUCH", "zset", "bf", "missing"))
+
+	wrongType := "WRONGTYPE Operation against a key holding the wrong kind of value"
+	assert.EqualValues(t, wrongType, run(s, "GET", "set"))
+	assert.EqualValues(t, wrongType, run(s, "INCR", "set"))
+	assert.EqualValues(t, wrongType, run(s, "APPEND", "zset", "x"))
+	assert.EqualValues(t, wrongType, run(s, "SADD", "str", "a"))
+	assert.EqualValues(t, wrongType, run(s, "SINTER", "set", "str"))
+	assert.EqualValues(t, wrongType, run(s, "ZADD", "set", "1", "a"))
+	assert.EqualValues(t, wrongType, run(s, "ZSCORE", "str", "a"))
+	assert.EqualValues(t, wrongType, run(s, "CMS.QUERY", "bf", "x"))
+	assert.EqualValues(t, wrongType, run(s, "BF.EXISTS", "cms", "x"))
+	assert.EqualValues(t, "CMS: key already exists", run(s, "CMS.INITBYDIM", "str", "10", "2"))
+	assert.EqualValues(t, []interface{}{"v", nil}, run(s, "MGET", "str", "set"))
+
+	// SET replaces a value of any type
+	assert.EqualValues(t, "OK", run(s, "SET", "set", "v"))
+	assert.EqualValues(t, "string", run(s, "TYPE", "set"))
+	assert.EqualValues(t, 2, run(s, "UNLINK", "set", "zset", "missing"))
+	assert.EqualValues(t, 3, run(s, "DBSIZE"))
+}
+
+func TestSREM_DeletesEmptySet(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, 0, run(s, "SREM", "s", "a"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "s"))
+	run(s, "SADD", "s", "a", "b")
+	assert.EqualValues(t, 2, run(s, "SREM", "s", "a", "b"))
+	assert.EqualValues(t, "none", run(s, "TYPE", "s"))
+}
+
+func TestZADD_FailedCreatesNoKey(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, "(error) Score must be floating point number", run(s, "ZADD", "z", "1", "a", "notafloat", "b"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "z"))
+	assert.EqualValues(t, "none", run(s, "TYPE", "z"))
+	assert.Empty(t, run(s, "KEYS", "*"))
+
+	run(s, "ZADD", "z", "1", "a")
+	assert.EqualValues(t, "(error) Score must be floating point number", run(s, "ZADD", "z", "2", "b", "x", "c"))
+	assert.EqualValues(t, []interface{}{"0", []interface{}{"a", "1.000000"}}, run(s, "ZSCAN", "z", "0"),
+		"nothing is added by a failed command")
+}
+
+func TestRENAME(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, "ERR no such key", run(s, "RENAME", "a", "b"))
+	run(s, "SADD", "a", "x")
+	run(s, "EXPIRE", "a", "100")
+	run(s, "SET", "b", "v")
+	assert.EqualValues(t, "OK", run(s, "RENAME", "a", "b"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "a"))
+	assert.EqualValues(t, "set", run(s, "TYPE", "b"))
+	assert.EqualValues(t, 100, run(s, "TTL", "b"))
+	assert.EqualValues(t, "OK", run(s, "RENAME", "b", "b"))
+	assert.Contains(t, run(s, "INFO"), "db0:keys=1,expires=1,")
+
+	run(s, "SET", "c", "v")
+	assert.EqualValues(t, 0, run(s, "RENAMENX", "b", "c"))
+	assert.EqualValues(t, 1, run(s, "RENAMENX", "b", "d"))
+	assert.EqualValues(t, []interface{}{"x"}, run(s, "SMEMBERS", "d"))
+	assert.EqualValues(t, "ERR no such key", run(s, "RENAMENX", "b", "e"))
+}
+
+func TestCOPY(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SADD", "src", "a")
+	run(s, "EXPIRE", "src", "100")
+	assert.EqualValues(t, 1, run(s, "COPY", "src", "dst"))
+	assert.EqualValues(t, 100, run(s, "TTL", "dst"))
+	run(s, "SADD", "dst", "b")
+	assert.EqualValues(t, []interface{}{"a"}, run(s, "SMEMBERS", "src"))
+
+	run(s, "SET", "str", "v")
+	assert.EqualValues(t, 0, run(s, "COPY", "str", "dst"))
+	assert.EqualValues(t, 1, run(s, "COPY", "str", "dst", "DB", "0", "REPLACE"))
+	assert.EqualValues(t, "v", run(s, "GET", "dst"))
+	assert.EqualValues(t, -1, run(s, "TTL", "dst"))
+	run(s, "APPEND", "dst", "w")
+	assert.EqualValues(t, "v", run(s, "GET", "str"))
+
+	assert.EqualValues(t, 0, run(s, "COPY", "missing", "dst"))
+	assert.EqualValues(t, "ERR source and destination objects are the same", run(s, "COPY", "str", "str"))
+	assert.EqualValues(t, "ERR DB index is out of range", run(s, "COPY", "str", "x", "DB", "1"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "COPY", "str", "x", "FOO"))
+}
+
+func TestRANDOMKEY(t *testing.T) {
+	s := core.NewStorage()
+	assert.Nil(t, run(s, "RANDOMKEY"))
+	run(s, "SET", "a", "1")
+	run(s, "SADD", "b", "x")
+	assert.Contains(t, []interface{}{"a", "b"}, run(s, "RANDOMKEY"))
+}
+
+// scanAll runs a SCAN command until the cursor is 0 and returns all the elements,
+// key is "" for SCAN
+func scanAll(t *testing.T, s *core.Storage, cmd, key string, options ...string) []string {
+	var all []string
+	cursor := "0"
+	for {
+		var args []string
+		if key != "" {
+			args = append(args, key)
+		}
+		reply := run(s, cmd, append(append(args, cursor), options...)...)
+		res, ok := reply.([]interface{})
+		if !assert.True(t, ok, reply) {
+			return nil
+		}
+		for _, ele := range res[1].([]interface{}) {
+			all = append(all, ele.(string))
+		}
+		if cursor = res[0].(string); cursor == "0" {
+			return all
+		}
+	}
+}
+
+func TestSCAN(t *testing.T) {
+	s := core.NewStorage()
+	for i := 0; i < 100; i++ {
+		run(s, "SET", "user:"+strconv.Itoa(i), "v")
+	}
+	run(s, "SADD", "user:set", "a")
+	run(s, "SET", "other", "v")
+
+	keys := scanAll(t, s, "SCAN", "", "COUNT", "7")
+	assert.Len(t, keys, 102)
+	assert.ElementsMatch(t, []string{"user:10", "user:11", "user:12", "user:13", "user:14",
+		"user:15", "user:16", "user:17", "user:18", "user:19"}, scanAll(t, s, "SCAN", "", "MATCH", "user:1?"))
+	assert.ElementsMatch(t, []string{"user:1"}, scanAll(t, s, "SCAN", "", "MATCH", "user:1", "COUNT", "1000"))
+	assert.ElementsMatch(t, []string{"user:set"}, scanAll(t, s, "SCAN", "", "TYPE", "SET"))
+	assert.ElementsMatch(t, []string{"user:set", "other"}, scanAll(t, s, "SCAN", "", "MATCH", "[!-9o-z]*[^0-9]"))
+
+	assert.EqualValues(t, "ERR invalid cursor", run(s, "SCAN", "-1"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "SCAN", "0", "COUNT", "0"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "SCAN", "0", "MATCH"))
+	assert.EqualValues(t, "ERR unknown type name 'foo'", run(s, "SCAN", "0", "TYPE", "foo"))
+}
+
+func TestSCAN_SkipsExpiredKeys(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "a", "v", "PX", "1")
+	run(s, "SET", "b", "v")
+	time.Sleep(5 * time.Millisecond)
+	assert.EqualValues(t, []string{"b"}, scanAll(t, s, "SCAN", ""))
+	assert.EqualValues(t, 1, run(s, "DBSIZE"))
+}
+
+func TestSSCAN_ZSCAN(t *testing.T) {
+	s := core.NewStorage()
+	var members []string
+	for i := 0; i < 50; i++ {
+		members = append(members, "m"+strconv.Itoa(i))
+		run(s, "ZADD", "z", strconv.Itoa(i), "m"+strconv.Itoa(i))
+	}
+	run(s, "SADD", append([]string{"s"}, members...)...)
+	assert.ElementsMatch(t, members, scanAll(t, s, "SSCAN", "s", "COUNT", "3"))
+	assert.ElementsMatch(t, []string{"m4", "m40", "m41", "m42", "m43", "m44", "m45", "m46", "m47", "m48", "m49"},
+		scanAll(t, s, "SSCAN", "s", "MATCH", "m4*"))
+	assert.EqualValues(t, []string{"m7", "7.000000"}, scanAll(t, s, "ZSCAN", "z", "MATCH", "m7"))
+	assert.Len(t, scanAll(t, s, "ZSCAN", "z"), 100)
+
+	assert.Empty(t, scanAll(t, s, "SSCAN", "missing"))
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", run(s, "SSCAN", "z", "0"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "SSCAN", "s", "0", "TYPE", "set"))
+}
+
+func TestKEYS(t *testing.T) {
+	s := core.NewStorage()
+	assert.Empty(t, run(s, "KEYS", "*"))
+	for _, key := range []string{"user:1", "user:2", "user:10", "order:1", "a*b"} {
+		run(s, "SET", key, "v")
+	}
+	run(s, "SET", "expired", "v", "PX", "1")
+	time.Sleep(5 * time.Millisecond)
+
+	assert.ElementsMatch(t, []interface{}{"user:1", "user:2", "user:10", "order:1", "a*b"}, run(s, "KEYS", "*"))
+	assert.ElementsMatch(t, []interface{}{"user:1", "user:2"}, run(s, "KEYS", "user:?"))
+	assert.ElementsMatch(t, []interface{}{"user:1", "order:1"}, run(s, "KEYS", "*[^0-9]1"))
+	assert.EqualValues(t, []interface{}{"a*b"}, run(s, "KEYS", `a\*b`))
+	assert.Empty(t, run(s, "KEYS", "expired"))
+	assert.EqualValues(t, 5, run(s, "DBSIZE"))
+}
@@ -0,0 +1,488 @@
+package core
+
+import (
+	"Nietzsche/internal/constant"
+	"Nietzsche/internal/data_structure"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getHashObj returns the hash object of the key, nil if it does not exist.
+// The expired fields of the hash are deleted first, and the key too if no field remains.
+func (s *Storage) getHashObj(key string) (*data_structure.Obj, error) {
+	obj, err := s.lookup(key, data_structure.ObjTypeHash)
+	if obj == nil {
+		return nil, err
+	}
+	hash := obj.Value.(*data_structure.Hash)
+	if hash.DelExpiredFields(uint64(time.Now().UnixMilli()), -1) > 0 && hash.Len() == 0 {
+		s.dictStore.Del(key)
+		return nil, nil
+	}
+	return obj, nil
+}
+
+// getHash returns the hash of the key, nil if it does not exist
+func (s *Storage) getHash(key string) (*data_structure.Hash, error) {
+	obj, err := s.getHashObj(key)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.Hash), nil
+}
+
+// hsetFields sets the field value pairs in the hash of obj, or in a new hash stored at key if obj
+// is nil. The time to live of the fields is removed unless keepTTL. It returns the number of new fields.
+func (s *Storage) hsetFields(key string, obj *data_structure.Obj, keepTTL bool, pairs ...string) int {
+	if obj == nil {
+		obj = data_structure.NewHashObj(data_structure.NewHash())
+		s.dictStore.Set(key, obj)
+	}
+	hash := obj.Value.(*data_structure.Hash)
+	added := 0
+	for i := 0; i < len(pairs); i += 2 {
+		var isNew bool
+		if keepTTL {
+			isNew = hash.SetKeepTTL(pairs[i], pairs[i+1])
+		} else {
+			isNew = hash.Set(pairs[i], pairs[i+1])
+		}
+		if isNew {
+			added++
+		}
+	}
+	// the hash is converted to a map once it grows past the listpack limits
+	obj.Encoding = hash.Encoding()
+	return added
+}
+
+func (s *Storage) cmdHSET(args []string) []byte {
+	if len(args)%2 != 1 {
+		return Encode(errWrongArity("hset"), false)
+	}
+	obj, err := s.getHashObj(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(s.hsetFields(args[0], obj, false, args[1:]...), false)
+}
+
+func (s *Storage) cmdHSETNX(args []string) []byte {
+	obj, err := s.getHashObj(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj != nil && obj.Value.(*data_structure.Hash).Exists(args[1]) {
+		return constant.RespZero
+	}
+	return Encode(s.hsetFields(args[0], obj, false, args[1], args[2]), false)
+}
+
+func (s *Storage) cmdHGET(args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespNil
+	}
+	value, ok := hash.Get(args[1])
+	if !ok {
+		return constant.RespNil
+	}
+	return Encode(value, false)
+}
+
+func (s *Storage) cmdHMGET(args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	values := make([][]byte, len(args)-1)
+	for i, field := range args[1:] {
+		values[i] = constant.RespNil
+		if hash == nil {
+			continue
+		}
+		if value, ok := hash.Get(field); ok {
+			values[i] = Encode(value, false)
+		}
+	}
+	return EncodeRawArray(values)
+}
+
+// cmdHDEL deletes the fields from the hash, the key is deleted once the hash is empty
+func (s *Storage) cmdHDEL(args []string) []byte {
+	key := args[0]
+	hash, err := s.getHash(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	count := 0
+	for _, field := range args[1:] {
+		if hash.Del(field) {
+			count++
+		}
+	}
+	if hash.Len() == 0 {
+		s.dictStore.Del(key)
+	}
+	return Encode(count, false)
+}
+
+func (s *Storage) cmdHEXISTS(args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil || !hash.Exists(args[1]) {
+		return constant.RespZero
+	}
+	return constant.RespOne
+}
+
+func (s *Storage) cmdHLEN(args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	return Encode(hash.Len(), false)
+}
+
+func (s *Storage) cmdHSTRLEN(args []string) []byte {
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if hash == nil {
+		return constant.RespZero
+	}
+	value, _ := hash.Get(args[1])
+	return Encode(len(value), false)
+}
+
+// hashContent returns the fields and/or the values of the hash of the key, as a flat array
+func (s *Storage) hashContent(key string, fields, values bool) []byte {
+	hash, err := s.getHash(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]string, 0)
+	if hash == nil {
+		return Encode(res, false)
+	}
+	hash.Range(func(field, value string) bool {
+		if fields {
+			res = append(res, field)
+		}
+		if values {
+			res = append(res, value)
+		}
+		return true
+	})
+	return Encode(res, false)
+}
+
+func (s *Storage) cmdHKEYS(args []string) []byte {
+	return s.hashContent(args[0], true, false)
+}
+
+func (s *Storage) cmdHVALS(args []string) []byte {
+	return s.hashContent(args[0], false, true)
+}
+
+func (s *Storage) cmdHGETALL(args []string) []byte {
+	return s.hashContent(args[0], true, true)
+}
+
+func (s *Storage) cmdHINCRBY(args []string) []byte {
+	key, field := args[0], args[1]
+	increment, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	obj, err := s.getHashObj(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current int64
+	if obj != nil {
+		if value, ok := obj.Value.(*data_structure.Hash).Get(field); ok {
+			current, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || strconv.FormatInt(current, 10) != value {
+				return Encode(errors.New("ERR hash value is not an integer"), false)
+			}
+		}
+	}
+	if (increment > 0 && current > math.MaxInt64-increment) || (increment < 0 && current < math.MinInt64-increment) {
+		return Encode(errors.New("ERR increment or decrement would overflow"), false)
+	}
+	current += increment
+	s.hsetFields(key, obj, true, field, strconv.FormatInt(current, 10))
+	return Encode(current, false)
+}
+
+func (s *Storage) cmdHINCRBYFLOAT(args []string) []byte {
+	key, field := args[0], args[1]
+	increment, ok := parseFloat(args[2])
+	if !ok {
+		return Encode(errors.New("ERR value is not a valid float"), false)
+	}
+	obj, err := s.getHashObj(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current float64
+	if obj != nil {
+		if value, exist := obj.Value.(*data_structure.Hash).Get(field); exist {
+			if current, ok = parseFloat(value); !ok {
+				return Encode(errors.New("ERR hash value is not a float"), false)
+			}
+		}
+	}
+	current += increment
+	if math.IsInf(current, 0) || math.IsNaN(current) {
+		return Encode(errors.New("ERR increment would produce NaN or Infinity"), false)
+	}
+	value := strconv.FormatFloat(current, 'f', -1, 64)
+	s.hsetFields(key, obj, true, field, value)
+	return Encode(value, false)
+}
+
+// cmdHRANDFIELD returns a random field, or with a count count distinct fields if it is positive,
+// and -count fields that may repeat if it is negative, optionally with their values
+func (s *Storage) cmdHRANDFIELD(args []string) []byte {
+	var count int64
+	withValues := false
+	if len(args) >= 2 {
+		var err error
+		if count, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return Encode(errNotInteger, false)
+		}
+		if len(args) > 3 || (len(args) == 3 && !strings.EqualFold(args[2], "WITHVALUES")) {
+			return Encode(errSyntax, false)
+		}
+		withValues = len(args) == 3
+		// like in Redis, the count is in -LONG_MAX..LONG_MAX so that it can be negated,
+		// halved with the values as the reply has 2 elements per field
+		limit := int64(math.MaxInt64)
+		if withValues {
+			limit /= 2
+		}
+		if count < -limit || count > limit {
+			return Encode(errors.New("ERR value is out of range"), false)
+		}
+	}
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if len(args) == 1 {
+		if hash == nil {
+			return constant.RespNil
+		}
+		return Encode(hash.RandomField(), false)
+	}
+
+	res := make([]string, 0)
+	if hash == nil || count == 0 {
+		return Encode(res, false)
+	}
+	var fields []string
+	if count > 0 {
+		fields = hash.RandomFields(int(min(count, int64(hash.Len()))), true)
+	} else {
+		fields = hash.RandomFields(int(-count), false)
+	}
+	for _, field := range fields {
+		res = append(res, field)
+		if withValues {
+			value, _ := hash.Get(field)
+			res = append(res, value)
+		}
+	}
+	return Encode(res, false)
+}
+
+func (s *Storage) cmdHSCAN(args []string) []byte {
+	opts, err := parseScanOptions(args[1:], "NOVALUES")
+	if err != nil {
+		return Encode(err, false)
+	}
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]string, 0)
+	if hash == nil {
+		return encodeScanReply(0, res)
+	}
+	fields, next := hash.Scan(opts.cursor, opts.count)
+	for _, field := range fields {
+		if opts.match(field) {
+			res = append(res, field)
+			if !opts.noValues {
+				value, _ := hash.Get(field)
+				res = append(res, value)
+			}
+		}
+	}
+	return encodeScanReply(next, res)
+}
+
+// maxFieldExpireAt is the latest expiry of a field in unix milliseconds, the one of Redis
+const maxFieldExpireAt = (1<<48 - 1) >> 2
+
+// parseFields parses the FIELDS numfields field [field ...] argument of the field expiry commands
+func parseFields(args []string) ([]string, error) {
+	if len(args) < 2 || !strings.EqualFold(args[0], "FIELDS") {
+		return nil, errors.New("ERR Mandatory argument FIELDS is missing or not at the right position")
+	}
+	numFields, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || numFields < 1 {
+		return nil, errors.New("ERR Parameter `numFields` should be greater than 0")
+	}
+	if numFields != int64(len(args)-2) {
+		return nil, errors.New("ERR The `numfields` parameter must match the number of arguments")
+	}
+	return args[2:], nil
+}
+
+// hexpireGeneric implements HEXPIRE, HPEXPIRE, HEXPIREAT and HPEXPIREAT, option is the SET option
+// with the same unit, see toUnixMilli. The reply has an integer per field:
+// -2 if the field does not exist, 0 if the condition is not met, 1 if the expiry is set
+// and 2 if the field is deleted because the expiry is in the past.
+func (s *Storage) hexpireGeneric(args []string, option, name string) []byte {
+	key := args[0]
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if n < 0 {
+		return Encode(errors.New("ERR invalid expire time, must be >= 0"), false)
+	}
+	expireAt, ok := toUnixMilli(option, n)
+	if !ok || expireAt > maxFieldExpireAt {
+		return Encode(errInvalidExpireTime(name), false)
+	}
+	opts := &expireOptions{}
+	rest := args[2:]
+	switch strings.ToUpper(rest[0]) {
+	case "NX", "XX", "GT", "LT":
+		opts, _ = parseExpireOptions(rest[:1])
+		rest = rest[1:]
+	}
+	fields, err := parseFields(rest)
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	res := make([]interface{}, len(fields))
+	hash, err := s.getHash(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	for i, field := range fields {
+		if hash == nil || !hash.Exists(field) {
+			res[i] = -2
+			continue
+		}
+		current := int64(-1)
+		if exp, isExpirySet := hash.FieldExpiry(field); isExpirySet {
+			current = int64(exp)
+		}
+		if !opts.allow(current, expireAt) {
+			res[i] = 0
+			continue
+		}
+		if expireAt <= time.Now().UnixMilli() {
+			hash.Del(field)
+			res[i] = 2
+			continue
+		}
+		hash.SetFieldExpiry(field, uint64(expireAt))
+		s.dictStore.AddVolatileHash(key)
+		res[i] = 1
+	}
+	if hash != nil && hash.Len() == 0 {
+		s.dictStore.Del(key)
+	}
+	return Encode(res, false)
+}
+
+func (s *Storage) cmdHEXPIRE(args []string) []byte {
+	return s.hexpireGeneric(args, "EX", "hexpire")
+}
+
+func (s *Storage) cmdHPEXPIRE(args []string) []byte {
+	return s.hexpireGeneric(args, "PX", "hpexpire")
+}
+
+func (s *Storage) cmdHEXPIREAT(args []string) []byte {
+	return s.hexpireGeneric(args, "EXAT", "hexpireat")
+}
+
+func (s *Storage) cmdHPEXPIREAT(args []string) []byte {
+	return s.hexpireGeneric(args, "PXAT", "hpexpireat")
+}
+
+// fieldsReply replies to HTTL, HPTTL and HPERSIST with the result of fn for each field,
+// or -2 if the field does not exist
+func (s *Storage) fieldsReply(args []string, fn func(hash *data_structure.Hash, field string) int64) []byte {
+	fields, err := parseFields(args[1:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	hash, err := s.getHash(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if hash == nil || !hash.Exists(field) {
+			res[i] = -2
+			continue
+		}
+		res[i] = fn(hash, field)
+	}
+	return Encode(res, false)
+}
+
+// fieldTTL implements HTTL and HPTTL like ttl, -1 is returned for a field without time to live
+func (s *Storage) fieldTTL(args []string, unitMs int64) []byte {
+	return s.fieldsReply(args, func(hash *data_structure.Hash, field string) int64 {
+		expireAt, isExpirySet := hash.FieldExpiry(field)
+		if !isExpirySet {
+			return -1
+		}
+		remainMs := max(0, int64(expireAt)-time.Now().UnixMilli())
+		return (remainMs + unitMs/2) / unitMs
+	})
+}
+
+func (s *Storage) cmdHTTL(args []string) []byte {
+	return s.fieldTTL(args, 1000)
+}
+
+func (s *Storage) cmdHPTTL(args []string) []byte {
+	return s.fieldTTL(args, 1)
+}
+
+// cmdHPERSIST removes the time to live of the fields, it replies 1 for the fields that had one and -1 for the others
+func (s *Storage) cmdHPERSIST(args []string) []byte {
+	return s.fieldsReply(args, func(hash *data_structure.Hash, field string) int64 {
+		if !hash.PersistField(field) {
+			return -1
+		}
+		return 1
+	})
+}
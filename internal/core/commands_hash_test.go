@@ -0,0 +1,210 @@
+package core_test
+
+import (
+	"Nietzsche/internal/config"
+	"Nietzsche/internal/core"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHSET_HGET(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, 2, run(s, "HSET", "h", "name", "nietzsche", "born", "1844"))
+	assert.EqualValues(t, 1, run(s, "HSET", "h", "name", "friedrich", "died", "1900"))
+	assert.EqualValues(t, "friedrich", run(s, "HGET", "h", "name"))
+	assert.Nil(t, run(s, "HGET", "h", "missing"))
+	assert.Nil(t, run(s, "HGET", "missing", "name"))
+	assert.EqualValues(t, []interface{}{"1844", nil, "1900"}, run(s, "HMGET", "h", "born", "missing", "died"))
+	assert.EqualValues(t, []interface{}{nil}, run(s, "HMGET", "missing", "born"))
+	assert.EqualValues(t, 3, run(s, "HLEN", "h"))
+	assert.EqualValues(t, 1, run(s, "HEXISTS", "h", "born"))
+	assert.EqualValues(t, 0, run(s, "HEXISTS", "h", "1844"))
+	assert.EqualValues(t, 9, run(s, "HSTRLEN", "h", "name"))
+	assert.EqualValues(t, 0, run(s, "HSTRLEN", "h", "missing"))
+	assert.EqualValues(t, "hash", run(s, "TYPE", "h"))
+	assert.EqualValues(t, "ERR wrong number of arguments for 'hset' command", run(s, "HSET", "h", "name", "a", "born"))
+
+	// a small hash keeps the insertion order
+	assert.EqualValues(t, []interface{}{"name", "born", "died"}, run(s, "HKEYS", "h"))
+	assert.EqualValues(t, []interface{}{"friedrich", "1844", "1900"}, run(s, "HVALS", "h"))
+	assert.EqualValues(t, []interface{}{"name", "friedrich", "born", "1844", "died", "1900"}, run(s, "HGETALL", "h"))
+	assert.EqualValues(t, []interface{}{}, run(s, "HGETALL", "missing"))
+
+	assert.EqualValues(t, 0, run(s, "HSETNX", "h", "name", "other"))
+	assert.EqualValues(t, 1, run(s, "HSETNX", "h", "country", "prussia"))
+	assert.EqualValues(t, "friedrich", run(s, "HGET", "h", "name"))
+
+	run(s, "SET", "str", "v")
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", run(s, "HSET", "str", "f", "v"))
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", run(s, "HGETALL", "str"))
+}
+
+func TestHDEL_DeletesEmptyHash(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "HSET", "h", "a", "1", "b", "2")
+	assert.EqualValues(t, 1, run(s, "HDEL", "h", "a", "missing"))
+	assert.EqualValues(t, 0, run(s, "HDEL", "missing", "a"))
+	assert.EqualValues(t, 1, run(s, "EXISTS", "h"))
+	assert.EqualValues(t, 1, run(s, "HDEL", "h", "b"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "h"))
+}
+
+func TestHINCRBY(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, 5, run(s, "HINCRBY", "h", "n", "5"))
+	assert.EqualValues(t, -5, run(s, "HINCRBY", "h", "n", "-10"))
+	run(s, "HSET", "h", "big", "9223372036854775807", "str", "x", "f", "1.5")
+	assert.EqualValues(t, "ERR increment or decrement would overflow", run(s, "HINCRBY", "h", "big", "1"))
+	assert.EqualValues(t, "ERR hash value is not an integer", run(s, "HINCRBY", "h", "str", "1"))
+	assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "HINCRBY", "h", "n", "x"))
+
+	assert.EqualValues(t, "2", run(s, "HINCRBYFLOAT", "h", "f", "0.5"))
+	assert.EqualValues(t, "-4.9", run(s, "HINCRBYFLOAT", "h", "n", "0.1"))
+	assert.EqualValues(t, "0.25", run(s, "HINCRBYFLOAT", "h", "new", "0.25"))
+	assert.EqualValues(t, "ERR hash value is not a float", run(s, "HINCRBYFLOAT", "h", "str", "1"))
+	assert.EqualValues(t, "ERR value is not a valid float", run(s, "HINCRBYFLOAT", "h", "f", "inf"))
+
+	// a failed increment does not create the key
+	assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "HINCRBY", "missing", "n", "x"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "missing"))
+}
+
+func TestHRANDFIELD(t *testing.T) {
+	s := core.NewStorage()
+	assert.Nil(t, run(s, "HRANDFIELD", "missing"))
+	assert.EqualValues(t, []interface{}{}, run(s, "HRANDFIELD", "missing", "3"))
+	run(s, "HSET", "h", "a", "1", "b", "2", "c", "3")
+	assert.Contains(t, []string{"a", "b", "c"}, run(s, "HRANDFIELD", "h"))
+
+	assert.ElementsMatch(t, []interface{}{"a", "b", "c"}, run(s, "HRANDFIELD", "h", "10"))
+	fields := run(s, "HRANDFIELD", "h", "2").([]interface{})
+	assert.Len(t, fields, 2)
+	assert.NotEqual(t, fields[0], fields[1])
+	assert.Len(t, run(s, "HRANDFIELD", "h", "-7"), 7)
+	assert.EqualValues(t, []interface{}{}, run(s, "HRANDFIELD", "h", "0"))
+
+	pairs := run(s, "HRANDFIELD", "h", "-4", "WITHVALUES").([]interface{})
+	assert.Len(t, pairs, 8)
+	for i := 0; i < len(pairs); i += 2 {
+		assert.EqualValues(t, run(s, "HGET", "h", pairs[i].(string)), pairs[i+1])
+	}
+	assert.EqualValues(t, "ERR syntax error", run(s, "HRANDFIELD", "h", "1", "WITHSCORES"))
+	assert.EqualValues(t, "ERR value is out of range", run(s, "HRANDFIELD", "h", "-9223372036854775808", "WITHVALUES"))
+	assert.EqualValues(t, "ERR value is out of range", run(s, "HRANDFIELD", "h", "-9223372036854775808"))
+	assert.EqualValues(t, "ERR value is out of range", run(s, "HRANDFIELD", "missing", "-9223372036854775808"))
+}
+
+func TestHSCAN(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "HSET", "small", "a", "1", "b", "2")
+	assert.EqualValues(t, []string{"a", "1", "b", "2"}, scanAll(t, s, "HSCAN", "small", "COUNT", "1"))
+	assert.EqualValues(t, []string{"a", "b"}, scanAll(t, s, "HSCAN", "small", "NOVALUES"))
+
+	var want []string
+	for i := 0; i < 300; i++ {
+		field := fmt.Sprintf("f%d", i)
+		run(s, "HSET", "big", field, "v")
+		if strings.HasPrefix(field, "f1") {
+			want = append(want, field)
+		}
+	}
+	assert.ElementsMatch(t, want, scanAll(t, s, "HSCAN", "big", "MATCH", "f1*", "NOVALUES", "COUNT", "20"))
+	assert.Len(t, scanAll(t, s, "HSCAN", "big"), 600)
+	assert.EqualValues(t, "ERR syntax error", run(s, "HSCAN", "big", "0", "NOVALUES", "TYPE", "hash"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "SSCAN", "big", "0", "NOVALUES"))
+}
+
+func TestHash_Encoding(t *testing.T) {
+	defer func(entries, value int) {
+		config.HashMaxListpackEntries, config.HashMaxListpackValue = entries, value
+	}(config.HashMaxListpackEntries, config.HashMaxListpackValue)
+	config.HashMaxListpackEntries, config.HashMaxListpackValue = 2, 4
+
+	s := core.NewStorage()
+	run(s, "HSET", "h", "a", "1", "b", "2")
+	run(s, "COPY", "h", "copy")
+	run(s, "HSET", "h", "c", "3")
+	run(s, "HSET", "copy", "long", "value")
+	for _, key := range []string{"h", "copy"} {
+		assert.EqualValues(t, 3, run(s, "HLEN", key))
+		assert.EqualValues(t, "2", run(s, "HGET", key, "b"))
+	}
+	assert.EqualValues(t, "value", run(s, "HGET", "copy", "long"))
+	assert.EqualValues(t, 0, run(s, "HEXISTS", "h", "long"))
+}
+
+func TestHEXPIRE(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "HSET", "h", "a", "1", "b", "2", "c", "3")
+	assert.EqualValues(t, []interface{}{int64(1), int64(-2)}, run(s, "HEXPIRE", "h", "100", "FIELDS", "2", "a", "missing"))
+	assert.EqualValues(t, []interface{}{int64(-2)}, run(s, "HEXPIRE", "missing", "100", "FIELDS", "1", "a"))
+	assert.EqualValues(t, []interface{}{int64(100), int64(-1), int64(-2)}, run(s, "HTTL", "h", "FIELDS", "3", "a", "b", "missing"))
+	pttl := run(s, "HPTTL", "h", "FIELDS", "1", "a").([]interface{})[0].(int64)
+	assert.True(t, pttl > 99000 && pttl <= 100000, pttl)
+
+	// the conditions are the ones of EXPIRE, a field without TTL has an infinite TTL
+	assert.EqualValues(t, []interface{}{int64(0), int64(1)}, run(s, "HEXPIRE", "h", "50", "NX", "FIELDS", "2", "a", "b"))
+	assert.EqualValues(t, []interface{}{int64(1), int64(0)}, run(s, "HPEXPIRE", "h", "200000", "GT", "FIELDS", "2", "a", "c"))
+	assert.EqualValues(t, []interface{}{int64(0), int64(1)}, run(s, "HEXPIRE", "h", "300", "lt", "FIELDS", "2", "a", "c"))
+	assert.EqualValues(t, []interface{}{int64(200), int64(50), int64(300)}, run(s, "HTTL", "h", "FIELDS", "3", "a", "b", "c"))
+
+	// the fields with an expiry in the past are deleted, the key too once empty
+	assert.EqualValues(t, []interface{}{int64(2)}, run(s, "HEXPIREAT", "h", "1", "FIELDS", "1", "a"))
+	assert.EqualValues(t, []interface{}{int64(1)}, run(s, "HPERSIST", "h", "FIELDS", "1", "b"))
+	assert.EqualValues(t, []interface{}{int64(-1), int64(-2)}, run(s, "HPERSIST", "h", "FIELDS", "2", "b", "a"))
+	assert.EqualValues(t, []interface{}{int64(2), int64(2)}, run(s, "HPEXPIREAT", "h", "0", "FIELDS", "2", "b", "c"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "h"))
+
+	run(s, "HSET", "h", "a", "1")
+	assert.EqualValues(t, "ERR Mandatory argument FIELDS is missing or not at the right position", run(s, "HEXPIRE", "h", "10", "XX", "GT", "FIELDS", "1", "a"))
+	assert.EqualValues(t, "ERR Parameter `numFields` should be greater than 0", run(s, "HEXPIRE", "h", "10", "FIELDS", "0", "a"))
+	assert.EqualValues(t, "ERR The `numfields` parameter must match the number of arguments", run(s, "HTTL", "h", "FIELDS", "2", "a"))
+	assert.EqualValues(t, "ERR invalid expire time, must be >= 0", run(s, "HEXPIRE", "h", "-1", "FIELDS", "1", "a"))
+	assert.EqualValues(t, "ERR invalid expire time in 'hpexpireat' command", run(s, "HPEXPIREAT", "h", "281474976710655", "FIELDS", "1", "a"))
+	assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "HEXPIRE", "h", "x", "FIELDS", "1", "a"))
+}
+
+func TestHEXPIRE_Overwrite(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "HSET", "h", "n", "1", "f", "1.5", "s", "v")
+	run(s, "HEXPIRE", "h", "100", "FIELDS", "3", "n", "f", "s")
+	// the increments keep the TTL, HSET removes it like SET
+	run(s, "HINCRBY", "h", "n", "1")
+	run(s, "HINCRBYFLOAT", "h", "f", "1")
+	run(s, "HSET", "h", "s", "w")
+	assert.EqualValues(t, []interface{}{int64(100), int64(100), int64(-1)}, run(s, "HTTL", "h", "FIELDS", "3", "n", "f", "s"))
+
+	// COPY and RENAME keep the TTL of the fields
+	run(s, "COPY", "h", "copy")
+	run(s, "RENAME", "h", "renamed")
+	assert.EqualValues(t, []interface{}{int64(100)}, run(s, "HTTL", "copy", "FIELDS", "1", "n"))
+	assert.EqualValues(t, []interface{}{int64(100)}, run(s, "HTTL", "renamed", "FIELDS", "1", "n"))
+}
+
+func TestHPEXPIRE_ExpiredFields(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "HSET", "h", "a", "1", "b", "2")
+	run(s, "HPEXPIRE", "h", "1", "FIELDS", "1", "a")
+	time.Sleep(5 * time.Millisecond)
+	// an expired field is deleted when the hash is accessed
+	assert.Nil(t, run(s, "HGET", "h", "a"))
+	assert.EqualValues(t, 1, run(s, "HLEN", "h"))
+	assert.EqualValues(t, []interface{}{"b", "2"}, run(s, "HGETALL", "h"))
+
+	// or by the active expire cycle, which deletes the key once its last field expired
+	for i := 0; i < 50; i++ {
+		run(s, "HSET", fmt.Sprintf("session:%d", i), "token", "t", "user", "u")
+		run(s, "HPEXPIRE", fmt.Sprintf("session:%d", i), "1", "FIELDS", "2", "token", "user")
+	}
+	run(s, "HSET", "kept", "token", "t", "user", "u")
+	run(s, "HPEXPIRE", "kept", "1", "FIELDS", "1", "token")
+	time.Sleep(5 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		s.ActiveDeleteExpiredKeys()
+	}
+	assert.EqualValues(t, 2, run(s, "DBSIZE"))
+	assert.EqualValues(t, []interface{}{"user", "u"}, run(s, "HGETALL", "kept"))
+}
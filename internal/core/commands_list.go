@@ -0,0 +1,481 @@
+package core
+
+import (
+	"Nietzsche/internal/constant"
+	"Nietzsche/internal/data_structure"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// getList returns the list of the key, nil if it does not exist
+func (s *Storage) getList(key string) (*data_structure.Quicklist, error) {
+	obj, err := s.lookup(key, data_structure.ObjTypeList)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.Quicklist), nil
+}
+
+// parseListEnd parses LEFT or RIGHT, it returns true for LEFT
+func parseListEnd(arg string) (bool, error) {
+	switch strings.ToUpper(arg) {
+	case "LEFT":
+		return true, nil
+	case "RIGHT":
+		return false, nil
+	}
+	return false, errSyntax
+}
+
+// listPush pushes the elements one after the other at the head of the list if left, at the tail
+// otherwise. The list is created if it is nil. It returns the new length of the list.
+func (s *Storage) listPush(key string, list *data_structure.Quicklist, left bool, elements ...string) int {
+	if list == nil {
+		list = data_structure.NewQuicklist()
+		s.dictStore.Set(key, data_structure.NewListObj(list))
+	}
+	for _, e := range elements {
+		if left {
+			list.PushHead(e)
+		} else {
+			list.PushTail(e)
+		}
+	}
+	return list.Len()
+}
+
+// listPop pops an element from the head of the list if left, from the tail otherwise.
+// The key is deleted once the list is empty.
+func (s *Storage) listPop(key string, list *data_structure.Quicklist, left bool) string {
+	var e string
+	if left {
+		e, _ = list.PopHead()
+	} else {
+		e, _ = list.PopTail()
+	}
+	if list.Len() == 0 {
+		s.dictStore.Del(key)
+	}
+	return e
+}
+
+func (s *Storage) push(args []string, left, onlyIfExists bool) []byte {
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil && onlyIfExists {
+		return constant.RespZero
+	}
+	return Encode(s.listPush(args[0], list, left, args[1:]...), false)
+}
+
+func (s *Storage) cmdLPUSH(args []string) []byte {
+	return s.push(args, true, false)
+}
+
+func (s *Storage) cmdRPUSH(args []string) []byte {
+	return s.push(args, false, false)
+}
+
+func (s *Storage) cmdLPUSHX(args []string) []byte {
+	return s.push(args, true, true)
+}
+
+func (s *Storage) cmdRPUSHX(args []string) []byte {
+	return s.push(args, false, true)
+}
+
+// pop pops an element, or with a count up to count elements
+func (s *Storage) pop(args []string, left bool) []byte {
+	if len(args) > 2 {
+		return Encode(errSyntax, false)
+	}
+	count := int64(-1)
+	if len(args) == 2 {
+		var err error
+		if count, err = strconv.ParseInt(args[1], 10, 64); err != nil || count < 0 {
+			return Encode(errors.New("ERR value is out of range, must be positive"), false)
+		}
+	}
+	key := args[0]
+	list, err := s.getList(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		if count == -1 {
+			return constant.RespNil
+		}
+		return constant.RespNilArray
+	}
+	if count == -1 {
+		return Encode(s.listPop(key, list, left), false)
+	}
+	res := make([]string, 0, min(count, int64(list.Len())))
+	for int64(len(res)) < count && list.Len() > 0 {
+		res = append(res, s.listPop(key, list, left))
+	}
+	return Encode(res, false)
+}
+
+func (s *Storage) cmdLPOP(args []string) []byte {
+	return s.pop(args, true)
+}
+
+func (s *Storage) cmdRPOP(args []string) []byte {
+	return s.pop(args, false)
+}
+
+func (s *Storage) cmdLLEN(args []string) []byte {
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	return Encode(list.Len(), false)
+}
+
+// listRange converts start and stop, which can be negative to count from the tail, to indexes
+// in [0, length). ok is false if the range is empty.
+func listRange(start, stop int64, length int) (int, int, bool) {
+	n := int64(length)
+	if start < 0 {
+		start = max(start+n, 0)
+	}
+	if stop < 0 {
+		stop += n
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return 0, 0, false
+	}
+	return int(start), int(stop), true
+}
+
+func (s *Storage) cmdLRANGE(args []string) []byte {
+	start, err1 := strconv.ParseInt(args[1], 10, 64)
+	stop, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return Encode(make([]string, 0), false)
+	}
+	first, last, ok := listRange(start, stop, list.Len())
+	if !ok {
+		return Encode(make([]string, 0), false)
+	}
+	return Encode(list.Range(first, last), false)
+}
+
+func (s *Storage) cmdLINDEX(args []string) []byte {
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespNil
+	}
+	e, ok := list.Index(index)
+	if !ok {
+		return constant.RespNil
+	}
+	return Encode(e, false)
+}
+
+func (s *Storage) cmdLSET(args []string) []byte {
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return Encode(errNoSuchKey, false)
+	}
+	if !list.Set(index, args[2]) {
+		return Encode(errors.New("ERR index out of range"), false)
+	}
+	return constant.RespOk
+}
+
+// cmdLINSERT inserts the element before or after the first occurrence of the pivot.
+// It returns the new length of the list, -1 if the pivot is not found and 0 if the key does not exist.
+func (s *Storage) cmdLINSERT(args []string) []byte {
+	var after bool
+	switch strings.ToUpper(args[1]) {
+	case "BEFORE":
+	case "AFTER":
+		after = true
+	default:
+		return Encode(errSyntax, false)
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	it := list.Iter(true)
+	for _, ok := it.Next(); ok; _, ok = it.Next() {
+		if it.Equal(args[2]) {
+			it.Insert(args[3], after)
+			return Encode(list.Len(), false)
+		}
+	}
+	return Encode(-1, false)
+}
+
+// cmdLREM removes the first count occurrences of the element from the head if count is positive,
+// from the tail if it is negative, and all of them if it is 0
+func (s *Storage) cmdLREM(args []string) []byte {
+	count, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	// the count is negated to search from the tail
+	if count == math.MinInt64 {
+		return Encode(errOutOfRange, false)
+	}
+	key := args[0]
+	list, err := s.getList(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespZero
+	}
+	fromHead := count >= 0
+	count = max(count, -count)
+	removed := int64(0)
+	it := list.Iter(fromHead)
+	for _, ok := it.Next(); ok && (count == 0 || removed < count); _, ok = it.Next() {
+		if it.Equal(args[2]) {
+			it.Delete()
+			removed++
+		}
+	}
+	if list.Len() == 0 {
+		s.dictStore.Del(key)
+	}
+	return Encode(removed, false)
+}
+
+// cmdLTRIM keeps only the elements from start to stop, the key is deleted if none remains
+func (s *Storage) cmdLTRIM(args []string) []byte {
+	start, err1 := strconv.ParseInt(args[1], 10, 64)
+	stop, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	key := args[0]
+	list, err := s.getList(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if list == nil {
+		return constant.RespOk
+	}
+	first, last, ok := listRange(start, stop, list.Len())
+	if !ok {
+		s.dictStore.Del(key)
+		return constant.RespOk
+	}
+	list.DelRange(last+1, list.Len()-last-1)
+	list.DelRange(0, first)
+	return constant.RespOk
+}
+
+// cmdLPOS returns the index of the element in the list. RANK skips the first matches, or searches
+// from the tail if negative, COUNT returns up to count matches (0 for all of them) and MAXLEN
+// bounds the number of compared elements.
+func (s *Storage) cmdLPOS(args []string) []byte {
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return Encode(errSyntax, false)
+		}
+		n, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err != nil {
+			return Encode(errNotInteger, false)
+		}
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			if n == 0 {
+				return Encode(errors.New("ERR RANK can't be zero: use 1 to start from the first match, "+
+					"2 from the second ... or use negative to start from the end of the list"), false)
+			}
+			if n == math.MinInt64 {
+				return Encode(errOutOfRange, false)
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return Encode(errors.New("ERR COUNT can't be negative"), false)
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				return Encode(errors.New("ERR MAXLEN can't be negative"), false)
+			}
+			maxLen = n
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	list, err := s.getList(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	res := make([]interface{}, 0)
+	if list != nil {
+		forward := rank > 0
+		skip := max(rank, -rank) - 1
+		// without COUNT only the first match is returned
+		limit := count
+		if count == -1 {
+			limit = 1
+		}
+		it := list.Iter(forward)
+		i := int64(0)
+		for _, ok := it.Next(); ok && (maxLen == 0 || i < maxLen); _, ok = it.Next() {
+			if it.Equal(args[1]) {
+				if skip > 0 {
+					skip--
+				} else {
+					index := i
+					if !forward {
+						index = int64(list.Len()) - 1 - i
+					}
+					res = append(res, index)
+					if limit != 0 && int64(len(res)) == limit {
+						break
+					}
+				}
+			}
+			i++
+		}
+	}
+	if count == -1 {
+		if len(res) == 0 {
+			return constant.RespNil
+		}
+		return Encode(res[0], false)
+	}
+	return Encode(res, false)
+}
+
+// cmdLMOVE pops an element from a side of the source list and pushes it to a side of the destination
+func (s *Storage) cmdLMOVE(args []string) []byte {
+	fromLeft, err := parseListEnd(args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	toLeft, err := parseListEnd(args[3])
+	if err != nil {
+		return Encode(err, false)
+	}
+	e, ok, err := s.listMove(args[0], args[1], fromLeft, toLeft)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !ok {
+		return constant.RespNil
+	}
+	return Encode(e, false)
+}
+
+// listMove moves an element from src to dst, ok is false if src does not exist.
+// Nothing is popped if dst holds another type than a list.
+func (s *Storage) listMove(src, dst string, fromLeft, toLeft bool) (e string, ok bool, err error) {
+	list, err := s.getList(src)
+	if list == nil {
+		return "", false, err
+	}
+	if _, err := s.getList(dst); err != nil {
+		return "", false, err
+	}
+	if src == dst {
+		// rotate in place so that the key, and its TTL, is kept
+		if fromLeft {
+			e, _ = list.PopHead()
+		} else {
+			e, _ = list.PopTail()
+		}
+		s.listPush(dst, list, toLeft, e)
+		return e, true, nil
+	}
+	e = s.listPop(src, list, fromLeft)
+	dstList, _ := s.getList(dst)
+	s.listPush(dst, dstList, toLeft, e)
+	return e, true, nil
+}
+
+// bpop pops an element from the first non-empty list of the keys, and replies with the key and
+// the element. If all the lists are empty, the client blocks until one of them is pushed to.
+func (s *Storage) bpop(args []string, left bool) []byte {
+	keys := args[:len(args)-1]
+	timeout, err := parseBlockTimeout(args[len(args)-1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	for _, key := range keys {
+		list, err := s.getList(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if list != nil {
+			return Encode([]string{key, s.listPop(key, list, left)}, false)
+		}
+	}
+	return s.block(keys, data_structure.ObjTypeList, timeout)
+}
+
+func (s *Storage) cmdBLPOP(args []string) []byte {
+	return s.bpop(args, true)
+}
+
+func (s *Storage) cmdBRPOP(args []string) []byte {
+	return s.bpop(args, false)
+}
+
+// cmdBLMOVE is LMOVE blocking until the source list is pushed to if it is empty
+func (s *Storage) cmdBLMOVE(args []string) []byte {
+	fromLeft, err := parseListEnd(args[2])
+	if err != nil {
+		return Encode(err, false)
+	}
+	toLeft, err := parseListEnd(args[3])
+	if err != nil {
+		return Encode(err, false)
+	}
+	timeout, err := parseBlockTimeout(args[4])
+	if err != nil {
+		return Encode(err, false)
+	}
+	e, ok, err := s.listMove(args[0], args[1], fromLeft, toLeft)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if !ok {
+		return s.block(args[:1], data_structure.ObjTypeList, timeout)
+	}
+	return Encode(e, false)
+}
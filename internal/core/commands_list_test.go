@@ -0,0 +1,185 @@
+package core_test
+
+import (
+	"Nietzsche/internal/config"
+	"Nietzsche/internal/core"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLPUSH_RPUSH(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, 3, run(s, "RPUSH", "l", "b", "c", "d"))
+	assert.EqualValues(t, 5, run(s, "LPUSH", "l", "a", "z"))
+	assert.EqualValues(t, []interface{}{"z", "a", "b", "c", "d"}, run(s, "LRANGE", "l", "0", "-1"))
+	assert.EqualValues(t, 5, run(s, "LLEN", "l"))
+	assert.EqualValues(t, 0, run(s, "LLEN", "missing"))
+	assert.EqualValues(t, "list", run(s, "TYPE", "l"))
+
+	assert.EqualValues(t, 0, run(s, "LPUSHX", "missing", "a"))
+	assert.EqualValues(t, 0, run(s, "RPUSHX", "missing", "a"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "missing"))
+	assert.EqualValues(t, 6, run(s, "RPUSHX", "l", "e"))
+	assert.EqualValues(t, 7, run(s, "LPUSHX", "l", "y"))
+
+	run(s, "SET", "str", "v")
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", run(s, "LPUSH", "str", "a"))
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", run(s, "LRANGE", "str", "0", "-1"))
+}
+
+func TestLPOP_RPOP(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "RPUSH", "l", "a", "b", "c", "d", "e")
+	assert.EqualValues(t, "a", run(s, "LPOP", "l"))
+	assert.EqualValues(t, "e", run(s, "RPOP", "l"))
+	assert.EqualValues(t, []interface{}{"b", "c"}, run(s, "LPOP", "l", "2"))
+	assert.EqualValues(t, []interface{}{}, run(s, "RPOP", "l", "0"))
+	assert.EqualValues(t, []interface{}{"d"}, run(s, "RPOP", "l", "10"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "l"), "the empty list is deleted")
+
+	assert.Nil(t, run(s, "LPOP", "l"))
+	assert.Nil(t, run(s, "RPOP", "l", "2"))
+	assert.EqualValues(t, "ERR value is out of range, must be positive", run(s, "LPOP", "l", "-1"))
+	assert.EqualValues(t, "ERR value is out of range, must be positive", run(s, "LPOP", "l", "x"))
+}
+
+func TestLRANGE_LINDEX_LSET(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "RPUSH", "l", "a", "b", "c", "d", "e")
+	assert.EqualValues(t, []interface{}{"b", "c", "d"}, run(s, "LRANGE", "l", "1", "3"))
+	assert.EqualValues(t, []interface{}{"d", "e"}, run(s, "LRANGE", "l", "-2", "100"))
+	assert.EqualValues(t, []interface{}{"a"}, run(s, "LRANGE", "l", "-100", "0"))
+	assert.EqualValues(t, []interface{}{}, run(s, "LRANGE", "l", "3", "1"))
+	assert.EqualValues(t, []interface{}{}, run(s, "LRANGE", "l", "5", "10"))
+	assert.EqualValues(t, []interface{}{}, run(s, "LRANGE", "missing", "0", "-1"))
+	assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "LRANGE", "l", "a", "1"))
+
+	assert.EqualValues(t, "a", run(s, "LINDEX", "l", "0"))
+	assert.EqualValues(t, "d", run(s, "LINDEX", "l", "-2"))
+	assert.Nil(t, run(s, "LINDEX", "l", "5"))
+	assert.Nil(t, run(s, "LINDEX", "missing", "0"))
+
+	assert.EqualValues(t, "OK", run(s, "LSET", "l", "-1", "E"))
+	assert.EqualValues(t, "E", run(s, "LINDEX", "l", "4"))
+	assert.EqualValues(t, "ERR index out of range", run(s, "LSET", "l", "5", "x"))
+	assert.EqualValues(t, "ERR no such key", run(s, "LSET", "missing", "0", "x"))
+}
+
+func TestLINSERT(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "RPUSH", "l", "a", "c", "c")
+	assert.EqualValues(t, 4, run(s, "LINSERT", "l", "BEFORE", "c", "b"))
+	assert.EqualValues(t, 5, run(s, "LINSERT", "l", "after", "c", "d"))
+	assert.EqualValues(t, []interface{}{"a", "b", "c", "d", "c"}, run(s, "LRANGE", "l", "0", "-1"))
+	assert.EqualValues(t, -1, run(s, "LINSERT", "l", "BEFORE", "x", "y"))
+	assert.EqualValues(t, 0, run(s, "LINSERT", "missing", "BEFORE", "x", "y"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "LINSERT", "l", "MIDDLE", "c", "y"))
+}
+
+func TestLREM(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "RPUSH", "l", "x", "a", "x", "b", "x", "c", "x")
+	assert.EqualValues(t, 2, run(s, "LREM", "l", "2", "x"))
+	assert.EqualValues(t, []interface{}{"a", "b", "x", "c", "x"}, run(s, "LRANGE", "l", "0", "-1"))
+	assert.EqualValues(t, 1, run(s, "LREM", "l", "-1", "x"))
+	assert.EqualValues(t, []interface{}{"a", "b", "x", "c"}, run(s, "LRANGE", "l", "0", "-1"))
+	assert.EqualValues(t, 0, run(s, "LREM", "l", "0", "missing"))
+	run(s, "RPUSH", "l", "x")
+	assert.EqualValues(t, 2, run(s, "LREM", "l", "0", "x"))
+	assert.EqualValues(t, []interface{}{"a", "b", "c"}, run(s, "LRANGE", "l", "0", "-1"))
+	assert.EqualValues(t, 0, run(s, "LREM", "missing", "0", "x"))
+
+	run(s, "RPUSH", "same", "x", "x")
+	assert.EqualValues(t, 2, run(s, "LREM", "same", "0", "x"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "same"), "the empty list is deleted")
+
+	assert.EqualValues(t, "ERR value is out of range", run(s, "LREM", "l", "-9223372036854775808", "x"))
+	assert.EqualValues(t, 1, run(s, "LREM", "l", "-9223372036854775807", "c"))
+}
+
+func TestLTRIM(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "RPUSH", "l", "a", "b", "c", "d", "e")
+	assert.EqualValues(t, "OK", run(s, "LTRIM", "l", "1", "-2"))
+	assert.EqualValues(t, []interface{}{"b", "c", "d"}, run(s, "LRANGE", "l", "0", "-1"))
+	assert.EqualValues(t, "OK", run(s, "LTRIM", "l", "0", "100"))
+	assert.EqualValues(t, 3, run(s, "LLEN", "l"))
+	assert.EqualValues(t, "OK", run(s, "LTRIM", "l", "2", "1"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "l"))
+	assert.EqualValues(t, "OK", run(s, "LTRIM", "missing", "0", "1"))
+}
+
+func TestLPOS(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "RPUSH", "l", "a", "b", "c", "1", "2", "3", "c", "c")
+	assert.EqualValues(t, 2, run(s, "LPOS", "l", "c"))
+	assert.Nil(t, run(s, "LPOS", "l", "x"))
+	assert.Nil(t, run(s, "LPOS", "missing", "x"))
+	assert.EqualValues(t, 6, run(s, "LPOS", "l", "c", "RANK", "2"))
+	assert.EqualValues(t, 7, run(s, "LPOS", "l", "c", "RANK", "-1"))
+	assert.EqualValues(t, 6, run(s, "LPOS", "l", "c", "RANK", "-2"))
+	assert.EqualValues(t, []interface{}{int64(2), int64(6)}, run(s, "LPOS", "l", "c", "COUNT", "2"))
+	assert.EqualValues(t, []interface{}{int64(2), int64(6), int64(7)}, run(s, "LPOS", "l", "c", "COUNT", "0"))
+	assert.EqualValues(t, []interface{}{int64(7), int64(6)}, run(s, "LPOS", "l", "c", "RANK", "-1", "COUNT", "2"))
+	assert.EqualValues(t, []interface{}{}, run(s, "LPOS", "l", "x", "COUNT", "0"))
+	assert.EqualValues(t, []interface{}{}, run(s, "LPOS", "missing", "x", "COUNT", "0"))
+	assert.EqualValues(t, []interface{}{int64(2)}, run(s, "LPOS", "l", "c", "COUNT", "0", "MAXLEN", "6"))
+	assert.Nil(t, run(s, "LPOS", "l", "c", "MAXLEN", "2"))
+
+	assert.EqualValues(t, "ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... "+
+		"or use negative to start from the end of the list", run(s, "LPOS", "l", "c", "RANK", "0"))
+	assert.EqualValues(t, "ERR value is out of range", run(s, "LPOS", "l", "c", "RANK", "-9223372036854775808"))
+	assert.Nil(t, run(s, "LPOS", "l", "c", "RANK", "-9223372036854775807"))
+	assert.EqualValues(t, "ERR COUNT can't be negative", run(s, "LPOS", "l", "c", "COUNT", "-1"))
+	assert.EqualValues(t, "ERR MAXLEN can't be negative", run(s, "LPOS", "l", "c", "MAXLEN", "-1"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "LPOS", "l", "c", "RANK"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "LPOS", "l", "c", "FOO", "1"))
+}
+
+func TestLMOVE(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "RPUSH", "src", "a", "b", "c")
+	assert.EqualValues(t, "c", run(s, "LMOVE", "src", "dst", "RIGHT", "LEFT"))
+	assert.EqualValues(t, "a", run(s, "LMOVE", "src", "dst", "left", "right"))
+	assert.EqualValues(t, []interface{}{"c", "a"}, run(s, "LRANGE", "dst", "0", "-1"))
+
+	// rotation
+	assert.EqualValues(t, "c", run(s, "LMOVE", "dst", "dst", "LEFT", "RIGHT"))
+	assert.EqualValues(t, []interface{}{"a", "c"}, run(s, "LRANGE", "dst", "0", "-1"))
+
+	assert.EqualValues(t, "b", run(s, "LMOVE", "src", "src", "LEFT", "LEFT"))
+	assert.EqualValues(t, []interface{}{"b"}, run(s, "LRANGE", "src", "0", "-1"), "a list of one element moved to itself")
+	run(s, "EXPIRE", "src", "100")
+	assert.EqualValues(t, "b", run(s, "LMOVE", "src", "src", "LEFT", "RIGHT"))
+	assert.EqualValues(t, 100, run(s, "TTL", "src"), "the key is not recreated")
+	assert.Nil(t, run(s, "LMOVE", "missing", "dst", "LEFT", "LEFT"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "LMOVE", "src", "dst", "UP", "LEFT"))
+
+	run(s, "SET", "str", "v")
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", run(s, "LMOVE", "src", "str", "LEFT", "LEFT"))
+	assert.EqualValues(t, []interface{}{"b"}, run(s, "LRANGE", "src", "0", "-1"), "nothing is popped on a wrong type")
+}
+
+func TestList_ManyNodes(t *testing.T) {
+	defer func(size int) { config.ListMaxListpackSize = size }(config.ListMaxListpackSize)
+	config.ListMaxListpackSize = 4
+
+	s := core.NewStorage()
+	var expected []interface{}
+	for i := 0; i < 50; i++ {
+		run(s, "RPUSH", "l", fmt.Sprint(i))
+		expected = append(expected, fmt.Sprint(i))
+	}
+	assert.EqualValues(t, "25", run(s, "LINDEX", "l", "25"))
+	assert.EqualValues(t, expected[10:31], run(s, "LRANGE", "l", "10", "30"))
+	assert.EqualValues(t, 51, run(s, "LINSERT", "l", "AFTER", "21", "x"))
+	assert.EqualValues(t, "x", run(s, "LINDEX", "l", "22"))
+	assert.EqualValues(t, 1, run(s, "LREM", "l", "0", "x"))
+	assert.EqualValues(t, "OK", run(s, "LTRIM", "l", "5", "-6"))
+	assert.EqualValues(t, expected[5:45], run(s, "LRANGE", "l", "0", "-1"))
+
+	run(s, "COPY", "l", "copy")
+	run(s, "RPOP", "l", "20")
+	assert.EqualValues(t, expected[5:45], run(s, "LRANGE", "copy", "0", "-1"))
+}
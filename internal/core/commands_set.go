@@ -2,57 +2,130 @@ package core
 
 import (
 	"Nietzsche/internal/data_structure"
-	"errors"
 )
 
-func cmdSADD(args []string) []byte {
-	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
+// getSet returns the set of the key, nil if it does not exist
+func (s *Storage) getSet(key string) (*data_structure.SimpleSet, error) {
+	obj, err := s.lookup(key, data_structure.ObjTypeSet)
+	if obj == nil {
+		return nil, err
 	}
-	key := args[0] // TODO: check key is used by other types or not
-	set, exist := setStore[key]
-	if !exist {
+	return obj.Value.(*data_structure.SimpleSet), nil
+}
+
+func (s *Storage) cmdSADD(args []string) []byte {
+	key := args[0]
+	set, err := s.getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		set = data_structure.NewSimpleSet(key)
-		setStore[key] = set
+		s.dictStore.Set(key, data_structure.NewSetObj(set))
 	}
 	count := set.Add(args[1:]...)
 	return Encode(count, false)
 }
 
-func cmdSREM(args []string) []byte {
-	if len(args) < 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SADD' command"), false)
-	}
+// cmdSREM removes the members from the set, the key is deleted once the set is empty
+func (s *Storage) cmdSREM(args []string) []byte {
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
-		set = data_structure.NewSimpleSet(key)
-		setStore[key] = set
+	set, err := s.getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
+		return Encode(0, false)
 	}
 	count := set.Rem(args[1:]...)
+	if set.Len() == 0 {
+		s.dictStore.Del(key)
+	}
 	return Encode(count, false)
 }
 
-func cmdSMEMBERS(args []string) []byte {
-	if len(args) != 1 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SMEMBERS' command"), false)
-	}
+func (s *Storage) cmdSMEMBERS(args []string) []byte {
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
+	set, err := s.getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		return Encode(make([]string, 0), false)
 	}
 	return Encode(set.Members(), false)
 }
 
-func cmdSISMEMBER(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SISMEMBER' command"), false)
-	}
+func (s *Storage) cmdSISMEMBER(args []string) []byte {
 	key := args[0]
-	set, exist := setStore[key]
-	if !exist {
+	set, err := s.getSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if set == nil {
 		return Encode(0, false)
 	}
 	return Encode(set.IsMember(args[1]), false)
 }
+
+func (s *Storage) cmdSSCAN(args []string) []byte {
+	opts, err := parseScanOptions(args[1:], "")
+	if err != nil {
+		return Encode(err, false)
+	}
+	set, err := s.getSet(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	res := make([]string, 0)
+	if set == nil {
+		return encodeScanReply(0, res)
+	}
+	members, next := set.Scan(opts.cursor, opts.count)
+	for _, m := range members {
+		if opts.match(m) {
+			res = append(res, m)
+		}
+	}
+	return encodeScanReply(next, res)
+}
+
+// setsOf returns the sets stored at keys, with an empty set for a missing key
+func (s *Storage) setsOf(keys []string) ([]*data_structure.SimpleSet, error) {
+	sets := make([]*data_structure.SimpleSet, len(keys))
+	for i, key := range keys {
+		set, err := s.getSet(key)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			set = data_structure.NewSimpleSet(key)
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+func (s *Storage) cmdSINTER(args []string) []byte {
+	sets, err := s.setsOf(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(data_structure.Intersection(sets...).Members(), false)
+}
+
+func (s *Storage) cmdSUNION(args []string) []byte {
+	sets, err := s.setsOf(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(data_structure.Union(sets...).Members(), false)
+}
+
+func (s *Storage) cmdSDIFF(args []string) []byte {
+	sets, err := s.setsOf(args)
+	if err != nil {
+		return Encode(err, false)
+	}
+	return Encode(data_structure.Difference(sets[0], sets[1:]...).Members(), false)
+}
@@ -8,10 +8,16 @@ import (
 	"strconv"
 )
 
-func cmdZADD(args []string) []byte {
-	if len(args) < 3 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZADD' command"), false)
+// getZSet returns the sorted set of the key, nil if it does not exist
+func (s *Storage) getZSet(key string) (*data_structure.ZSet, error) {
+	obj, err := s.lookup(key, data_structure.ObjTypeZSet)
+	if obj == nil {
+		return nil, err
 	}
+	return obj.Value.(*data_structure.ZSet), nil
+}
+
+func (s *Storage) cmdZADD(args []string) []byte {
 	key := args[0]
 	scoreIndex := 1
 
@@ -20,53 +26,114 @@ func cmdZADD(args []string) []byte {
 		return Encode(errors.New(fmt.Sprintf("(error) Wrong number of (score, member) arg: %d", numScoreEleArgs)), false)
 	}
 
-	zset, exist := zsetStore[key]
-	if !exist {
-		zset = data_structure.CreateZSet()
-		zsetStore[key] = zset
-	}
-
-	count := 0
+	// the pairs are all checked first, so that a failed command neither adds members nor
+	// leaves an empty key behind
+	scores := make([]float64, 0, numScoreEleArgs/2)
 	for i := scoreIndex; i < len(args); i += 2 {
-		member := args[i+1]
 		score, err := strconv.ParseFloat(args[i], 64)
 		if err != nil {
 			return Encode(errors.New("(error) Score must be floating point number"), false)
 		}
-		ret := zset.Add(score, member)
-		if ret != 1 {
+		if args[i+1] == "" {
 			return Encode(errors.New("error when adding element"), false)
 		}
-		count++
+		scores = append(scores, score)
 	}
-	return Encode(count, false)
-}
 
-func cmdZSCORE(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZSCORE' command"), false)
+	zset, err := s.getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
+		zset = data_structure.CreateZSet()
+		s.dictStore.Set(key, data_structure.NewZSetObj(zset))
 	}
+	for j, score := range scores {
+		zset.Add(score, args[scoreIndex+2*j+1])
+	}
+	return Encode(len(scores), false)
+}
+
+func (s *Storage) cmdZSCORE(args []string) []byte {
 	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := s.getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespNil
 	}
 	ret, score := zset.GetScore(member)
 	if ret == 0 {
 		return constant.RespNil
 	}
-	return Encode(fmt.Sprintf("%f", score), false)
+	return Encode(formatScore(score), false)
 }
 
-func cmdZRANK(args []string) []byte {
-	if len(args) != 2 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'ZRANK' command"), false)
+// formatScore formats a score in the replies
+func formatScore(score float64) string {
+	return fmt.Sprintf("%f", score)
+}
+
+// cmdZSCAN returns the members from the cursor, each followed by its score
+func (s *Storage) cmdZSCAN(args []string) []byte {
+	opts, err := parseScanOptions(args[1:], "")
+	if err != nil {
+		return Encode(err, false)
+	}
+	zset, err := s.getZSet(args[0])
+	if err != nil {
+		return Encode(err, false)
 	}
+	res := make([]string, 0)
+	if zset == nil {
+		return encodeScanReply(0, res)
+	}
+	members, next := zset.Scan(opts.cursor, opts.count)
+	for _, m := range members {
+		if opts.match(m) {
+			_, score := zset.GetScore(m)
+			res = append(res, m, formatScore(score))
+		}
+	}
+	return encodeScanReply(next, res)
+}
+
+func (s *Storage) cmdZRANK(args []string) []byte {
 	key, member := args[0], args[1]
-	zset, exist := zsetStore[key]
-	if !exist {
+	zset, err := s.getZSet(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if zset == nil {
 		return constant.RespNil
 	}
 	rank, _ := zset.GetRank(member, false)
 	return Encode(rank, false)
 }
+
+// cmdBZPOPMIN pops the member with the lowest score from the first non-empty sorted set of the
+// keys, and replies with the key, the member and its score. If all the sorted sets are empty,
+// the client blocks until a member is added to one of them.
+func (s *Storage) cmdBZPOPMIN(args []string) []byte {
+	keys := args[:len(args)-1]
+	timeout, err := parseBlockTimeout(args[len(args)-1])
+	if err != nil {
+		return Encode(err, false)
+	}
+	for _, key := range keys {
+		zset, err := s.getZSet(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if zset == nil || zset.Len() == 0 {
+			continue
+		}
+		member, score, _ := zset.PopMin()
+		if zset.Len() == 0 {
+			s.dictStore.Del(key)
+		}
+		return Encode([]string{key, member, formatScore(score)}, false)
+	}
+	return s.block(keys, data_structure.ObjTypeZSet, timeout)
+}
@@ -0,0 +1,482 @@
+package core
+
+import (
+	"Nietzsche/internal/config"
+	"Nietzsche/internal/constant"
+	"Nietzsche/internal/data_structure"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidStreamID = errors.New("ERR Invalid stream ID specified as stream command argument")
+var errStreamIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+
+var maxStreamID = data_structure.StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// getStream returns the stream of the key, nil if it does not exist
+func (s *Storage) getStream(key string) (*data_structure.Stream, error) {
+	obj, err := s.lookup(key, data_structure.ObjTypeStream)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.Value.(*data_structure.Stream), nil
+}
+
+// parseStreamID parses <ms>-<seq>, or <ms> alone whose sequence number is then missingSeq
+func parseStreamID(arg string, missingSeq uint64) (data_structure.StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(arg, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return data_structure.StreamID{}, errInvalidStreamID
+	}
+	seq := missingSeq
+	if hasSeq {
+		if seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+			return data_structure.StreamID{}, errInvalidStreamID
+		}
+	}
+	return data_structure.StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// parseRangeID parses a bound of XRANGE: - and + for the smallest and greatest IDs, an ID whose
+// missing sequence number is 0 for the start and the greatest one for the end, or an ID prefixed
+// with ( to exclude it. ok is false if the range is empty because of an excluded extreme ID.
+func parseRangeID(arg string, isStart bool) (id data_structure.StreamID, ok bool, err error) {
+	exclusive := strings.HasPrefix(arg, "(")
+	if !exclusive {
+		switch arg {
+		case "-":
+			return data_structure.StreamID{}, true, nil
+		case "+":
+			return maxStreamID, true, nil
+		}
+	}
+	missingSeq := uint64(0)
+	if !isStart {
+		missingSeq = math.MaxUint64
+	}
+	id, err = parseStreamID(strings.TrimPrefix(arg, "("), missingSeq)
+	if err != nil || !exclusive {
+		return id, true, err
+	}
+	if isStart {
+		id, ok = id.Incr()
+	} else {
+		id, ok = id.Decr()
+	}
+	return id, ok, nil
+}
+
+func encodeStreamEntries(entries []data_structure.StreamEntry) []interface{} {
+	res := make([]interface{}, len(entries))
+	for i, e := range entries {
+		res[i] = []interface{}{e.ID.String(), e.Fields}
+	}
+	return res
+}
+
+// streamTrim holds the MAXLEN or MINID option of XADD and XTRIM
+type streamTrim struct {
+	strategy string // "MAXLEN", "MINID", or empty without trimming
+	maxLen   int
+	minID    data_structure.StreamID
+	approx   bool
+	limit    int
+	hasLimit bool
+}
+
+// parseTrimOption parses the trimming option at args[i], MAXLEN|MINID [=|~] threshold, or
+// LIMIT count. It returns the index of the argument following the option, or 0 if args[i] is not
+// a trimming option.
+func (t *streamTrim) parseTrimOption(args []string, i int) (int, error) {
+	option := strings.ToUpper(args[i])
+	switch option {
+	case "MAXLEN", "MINID":
+	case "LIMIT":
+		if i+1 >= len(args) {
+			return 0, errSyntax
+		}
+		limit, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			return 0, errNotInteger
+		}
+		if limit < 0 {
+			return 0, errors.New("ERR The LIMIT argument must be >= 0.")
+		}
+		t.limit, t.hasLimit = limit, true
+		return i + 2, nil
+	default:
+		return 0, nil
+	}
+	if t.strategy != "" && t.strategy != option {
+		return 0, errors.New("ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+	}
+	t.strategy = option
+	i++
+	if i < len(args) && (args[i] == "=" || args[i] == "~") {
+		t.approx = args[i] == "~"
+		i++
+	}
+	if i >= len(args) {
+		return 0, errSyntax
+	}
+	if option == "MINID" {
+		id, err := parseStreamID(args[i], 0)
+		if err != nil {
+			return 0, err
+		}
+		t.minID = id
+		return i + 1, nil
+	}
+	maxLen, err := strconv.Atoi(args[i])
+	if err != nil {
+		return 0, errNotInteger
+	}
+	if maxLen < 0 {
+		return 0, errors.New("ERR The MAXLEN argument must be >= 0.")
+	}
+	t.maxLen = maxLen
+	return i + 1, nil
+}
+
+// validate checks the options once they are all parsed
+func (t *streamTrim) validate() error {
+	if t.hasLimit && !t.approx {
+		return errors.New("ERR syntax error, LIMIT cannot be used without the special ~ option")
+	}
+	return nil
+}
+
+// trim trims the stream and returns the number of deleted entries. Like in Redis, an approximate
+// trimming frees at most 100 listpacks worth of entries at once, unless LIMIT is given.
+func (t *streamTrim) trim(stream *data_structure.Stream) int {
+	if t.strategy == "" {
+		return 0
+	}
+	limit := t.limit
+	if t.approx && !t.hasLimit {
+		limit = 100 * config.StreamNodeMaxEntries
+		if limit <= 0 {
+			limit = 10000
+		}
+	}
+	return stream.Trim(t.maxLen, t.minID, t.strategy == "MINID", t.approx, limit)
+}
+
+// nextStreamID returns the ID of the entry added by XADD with the ID argument arg: * for an ID
+// generated from the current time, <ms>-* for an ID generated in the given millisecond, or an
+// explicit ID. The ID must be greater than the last ID of the stream.
+func nextStreamID(arg string, last data_structure.StreamID) (data_structure.StreamID, error) {
+	if arg == "*" {
+		now := uint64(time.Now().UnixMilli())
+		if now > last.Ms {
+			return data_structure.StreamID{Ms: now}, nil
+		}
+		id, ok := last.Incr()
+		if !ok {
+			return id, errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+		}
+		return id, nil
+	}
+	var id data_structure.StreamID
+	if msPart, ok := strings.CutSuffix(arg, "-*"); ok {
+		ms, err := strconv.ParseUint(msPart, 10, 64)
+		if err != nil {
+			return id, errInvalidStreamID
+		}
+		switch {
+		case ms > last.Ms:
+			return data_structure.StreamID{Ms: ms}, nil
+		case ms < last.Ms || last.Seq == math.MaxUint64:
+			return id, errStreamIDTooSmall
+		}
+		return data_structure.StreamID{Ms: ms, Seq: last.Seq + 1}, nil
+	}
+	id, err := parseStreamID(arg, 0)
+	if err != nil {
+		return id, err
+	}
+	if id == (data_structure.StreamID{}) {
+		return id, errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	}
+	if id.Compare(last) <= 0 {
+		return id, errStreamIDTooSmall
+	}
+	return id, nil
+}
+
+// cmdXADD appends an entry to a stream:
+// XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]
+func (s *Storage) cmdXADD(args []string) []byte {
+	var trim streamTrim
+	noMkStream := false
+	i := 1
+	for i < len(args) && args[i] != "*" {
+		if strings.ToUpper(args[i]) == "NOMKSTREAM" {
+			noMkStream = true
+			i++
+			continue
+		}
+		next, err := trim.parseTrimOption(args, i)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if next == 0 {
+			// the ID
+			break
+		}
+		i = next
+	}
+	if err := trim.validate(); err != nil {
+		return Encode(err, false)
+	}
+	fields := args[min(i+1, len(args)):]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return Encode(errWrongArity("xadd"), false)
+	}
+	stream, err := s.getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil && noMkStream {
+		return constant.RespNil
+	}
+	var last data_structure.StreamID
+	if stream != nil {
+		last = stream.LastID()
+	}
+	id, err := nextStreamID(args[i], last)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil {
+		stream = data_structure.NewStream()
+		s.dictStore.Set(args[0], data_structure.NewStreamObj(stream))
+	}
+	stream.Add(id, fields)
+	trim.trim(stream)
+	return Encode(id.String(), false)
+}
+
+// cmdXTRIM trims a stream: XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]
+func (s *Storage) cmdXTRIM(args []string) []byte {
+	var trim streamTrim
+	for i := 1; i < len(args); {
+		next, err := trim.parseTrimOption(args, i)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if next == 0 {
+			return Encode(errSyntax, false)
+		}
+		i = next
+	}
+	if trim.strategy == "" {
+		return Encode(errSyntax, false)
+	}
+	if err := trim.validate(); err != nil {
+		return Encode(err, false)
+	}
+	stream, err := s.getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil {
+		return constant.RespZero
+	}
+	return Encode(trim.trim(stream), false)
+}
+
+func (s *Storage) cmdXLEN(args []string) []byte {
+	stream, err := s.getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil {
+		return constant.RespZero
+	}
+	return Encode(stream.Len(), false)
+}
+
+// cmdXDEL deletes entries by ID and returns the number of deleted entries
+func (s *Storage) cmdXDEL(args []string) []byte {
+	ids := make([]data_structure.StreamID, len(args)-1)
+	for i, arg := range args[1:] {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return Encode(err, false)
+		}
+		ids[i] = id
+	}
+	stream, err := s.getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	deleted := 0
+	for _, id := range ids {
+		if stream != nil && stream.Delete(id) {
+			deleted++
+		}
+	}
+	return Encode(deleted, false)
+}
+
+// xrange replies the entries between the bounds args[1] and args[2], swapped for XREVRANGE
+func (s *Storage) xrange(args []string, rev bool) []byte {
+	startArg, endArg := args[1], args[2]
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+	start, startOk, err := parseRangeID(startArg, true)
+	if err != nil {
+		return Encode(err, false)
+	}
+	end, endOk, err := parseRangeID(endArg, false)
+	if err != nil {
+		return Encode(err, false)
+	}
+	count := 0
+	switch {
+	case len(args) == 5 && strings.ToUpper(args[3]) == "COUNT":
+		n, err := strconv.Atoi(args[4])
+		if err != nil {
+			return Encode(errNotInteger, false)
+		}
+		if n <= 0 {
+			return Encode([]interface{}{}, false)
+		}
+		count = n
+	case len(args) != 3:
+		return Encode(errSyntax, false)
+	}
+	stream, err := s.getStream(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if stream == nil || !startOk || !endOk {
+		return Encode([]interface{}{}, false)
+	}
+	return Encode(encodeStreamEntries(stream.Range(start, end, count, rev)), false)
+}
+
+// cmdXRANGE replies the entries between two IDs: XRANGE key start end [COUNT count]
+func (s *Storage) cmdXRANGE(args []string) []byte {
+	return s.xrange(args, false)
+}
+
+// cmdXREVRANGE is XRANGE from the end to the start: XREVRANGE key end start [COUNT count]
+func (s *Storage) cmdXREVRANGE(args []string) []byte {
+	return s.xrange(args, true)
+}
+
+// xreadStreams returns the index of the STREAMS token of XREAD, -1 if there is none
+func xreadStreams(args []string) int {
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COUNT", "BLOCK":
+			i++
+		case "STREAMS":
+			return i
+		}
+	}
+	return -1
+}
+
+// xreadKeys returns the indexes of the keys of XREAD, the first half of the arguments following STREAMS
+func xreadKeys(args []string) []int {
+	i := xreadStreams(args)
+	if i == -1 {
+		return nil
+	}
+	keys := make([]int, (len(args)-i-1)/2)
+	for j := range keys {
+		keys[j] = i + 1 + j
+	}
+	return keys
+}
+
+// cmdXREAD replies the entries following the given IDs of one or more streams:
+// XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+// If none of the streams has such entries, the client blocks until one of them is added to
+// with BLOCK, and the reply is nil otherwise. The ID $ is the last ID of the stream.
+func (s *Storage) cmdXREAD(args []string) []byte {
+	count := 0
+	var timeout time.Duration
+	blocking := false
+	streams := xreadStreams(args)
+	if streams == -1 {
+		return Encode(errSyntax, false)
+	}
+	for i := 0; i < streams; i += 2 {
+		if i+1 == streams {
+			return Encode(errSyntax, false)
+		}
+		n, err := strconv.ParseInt(args[i+1], 10, 64)
+		switch strings.ToUpper(args[i]) {
+		case "COUNT":
+			if err != nil {
+				return Encode(errNotInteger, false)
+			}
+			count = int(max(n, 0))
+		case "BLOCK":
+			if err != nil {
+				return Encode(errors.New("ERR timeout is not an integer or out of range"), false)
+			}
+			if n < 0 {
+				return Encode(errors.New("ERR timeout is negative"), false)
+			}
+			if n > math.MaxInt64/int64(time.Millisecond) {
+				return Encode(errors.New("ERR timeout is out of range"), false)
+			}
+			timeout, blocking = time.Duration(n)*time.Millisecond, true
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+	n := len(args) - streams - 1
+	if n == 0 || n%2 != 0 {
+		return Encode(errors.New("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified."), false)
+	}
+	keys, idArgs := args[streams+1:streams+1+n/2], args[streams+1+n/2:]
+	ids := make([]data_structure.StreamID, len(keys))
+	var res []interface{}
+	for i, key := range keys {
+		stream, err := s.getStream(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if idArgs[i] == "$" {
+			if stream != nil {
+				ids[i] = stream.LastID()
+			}
+			continue
+		}
+		if ids[i], err = parseStreamID(idArgs[i], 0); err != nil {
+			return Encode(err, false)
+		}
+		start, ok := ids[i].Incr()
+		if stream == nil || !ok {
+			continue
+		}
+		if entries := stream.Range(start, maxStreamID, count, false); len(entries) > 0 {
+			res = append(res, []interface{}{key, encodeStreamEntries(entries)})
+		}
+	}
+	if len(res) > 0 {
+		return Encode(res, false)
+	}
+	if !blocking {
+		return constant.RespNilArray
+	}
+	// once a stream is added to, the command is run again with the IDs $ stood for
+	rewritten := append([]string(nil), args...)
+	for i, arg := range idArgs {
+		if arg == "$" {
+			rewritten[streams+1+n/2+i] = ids[i].String()
+		}
+	}
+	return s.blockRewriting(rewritten, keys, data_structure.ObjTypeStream, timeout)
+}
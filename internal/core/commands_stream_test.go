@@ -0,0 +1,172 @@
+package core_test
+
+import (
+	"Nietzsche/internal/core"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestXADD(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, "1-1", run(s, "XADD", "s", "1-1", "a", "1"))
+	assert.EqualValues(t, "1-2", run(s, "XADD", "s", "1-*", "a", "2"))
+	assert.EqualValues(t, "5-0", run(s, "XADD", "s", "5-*", "a", "3"))
+	assert.EqualValues(t, "6-0", run(s, "XADD", "s", "6", "a", "4"))
+	assert.EqualValues(t, 4, run(s, "XLEN", "s"))
+	assert.EqualValues(t, "stream", run(s, "TYPE", "s"))
+
+	assert.EqualValues(t, "ERR The ID specified in XADD is equal or smaller than the target stream top item", run(s, "XADD", "s", "6-0", "a", "5"))
+	assert.EqualValues(t, "ERR The ID specified in XADD is equal or smaller than the target stream top item", run(s, "XADD", "s", "5-*", "a", "5"))
+	assert.EqualValues(t, "ERR The ID specified in XADD must be greater than 0-0", run(s, "XADD", "new", "0-0", "a", "5"))
+	assert.EqualValues(t, "ERR Invalid stream ID specified as stream command argument", run(s, "XADD", "s", "x-1", "a", "5"))
+	assert.EqualValues(t, "ERR wrong number of arguments for 'xadd' command", run(s, "XADD", "s", "*", "a", "5", "b"))
+	assert.EqualValues(t, 4, run(s, "XLEN", "s"))
+
+	// * generates an ID from the current time
+	id := run(s, "XADD", "s", "*", "a", "5").(string)
+	ms, _ := strconv.ParseInt(strings.Split(id, "-")[0], 10, 64)
+	assert.InDelta(t, time.Now().UnixMilli(), ms, 1000)
+
+	assert.Nil(t, run(s, "XADD", "missing", "NOMKSTREAM", "*", "a", "1"))
+	assert.EqualValues(t, 0, run(s, "EXISTS", "missing"))
+	assert.EqualValues(t, 0, run(s, "XLEN", "missing"))
+
+	run(s, "SET", "str", "v")
+	assert.EqualValues(t, "WRONGTYPE Operation against a key holding the wrong kind of value", run(s, "XADD", "str", "*", "a", "1"))
+}
+
+func TestXRANGE_XREVRANGE(t *testing.T) {
+	s := core.NewStorage()
+	for i := 1; i <= 5; i++ {
+		run(s, "XADD", "s", strconv.Itoa(i)+"-0", "f", strconv.Itoa(i))
+	}
+	run(s, "XADD", "s", "5-1", "f", "6", "g", "x")
+
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"1-0", []interface{}{"f", "1"}},
+		[]interface{}{"2-0", []interface{}{"f", "2"}},
+	}, run(s, "XRANGE", "s", "-", "2"))
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"5-0", []interface{}{"f", "5"}},
+		[]interface{}{"5-1", []interface{}{"f", "6", "g", "x"}},
+	}, run(s, "XRANGE", "s", "5", "+"))
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"3-0", []interface{}{"f", "3"}},
+	}, run(s, "XRANGE", "s", "(2-0", "(4-0"))
+	assert.Len(t, run(s, "XRANGE", "s", "-", "+", "COUNT", "3"), 3)
+	assert.Empty(t, run(s, "XRANGE", "s", "-", "+", "COUNT", "0"))
+	assert.Empty(t, run(s, "XRANGE", "s", "4", "3"))
+	assert.Empty(t, run(s, "XRANGE", "missing", "-", "+"))
+
+	reply := run(s, "XREVRANGE", "s", "+", "-", "COUNT", "2").([]interface{})
+	assert.EqualValues(t, "5-1", reply[0].([]interface{})[0])
+	assert.EqualValues(t, "5-0", reply[1].([]interface{})[0])
+	reply = run(s, "XREVRANGE", "s", "3", "(1").([]interface{})
+	assert.Len(t, reply, 2)
+	assert.EqualValues(t, "3-0", reply[0].([]interface{})[0])
+
+	assert.EqualValues(t, "ERR Invalid stream ID specified as stream command argument", run(s, "XRANGE", "s", "(-", "+"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "XRANGE", "s", "-", "+", "LIMIT", "1"))
+}
+
+func TestXDEL(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "XADD", "s", "1-0", "f", "1")
+	run(s, "XADD", "s", "2-0", "f", "2")
+	assert.EqualValues(t, 1, run(s, "XDEL", "s", "1-0", "3-0", "1-0"))
+	assert.EqualValues(t, 1, run(s, "XLEN", "s"))
+	assert.EqualValues(t, "ERR Invalid stream ID specified as stream command argument", run(s, "XDEL", "s", "2-0", "x"))
+	assert.EqualValues(t, 1, run(s, "XDEL", "s", "2"))
+	// an empty stream is kept, with its last ID
+	assert.EqualValues(t, 0, run(s, "XLEN", "s"))
+	assert.EqualValues(t, 1, run(s, "EXISTS", "s"))
+	assert.EqualValues(t, "ERR The ID specified in XADD is equal or smaller than the target stream top item", run(s, "XADD", "s", "2-0", "f", "3"))
+	assert.EqualValues(t, 0, run(s, "XDEL", "missing", "1-0"))
+}
+
+func TestXTRIM(t *testing.T) {
+	s := core.NewStorage()
+	for i := 1; i <= 10; i++ {
+		run(s, "XADD", "s", strconv.Itoa(i)+"-0", "f", "v")
+	}
+	assert.EqualValues(t, 2, run(s, "XTRIM", "s", "MAXLEN", "8"))
+	assert.EqualValues(t, 3, run(s, "XTRIM", "s", "MINID", "=", "6"))
+	assert.EqualValues(t, "6-0", run(s, "XRANGE", "s", "-", "+", "COUNT", "1").([]interface{})[0].([]interface{})[0])
+	// the entries fit in a single listpack, which an approximate trimming does not free
+	assert.EqualValues(t, 0, run(s, "XTRIM", "s", "MAXLEN", "~", "1"))
+	assert.EqualValues(t, 5, run(s, "XLEN", "s"))
+	assert.EqualValues(t, 0, run(s, "XTRIM", "missing", "MAXLEN", "0"))
+
+	assert.EqualValues(t, "ERR syntax error, LIMIT cannot be used without the special ~ option", run(s, "XTRIM", "s", "MAXLEN", "1", "LIMIT", "10"))
+	assert.EqualValues(t, "ERR The MAXLEN argument must be >= 0.", run(s, "XTRIM", "s", "MAXLEN", "-1"))
+	assert.EqualValues(t, "ERR syntax error, MAXLEN and MINID options at the same time are not compatible", run(s, "XTRIM", "s", "MAXLEN", "1", "MINID", "1"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "XTRIM", "s", "LIMIT", "10"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "XTRIM", "s", "MINID", "~"))
+
+	// XADD trims after adding
+	assert.EqualValues(t, "11-0", run(s, "XADD", "s", "MAXLEN", "2", "11-0", "f", "v"))
+	assert.EqualValues(t, 2, run(s, "XLEN", "s"))
+	assert.EqualValues(t, "12-0", run(s, "XADD", "s", "MINID", "12", "12-0", "f", "v"))
+	assert.EqualValues(t, 1, run(s, "XLEN", "s"))
+}
+
+func TestXREAD(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "XADD", "a", "1-0", "f", "1")
+	run(s, "XADD", "a", "2-0", "f", "2")
+	run(s, "XADD", "b", "1-0", "f", "3")
+
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"a", []interface{}{[]interface{}{"2-0", []interface{}{"f", "2"}}}},
+		[]interface{}{"b", []interface{}{[]interface{}{"1-0", []interface{}{"f", "3"}}}},
+	}, run(s, "XREAD", "STREAMS", "a", "b", "1-0", "0"))
+	assert.EqualValues(t, []interface{}{
+		[]interface{}{"a", []interface{}{[]interface{}{"1-0", []interface{}{"f", "1"}}}},
+	}, run(s, "XREAD", "COUNT", "1", "STREAMS", "a", "missing", "0-0", "0-0"))
+	assert.Nil(t, run(s, "XREAD", "STREAMS", "a", "$"))
+	assert.Nil(t, run(s, "XREAD", "BLOCK", "10", "STREAMS", "a", "2-0"), "no blocking without a client")
+
+	assert.EqualValues(t, "ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.", run(s, "XREAD", "STREAMS", "a", "b", "0"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "XREAD", "COUNT", "1", "a", "0"))
+	assert.EqualValues(t, "ERR timeout is negative", run(s, "XREAD", "BLOCK", "-1", "STREAMS", "a", "0"))
+
+	spec := core.LookupCommand("XREAD")
+	assert.EqualValues(t, []int{3, 4}, spec.KeyIndexes([]string{"COUNT", "1", "STREAMS", "streams", "a", "0", "0"}))
+	assert.Contains(t, spec.FlagNames(), "movablekeys")
+}
+
+func TestXREAD_Blocking(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "XADD", "a", "1-0", "f", "1")
+	c1, c2 := newBlockedClient(), newBlockedClient()
+	_, ok := c1.run(s, "XREAD", "BLOCK", "0", "STREAMS", "a", "b", "$", "$")
+	assert.False(t, ok)
+	_, ok = c2.run(s, "XREAD", "BLOCK", "0", "STREAMS", "b", "0")
+	assert.False(t, ok)
+
+	// $ stands for the last ID when the client blocked
+	run(s, "XADD", "a", "2-0", "f", "2")
+	assert.EqualValues(t, []interface{}{[]interface{}{
+		[]interface{}{"a", []interface{}{[]interface{}{"2-0", []interface{}{"f", "2"}}}},
+	}}, c1.replies)
+	assert.Empty(t, c2.replies)
+
+	// deleting entries does not serve the client, adding one does
+	run(s, "XADD", "b", "1-0", "f", "3")
+	assert.Len(t, c2.replies, 1)
+	_, ok = c2.run(s, "XREAD", "BLOCK", "0", "STREAMS", "b", "$")
+	assert.False(t, ok)
+	run(s, "XDEL", "b", "1-0")
+	assert.Len(t, c2.replies, 1)
+	run(s, "XADD", "b", "2-0", "f", "4")
+	assert.Len(t, c2.replies, 2)
+
+	c3 := newBlockedClient()
+	c3.run(s, "XREAD", "BLOCK", "20", "STREAMS", "a", "$")
+	time.Sleep(30 * time.Millisecond)
+	s.UnblockTimedOutClients()
+	assert.EqualValues(t, []interface{}{nil}, c3.replies)
+}
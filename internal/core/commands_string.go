@@ -0,0 +1,627 @@
+package core
+
+import (
+	"Nietzsche/internal/config"
+	"Nietzsche/internal/constant"
+	"Nietzsche/internal/data_structure"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseExpireTime converts the value of an EX, PX, EXAT or PXAT option to an absolute unix time
+// in milliseconds. name is the command name used in the error message.
+func parseExpireTime(option, value, name string) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	if n <= 0 {
+		return 0, errInvalidExpireTime(name)
+	}
+	expireAt, ok := toUnixMilli(option, n)
+	if !ok {
+		return 0, errInvalidExpireTime(name)
+	}
+	return expireAt, nil
+}
+
+// toUnixMilli converts the time n of an EX, PX, EXAT or PXAT option to an absolute unix time
+// in milliseconds, ok is false if the conversion overflows
+func toUnixMilli(option string, n int64) (int64, bool) {
+	if option == "EX" || option == "EXAT" {
+		if n > math.MaxInt64/1000 || n < math.MinInt64/1000 {
+			return 0, false
+		}
+		n *= 1000
+	}
+	if option == "EX" || option == "PX" {
+		nowMs := time.Now().UnixMilli()
+		if n > math.MaxInt64-nowMs {
+			return 0, false
+		}
+		n += nowMs
+	}
+	return n, true
+}
+
+// setOptions are the options of SET key value [NX | XX] [GET] [EX | PX | EXAT | PXAT | KEEPTTL]
+type setOptions struct {
+	nx, xx   bool
+	get      bool
+	keepTTL  bool
+	expireAt int64 // unix time in milliseconds, 0 if no expiry is given
+}
+
+func parseSetOptions(args []string) (*setOptions, error) {
+	opts := &setOptions{}
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); option {
+		case "NX":
+			if opts.xx {
+				return nil, errSyntax
+			}
+			opts.nx = true
+		case "XX":
+			if opts.nx {
+				return nil, errSyntax
+			}
+			opts.xx = true
+		case "GET":
+			opts.get = true
+		case "KEEPTTL":
+			if opts.expireAt != 0 {
+				return nil, errSyntax
+			}
+			opts.keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if opts.keepTTL || opts.expireAt != 0 || i+1 == len(args) {
+				return nil, errSyntax
+			}
+			expireAt, err := parseExpireTime(option, args[i+1], "set")
+			if err != nil {
+				return nil, err
+			}
+			opts.expireAt = expireAt
+			i++
+		default:
+			return nil, errSyntax
+		}
+	}
+	return opts, nil
+}
+
+// getStringObj returns the object of the key, nil if it does not exist or has expired
+func (s *Storage) getStringObj(key string) (*data_structure.Obj, error) {
+	return s.lookup(key, data_structure.ObjTypeString)
+}
+
+// getString returns the value of the key, nil if it does not exist or has expired
+func (s *Storage) getString(key string) (interface{}, error) {
+	obj, err := s.getStringObj(key)
+	if obj == nil {
+		return nil, err
+	}
+	return obj.StringValue(), nil
+}
+
+// setString stores a string value, replacing a value of any type. The expiry of the previous value is removed, unless keepTTL is set,
+// and replaced by expireAt if it is not 0.
+func (s *Storage) setString(key, value string, expireAt int64, keepTTL bool) {
+	if !keepTTL {
+		s.dictStore.DelExpiry(key)
+	}
+	s.dictStore.Set(key, data_structure.NewStringObj(value))
+	if expireAt != 0 {
+		s.dictStore.SetExpiryAt(key, uint64(expireAt))
+	}
+}
+
+// encodeValue encodes a value returned by getString
+func encodeValue(value interface{}) []byte {
+	if value == nil {
+		return constant.RespNil
+	}
+	return Encode(value, false)
+}
+
+func (s *Storage) cmdSET(args []string) []byte {
+	key, value := args[0], args[1]
+	opts, err := parseSetOptions(args[2:])
+	if err != nil {
+		return Encode(err, false)
+	}
+
+	var old interface{}
+	if opts.get {
+		if old, err = s.getString(key); err != nil {
+			return Encode(err, false)
+		}
+	}
+	exist := s.keyExists(key)
+	if (opts.nx && exist) || (opts.xx && !exist) {
+		if opts.get {
+			return encodeValue(old)
+		}
+		return constant.RespNil
+	}
+	s.setString(key, value, opts.expireAt, opts.keepTTL)
+	if opts.get {
+		return encodeValue(old)
+	}
+	return constant.RespOk
+}
+
+func (s *Storage) cmdGET(args []string) []byte {
+	value, err := s.getString(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	return encodeValue(value)
+}
+
+func (s *Storage) cmdSETNX(args []string) []byte {
+	if s.keyExists(args[0]) {
+		return constant.RespZero
+	}
+	s.setString(args[0], args[1], 0, false)
+	return constant.RespOne
+}
+
+// setWithExpiry implements SETEX and PSETEX key ttl value, option is EX or PX
+func (s *Storage) setWithExpiry(args []string, option, name string) []byte {
+	expireAt, err := parseExpireTime(option, args[1], name)
+	if err != nil {
+		return Encode(err, false)
+	}
+	s.setString(args[0], args[2], expireAt, false)
+	return constant.RespOk
+}
+
+func (s *Storage) cmdSETEX(args []string) []byte {
+	return s.setWithExpiry(args, "EX", "setex")
+}
+
+func (s *Storage) cmdPSETEX(args []string) []byte {
+	return s.setWithExpiry(args, "PX", "psetex")
+}
+
+func (s *Storage) cmdGETSET(args []string) []byte {
+	old, err := s.getString(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	s.setString(args[0], args[1], 0, false)
+	return encodeValue(old)
+}
+
+func (s *Storage) cmdGETDEL(args []string) []byte {
+	value, err := s.getString(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if value != nil {
+		s.dictStore.Del(args[0])
+	}
+	return encodeValue(value)
+}
+
+// cmdGETEX returns the value of the key like GET, and sets its expiry with EX, PX, EXAT or PXAT,
+// or removes it with PERSIST
+func (s *Storage) cmdGETEX(args []string) []byte {
+	key := args[0]
+	var expireAt int64
+	persist := false
+	for i := 1; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); option {
+		case "PERSIST":
+			if expireAt != 0 || persist {
+				return Encode(errSyntax, false)
+			}
+			persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if expireAt != 0 || persist || i+1 == len(args) {
+				return Encode(errSyntax, false)
+			}
+			var err error
+			if expireAt, err = parseExpireTime(option, args[i+1], "getex"); err != nil {
+				return Encode(err, false)
+			}
+			i++
+		default:
+			return Encode(errSyntax, false)
+		}
+	}
+
+	value, err := s.getString(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if value == nil {
+		return constant.RespNil
+	}
+	if persist {
+		s.dictStore.DelExpiry(key)
+	} else if expireAt != 0 {
+		s.dictStore.SetExpiryAt(key, uint64(expireAt))
+	}
+	return encodeValue(value)
+}
+
+func (s *Storage) cmdMGET(args []string) []byte {
+	values := make([][]byte, len(args))
+	for i, key := range args {
+		// a key holding another type is reported as missing, it is not an error
+		value, _ := s.getString(key)
+		values[i] = encodeValue(value)
+	}
+	return EncodeRawArray(values)
+}
+
+// cmdMSET sets the key value pairs, removing the expiry of the keys like SET
+func (s *Storage) cmdMSET(args []string) []byte {
+	if len(args)%2 != 0 {
+		return Encode(errWrongArity("mset"), false)
+	}
+	for i := 0; i < len(args); i += 2 {
+		s.setString(args[i], args[i+1], 0, false)
+	}
+	return constant.RespOk
+}
+
+// cmdMSETNX sets the key value pairs only if none of the keys exists
+func (s *Storage) cmdMSETNX(args []string) []byte {
+	if len(args)%2 != 0 {
+		return Encode(errWrongArity("msetnx"), false)
+	}
+	for i := 0; i < len(args); i += 2 {
+		if s.keyExists(args[i]) {
+			return constant.RespZero
+		}
+	}
+	for i := 0; i < len(args); i += 2 {
+		s.setString(args[i], args[i+1], 0, false)
+	}
+	return constant.RespOne
+}
+
+// incrBy adds delta to the integer value of the key, a missing key counts as 0.
+// The value is updated in place, so the expiry of the key is kept.
+func (s *Storage) incrBy(key string, delta int64) []byte {
+	obj, err := s.getStringObj(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current int64
+	if obj != nil {
+		var ok bool
+		if current, ok = obj.IntValue(); !ok {
+			return Encode(errNotInteger, false)
+		}
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return Encode(errors.New("ERR increment or decrement would overflow"), false)
+	}
+	current += delta
+	if obj != nil {
+		obj.SetInt(current)
+	} else {
+		s.dictStore.Set(key, data_structure.NewIntObj(current))
+	}
+	return Encode(current, false)
+}
+
+func (s *Storage) cmdINCR(args []string) []byte {
+	return s.incrBy(args[0], 1)
+}
+
+func (s *Storage) cmdDECR(args []string) []byte {
+	return s.incrBy(args[0], -1)
+}
+
+func (s *Storage) cmdINCRBY(args []string) []byte {
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	return s.incrBy(args[0], delta)
+}
+
+func (s *Storage) cmdDECRBY(args []string) []byte {
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if delta == math.MinInt64 {
+		return Encode(errors.New("ERR decrement would overflow"), false)
+	}
+	return s.incrBy(args[0], -delta)
+}
+
+// parseFloat parses a float value of INCRBYFLOAT, infinity and NaN are not valid
+func parseFloat(value string) (float64, bool) {
+	f, err := strconv.ParseFloat(value, 64)
+	return f, err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+}
+
+func (s *Storage) cmdINCRBYFLOAT(args []string) []byte {
+	key := args[0]
+	errNotFloat := errors.New("ERR value is not a valid float")
+	increment, ok := parseFloat(args[1])
+	if !ok {
+		return Encode(errNotFloat, false)
+	}
+	obj, err := s.getStringObj(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	var current float64
+	if obj != nil {
+		if current, ok = parseFloat(obj.StringValue()); !ok {
+			return Encode(errNotFloat, false)
+		}
+	}
+	current += increment
+	if math.IsInf(current, 0) || math.IsNaN(current) {
+		return Encode(errors.New("ERR increment would produce NaN or Infinity"), false)
+	}
+
+	value := strconv.FormatFloat(current, 'f', -1, 64)
+	if obj != nil {
+		// like the integer counters, the value is replaced in place to keep the expiry
+		*obj = *data_structure.NewStringObj(value)
+	} else {
+		s.dictStore.Set(key, data_structure.NewStringObj(value))
+	}
+	return Encode(value, false)
+}
+
+var errStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+
+// checkStringLength returns an error if a string value of the given length would be too long
+func checkStringLength(length int64) error {
+	if length > int64(config.ProtoMaxBulkLen) {
+		return errStringTooLong
+	}
+	return nil
+}
+
+func (s *Storage) cmdAPPEND(args []string) []byte {
+	key, value := args[0], args[1]
+	obj, err := s.getStringObj(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		s.setString(key, value, 0, false)
+		return Encode(len(value), false)
+	}
+	if err := checkStringLength(int64(obj.Len()) + int64(len(value))); err != nil {
+		return Encode(err, false)
+	}
+	// append grows the buffer geometrically, so that appending to a log is amortized O(1)
+	b := append(obj.MutableBytes(), value...)
+	obj.SetBytes(b)
+	return Encode(len(b), false)
+}
+
+func (s *Storage) cmdSTRLEN(args []string) []byte {
+	obj, err := s.getStringObj(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		return constant.RespZero
+	}
+	return Encode(obj.Len(), false)
+}
+
+// cmdGETRANGE returns the substring between the start and end offsets, both included.
+// Negative offsets are relative to the end of the string.
+func (s *Storage) cmdGETRANGE(args []string) []byte {
+	start, err1 := strconv.ParseInt(args[1], 10, 64)
+	end, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return Encode(errNotInteger, false)
+	}
+	obj, err := s.getStringObj(args[0])
+	if err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil || (start < 0 && end < 0 && start > end) {
+		return Encode("", false)
+	}
+
+	value := obj.StringValue()
+	length := int64(len(value))
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	start = max(start, 0)
+	end = min(max(end, 0), length-1)
+	if start > end || length == 0 {
+		return Encode("", false)
+	}
+	return Encode(value[start:end+1], false)
+}
+
+// cmdSETRANGE overwrites the value from the offset, the string is padded with zero bytes
+// if it is shorter than the offset
+func (s *Storage) cmdSETRANGE(args []string) []byte {
+	key, value := args[0], args[2]
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return Encode(errNotInteger, false)
+	}
+	if offset < 0 {
+		return Encode(errors.New("ERR offset is out of range"), false)
+	}
+
+	obj, err := s.getStringObj(key)
+	if err != nil {
+		return Encode(err, false)
+	}
+	if len(value) == 0 {
+		// nothing to write, a missing key is not created
+		if obj == nil {
+			return constant.RespZero
+		}
+		return Encode(obj.Len(), false)
+	}
+	if err := checkStringLength(offset + int64(len(value))); err != nil {
+		return Encode(err, false)
+	}
+	if obj == nil {
+		obj = data_structure.NewStringObj("")
+		s.dictStore.Set(key, obj)
+	}
+
+	b := obj.MutableBytes()
+	if end := int(offset) + len(value); end > len(b) {
+		b = append(b, make([]byte, end-len(b))...)
+	}
+	copy(b[offset:], value)
+	obj.SetBytes(b)
+	return Encode(len(b), false)
+}
+
+// lcsOptions are the options of LCS key1 key2 [LEN] [IDX] [MINMATCHLEN len] [WITHMATCHLEN]
+type lcsOptions struct {
+	getLen, getIdx bool
+	minMatchLen    int64
+	withMatchLen   bool
+}
+
+func parseLcsOptions(args []string) (*lcsOptions, error) {
+	opts := &lcsOptions{}
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "LEN":
+			opts.getLen = true
+		case "IDX":
+			opts.getIdx = true
+		case "WITHMATCHLEN":
+			opts.withMatchLen = true
+		case "MINMATCHLEN":
+			if i+1 == len(args) {
+				return nil, errSyntax
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return nil, errNotInteger
+			}
+			opts.minMatchLen = max(n, 0)
+			i++
+		default:
+			return nil, errSyntax
+		}
+	}
+	if opts.getLen && opts.getIdx {
+		return nil, errors.New("ERR If you want both the length and indexes, please just use IDX.")
+	}
+	return opts, nil
+}
+
+// cmdLCS returns the longest common subsequence of two strings, missing keys are empty strings.
+// With IDX, the matching ranges of both strings are returned from the last to the first one.
+func (s *Storage) cmdLCS(args []string) []byte {
+	opts, err := parseLcsOptions(args[2:])
+	if err != nil {
+		return Encode(err, false)
+	}
+	var values [2]string
+	for i, key := range args[:2] {
+		obj, err := s.getStringObj(key)
+		if err != nil {
+			return Encode(err, false)
+		}
+		if obj != nil {
+			values[i] = obj.StringValue()
+		}
+	}
+	a, b := values[0], values[1]
+
+	// the dynamic programming table is transient, but it is limited like a string value
+	if (int64(len(a))+1)*(int64(len(b))+1)*4 > int64(config.ProtoMaxBulkLen) {
+		return Encode(errors.New("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len"), false)
+	}
+	// lcs[i*(len(b)+1)+j] is the length of the LCS of a[:i] and b[:j]
+	width := len(b) + 1
+	lcs := make([]uint32, (len(a)+1)*width)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				lcs[i*width+j] = lcs[(i-1)*width+j-1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i-1)*width+j], lcs[i*width+j-1])
+			}
+		}
+	}
+	length := lcs[len(a)*width+len(b)]
+	if opts.getLen {
+		return Encode(int64(length), false)
+	}
+
+	// walk the table back from the end to build the LCS and the matching ranges
+	result := make([]byte, length)
+	idx := int(length)
+	matches := []interface{}{}
+	noRange := len(a)
+	aStart, aEnd, bStart, bEnd := noRange, 0, 0, 0
+	for i, j := len(a), len(b); i > 0 && j > 0; {
+		emitRange := false
+		if a[i-1] == b[j-1] {
+			result[idx-1] = a[i-1]
+			if aStart == noRange {
+				aStart, aEnd, bStart, bEnd = i-1, i-1, j-1, j-1
+			} else if aStart == i && bStart == j {
+				// the range is contiguous, extend it backward
+				aStart--
+				bStart--
+			} else {
+				emitRange = true
+			}
+			// the first byte of one of the strings is reached, the loop ends
+			if aStart == 0 || bStart == 0 {
+				emitRange = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if lcs[(i-1)*width+j] > lcs[i*width+j-1] {
+				i--
+			} else {
+				j--
+			}
+			if aStart != noRange {
+				emitRange = true
+			}
+		}
+
+		if emitRange {
+			matchLen := int64(aEnd - aStart + 1)
+			if opts.getIdx && (opts.minMatchLen == 0 || matchLen >= opts.minMatchLen) {
+				match := []interface{}{
+					[]interface{}{int64(aStart), int64(aEnd)},
+					[]interface{}{int64(bStart), int64(bEnd)},
+				}
+				if opts.withMatchLen {
+					match = append(match, matchLen)
+				}
+				matches = append(matches, match)
+			}
+			aStart = noRange
+		}
+	}
+
+	if opts.getIdx {
+		return Encode([]interface{}{"matches", matches, "len", int64(length)}, false)
+	}
+	return Encode(string(result), false)
+}
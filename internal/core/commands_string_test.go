@@ -0,0 +1,276 @@
+package core_test
+
+import (
+	"Nietzsche/internal/core"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// run executes a command on the storage and returns the decoded reply
+func run(s *core.Storage, cmd string, args ...string) interface{} {
+	res, _ := core.Decode(s.Execute(&core.Command{Cmd: cmd, Args: args}))
+	return res
+}
+
+func TestSET_Options(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, "OK", run(s, "SET", "k", "v", "PX", "500"))
+	ttl := run(s, "TTL", "k").(int64)
+	assert.True(t, ttl >= 0 && ttl <= 1, ttl)
+
+	// NX sets only missing keys, XX only existing ones
+	assert.Nil(t, run(s, "SET", "k", "v2", "NX"))
+	assert.EqualValues(t, "v", run(s, "GET", "k"))
+	assert.EqualValues(t, "OK", run(s, "SET", "lock", "me", "nx", "ex", "10"))
+	assert.InDelta(t, 10, run(s, "TTL", "lock"), 1)
+	assert.Nil(t, run(s, "SET", "missing", "v", "XX"))
+	assert.Nil(t, run(s, "GET", "missing"))
+	assert.EqualValues(t, "OK", run(s, "SET", "k", "v3", "XX"))
+
+	// overwriting a key removes its expiry, unless KEEPTTL is given
+	assert.EqualValues(t, -1, run(s, "TTL", "k"))
+	assert.EqualValues(t, "OK", run(s, "SET", "lock", "you", "KEEPTTL"))
+	assert.InDelta(t, 10, run(s, "TTL", "lock"), 1)
+
+	// GET returns the previous value
+	assert.EqualValues(t, "you", run(s, "SET", "lock", "him", "GET"))
+	assert.Nil(t, run(s, "SET", "new", "v", "GET"))
+	assert.EqualValues(t, "v", run(s, "SET", "new", "other", "NX", "GET"))
+	assert.EqualValues(t, "v", run(s, "GET", "new"))
+
+	// absolute expiry times
+	at := time.Now().Add(100 * time.Second)
+	assert.EqualValues(t, "OK", run(s, "SET", "a", "v", "EXAT", strconv.FormatInt(at.Unix(), 10)))
+	assert.InDelta(t, 100, run(s, "TTL", "a"), 1)
+	assert.EqualValues(t, "OK", run(s, "SET", "a", "v", "PXAT", strconv.FormatInt(at.UnixMilli(), 10)))
+	assert.InDelta(t, 100, run(s, "TTL", "a"), 1)
+	assert.EqualValues(t, "OK", run(s, "SET", "a", "v", "PXAT", "1"))
+	assert.Nil(t, run(s, "GET", "a"))
+}
+
+func TestSET_Errors(t *testing.T) {
+	s := core.NewStorage()
+	for _, args := range [][]string{
+		{"k", "v", "NX", "XX"},
+		{"k", "v", "EX", "10", "PX", "10"},
+		{"k", "v", "EX", "10", "KEEPTTL"},
+		{"k", "v", "KEEPTTL", "PXAT", "10"},
+		{"k", "v", "EX"},
+		{"k", "v", "NOPE"},
+		{"k", "v", "10"},
+	} {
+		assert.EqualValues(t, "ERR syntax error", run(s, "SET", args...), args)
+	}
+	assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "SET", "k", "v", "EX", "ten"))
+	assert.EqualValues(t, "ERR invalid expire time in 'set' command", run(s, "SET", "k", "v", "PX", "0"))
+	assert.EqualValues(t, "ERR invalid expire time in 'set' command", run(s, "SET", "k", "v", "EX", "-1"))
+	assert.EqualValues(t, "ERR invalid expire time in 'set' command", run(s, "SET", "k", "v", "EX", "9223372036854775"))
+	assert.Nil(t, run(s, "GET", "k"))
+}
+
+func TestStringSetVariants(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, 1, run(s, "SETNX", "k", "v"))
+	assert.EqualValues(t, 0, run(s, "SETNX", "k", "v2"))
+	assert.EqualValues(t, "v", run(s, "GET", "k"))
+
+	assert.EqualValues(t, "OK", run(s, "SETEX", "k", "100", "v3"))
+	assert.InDelta(t, 100, run(s, "TTL", "k"), 1)
+	assert.EqualValues(t, "OK", run(s, "PSETEX", "k", "100000", "v4"))
+	assert.InDelta(t, 100, run(s, "TTL", "k"), 1)
+	assert.EqualValues(t, "ERR invalid expire time in 'setex' command", run(s, "SETEX", "k", "0", "v"))
+	assert.EqualValues(t, "ERR invalid expire time in 'psetex' command", run(s, "PSETEX", "k", "-5", "v"))
+
+	// GETSET removes the expiry like SET
+	assert.EqualValues(t, "v4", run(s, "GETSET", "k", "v5"))
+	assert.EqualValues(t, -1, run(s, "TTL", "k"))
+	assert.Nil(t, run(s, "GETSET", "other", "v"))
+
+	assert.EqualValues(t, "v5", run(s, "GETDEL", "k"))
+	assert.Nil(t, run(s, "GETDEL", "k"))
+	assert.EqualValues(t, -2, run(s, "TTL", "k"))
+}
+
+func TestGETEX(t *testing.T) {
+	s := core.NewStorage()
+	assert.Nil(t, run(s, "GETEX", "k", "EX", "10"))
+	run(s, "SET", "k", "v")
+	assert.EqualValues(t, "v", run(s, "GETEX", "k"))
+	assert.EqualValues(t, -1, run(s, "TTL", "k"))
+	assert.EqualValues(t, "v", run(s, "GETEX", "k", "EX", "10"))
+	assert.InDelta(t, 10, run(s, "TTL", "k"), 1)
+	assert.EqualValues(t, "v", run(s, "GETEX", "k", "PERSIST"))
+	assert.EqualValues(t, -1, run(s, "TTL", "k"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "GETEX", "k", "EX", "10", "PERSIST"))
+	assert.EqualValues(t, "ERR invalid expire time in 'getex' command", run(s, "GETEX", "k", "PX", "0"))
+}
+
+func TestIntegerCounters(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, 1, run(s, "INCR", "c"))
+	assert.EqualValues(t, 11, run(s, "INCRBY", "c", "10"))
+	assert.EqualValues(t, 10, run(s, "DECR", "c"))
+	assert.EqualValues(t, -5, run(s, "DECRBY", "c", "15"))
+	assert.EqualValues(t, "-5", run(s, "GET", "c"))
+
+	// a value set by SET is a counter if it is the canonical representation of an integer
+	run(s, "SET", "c", "41")
+	assert.EqualValues(t, 42, run(s, "INCR", "c"))
+	for _, value := range []string{"abc", "1.5", "007", "+1", " 1", "", "9223372036854775808"} {
+		run(s, "SET", "bad", value)
+		assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "INCR", "bad"), value)
+		assert.EqualValues(t, value, run(s, "GET", "bad"))
+	}
+	assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "INCRBY", "c", "x"))
+
+	run(s, "SET", "max", "9223372036854775807")
+	assert.EqualValues(t, "ERR increment or decrement would overflow", run(s, "INCR", "max"))
+	run(s, "SET", "min", "-9223372036854775808")
+	assert.EqualValues(t, "ERR increment or decrement would overflow", run(s, "DECR", "min"))
+	assert.EqualValues(t, "ERR decrement would overflow", run(s, "DECRBY", "c", "-9223372036854775808"))
+	assert.EqualValues(t, "9223372036854775807", run(s, "GET", "max"))
+
+	// the expiry of a counter is kept
+	run(s, "SET", "rate", "0", "EX", "100")
+	assert.EqualValues(t, 5, run(s, "INCRBY", "rate", "5"))
+	assert.InDelta(t, 100, run(s, "TTL", "rate"), 1)
+}
+
+func TestINCRBYFLOAT(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, "10.5", run(s, "INCRBYFLOAT", "f", "10.5"))
+	assert.EqualValues(t, "10.6", run(s, "INCRBYFLOAT", "f", "0.1"))
+	assert.EqualValues(t, "5.6", run(s, "INCRBYFLOAT", "f", "-5"))
+	assert.EqualValues(t, "5.6", run(s, "GET", "f"))
+	assert.EqualValues(t, "5000", run(s, "INCRBYFLOAT", "f", "4994.4"))
+	assert.EqualValues(t, 5001, run(s, "INCR", "f"))
+	assert.EqualValues(t, "5001.5", run(s, "INCRBYFLOAT", "f", "5.0e-1"))
+
+	run(s, "SET", "bad", "abc")
+	assert.EqualValues(t, "ERR value is not a valid float", run(s, "INCRBYFLOAT", "bad", "1"))
+	assert.EqualValues(t, "ERR value is not a valid float", run(s, "INCRBYFLOAT", "f", "x"))
+	assert.EqualValues(t, "ERR value is not a valid float", run(s, "INCRBYFLOAT", "f", "inf"))
+	run(s, "SET", "big", "1.7e308")
+	assert.EqualValues(t, "ERR increment would produce NaN or Infinity", run(s, "INCRBYFLOAT", "big", "1.7e308"))
+
+	run(s, "SET", "rate", "1.5", "EX", "100")
+	assert.EqualValues(t, "2", run(s, "INCRBYFLOAT", "rate", "0.5"))
+	assert.InDelta(t, 100, run(s, "TTL", "rate"), 1)
+}
+
+func TestAPPEND_STRLEN(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, 0, run(s, "STRLEN", "log"))
+	assert.EqualValues(t, 5, run(s, "APPEND", "log", "hello"))
+	assert.EqualValues(t, 11, run(s, "APPEND", "log", " world"))
+	assert.EqualValues(t, "hello world", run(s, "GET", "log"))
+	assert.EqualValues(t, 11, run(s, "STRLEN", "log"))
+
+	// binary safe
+	assert.EqualValues(t, 14, run(s, "APPEND", "log", "\x00\r\n"))
+	assert.EqualValues(t, "hello world\x00\r\n", run(s, "GET", "log"))
+
+	// an integer value can be appended to, and is still a counter afterwards
+	run(s, "SET", "n", "12", "EX", "100")
+	assert.EqualValues(t, 2, run(s, "STRLEN", "n"))
+	assert.EqualValues(t, 3, run(s, "APPEND", "n", "3"))
+	assert.EqualValues(t, 124, run(s, "INCR", "n"))
+	assert.InDelta(t, 100, run(s, "TTL", "n"), 1)
+}
+
+func TestGETRANGE(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "k", "This is a string")
+	for _, tc := range []struct {
+		start, end string
+		expected   string
+	}{
+		{"0", "3", "This"},
+		{"-3", "-1", "ing"},
+		{"0", "-1", "This is a string"},
+		{"10", "100", "string"},
+		{"5", "3", ""},
+		{"-1", "-5", ""},
+		{"-100", "3", "This"},
+		{"100", "200", ""},
+	} {
+		assert.EqualValues(t, tc.expected, run(s, "GETRANGE", "k", tc.start, tc.end), tc)
+	}
+	assert.EqualValues(t, "is", run(s, "SUBSTR", "k", "5", "6"))
+	assert.EqualValues(t, "", run(s, "GETRANGE", "missing", "0", "-1"))
+	assert.EqualValues(t, "ERR value is not an integer or out of range", run(s, "GETRANGE", "k", "a", "1"))
+	run(s, "SET", "n", "12345")
+	assert.EqualValues(t, "234", run(s, "GETRANGE", "n", "1", "3"))
+}
+
+func TestSETRANGE(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "k", "Hello World")
+	assert.EqualValues(t, 11, run(s, "SETRANGE", "k", "6", "Redis"))
+	assert.EqualValues(t, "Hello Redis", run(s, "GET", "k"))
+
+	// zero padding
+	assert.EqualValues(t, 11, run(s, "SETRANGE", "record", "6", "Redis"))
+	assert.EqualValues(t, "\x00\x00\x00\x00\x00\x00Redis", run(s, "GET", "record"))
+	assert.EqualValues(t, 13, run(s, "SETRANGE", "record", "11", "!!"))
+
+	// an empty value does not create the key
+	assert.EqualValues(t, 0, run(s, "SETRANGE", "missing", "10", ""))
+	assert.Nil(t, run(s, "GET", "missing"))
+	assert.EqualValues(t, 11, run(s, "SETRANGE", "k", "100", ""))
+
+	assert.EqualValues(t, "ERR offset is out of range", run(s, "SETRANGE", "k", "-1", "x"))
+	assert.EqualValues(t, "ERR string exceeds maximum allowed size (proto-max-bulk-len)",
+		run(s, "SETRANGE", "k", "536870912", "x"))
+	assert.EqualValues(t, "Hello Redis", run(s, "GET", "k"))
+}
+
+func TestLCS(t *testing.T) {
+	s := core.NewStorage()
+	run(s, "SET", "key1", "ohmytext")
+	run(s, "SET", "key2", "mynewtext")
+	assert.EqualValues(t, "mytext", run(s, "LCS", "key1", "key2"))
+	assert.EqualValues(t, 6, run(s, "LCS", "key1", "key2", "LEN"))
+	assert.EqualValues(t, []interface{}{
+		"matches", []interface{}{
+			[]interface{}{[]interface{}{int64(4), int64(7)}, []interface{}{int64(5), int64(8)}},
+			[]interface{}{[]interface{}{int64(2), int64(3)}, []interface{}{int64(0), int64(1)}},
+		},
+		"len", int64(6),
+	}, run(s, "LCS", "key1", "key2", "IDX"))
+	assert.EqualValues(t, []interface{}{
+		"matches", []interface{}{
+			[]interface{}{[]interface{}{int64(4), int64(7)}, []interface{}{int64(5), int64(8)}, int64(4)},
+		},
+		"len", int64(6),
+	}, run(s, "LCS", "key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN"))
+
+	assert.EqualValues(t, "", run(s, "LCS", "key1", "missing"))
+	assert.EqualValues(t, 0, run(s, "LCS", "missing", "missing", "LEN"))
+	assert.EqualValues(t, "ERR If you want both the length and indexes, please just use IDX.",
+		run(s, "LCS", "key1", "key2", "LEN", "IDX"))
+	assert.EqualValues(t, "ERR syntax error", run(s, "LCS", "key1", "key2", "MINMATCHLEN"))
+}
+
+func TestMultiKeyStrings(t *testing.T) {
+	s := core.NewStorage()
+	assert.EqualValues(t, "OK", run(s, "MSET", "a", "1", "b", "2", "a", "3"))
+	assert.EqualValues(t, []interface{}{"3", "2", nil}, run(s, "MGET", "a", "b", "c"))
+	assert.EqualValues(t, "ERR wrong number of arguments for 'mset' command", run(s, "MSET", "a", "1", "b"))
+
+	// MSET removes the expiry like SET
+	run(s, "SET", "t", "v", "EX", "100")
+	run(s, "MSET", "t", "w")
+	assert.EqualValues(t, -1, run(s, "TTL", "t"))
+
+	assert.EqualValues(t, 0, run(s, "MSETNX", "c", "1", "a", "1"))
+	assert.Nil(t, run(s, "GET", "c"))
+	assert.EqualValues(t, 1, run(s, "MSETNX", "c", "1", "d", "2"))
+	assert.EqualValues(t, []interface{}{"1", "2"}, run(s, "MGET", "c", "d"))
+	// a key of another type exists too
+	run(s, "SADD", "set", "m")
+	assert.EqualValues(t, 0, run(s, "MSETNX", "e", "1", "set", "1"))
+	assert.EqualValues(t, "ERR wrong number of arguments for 'msetnx' command", run(s, "MSETNX", "e", "1", "f"))
+}
@@ -1,17 +1,15 @@
 package core
 
 import (
+	"Nietzsche/internal/config"
 	"Nietzsche/internal/constant"
-	"Nietzsche/internal/data_structure"
 	"bytes"
 	"errors"
 	"fmt"
-	"strconv"
-	"syscall"
-	"time"
+	"strings"
 )
 
-func cmdPING(args []string) []byte {
+func (s *Storage) cmdPING(args []string) []byte {
 	var res []byte
 	if len(args) > 1 {
 		return Encode(errors.New("ERR wrong number of arguments for 'ping' command"), false)
@@ -25,123 +23,56 @@ func cmdPING(args []string) []byte {
 	return res
 }
 
-func cmdSET(args []string) []byte {
-	if len(args) < 2 || len(args) == 3 || len(args) > 4 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'SET' command"), false)
-	}
-
-	var key, value string
-	var ttlMs int64 = -1
-
-	key, value = args[0], args[1]
-	if len(args) > 2 {
-		ttlSec, err := strconv.ParseInt(args[3], 10, 64)
-		if err != nil {
-			return Encode(errors.New("(error) ERR value is not an integer or out of range"), false)
-		}
-		ttlMs = ttlSec * 1000
-	}
-
-	dictStore.Set(key, dictStore.NewObj(key, value, ttlMs))
-	return constant.RespOk
+func (s *Storage) cmdINFO(args []string) []byte {
+	var info []byte
+	buf := bytes.NewBuffer(info)
+	buf.WriteString("# Server\r\n")
+	buf.WriteString(fmt.Sprintf("io_mode:%s\r\n", config.IOMode))
+	buf.WriteString(fmt.Sprintf("tcp_port:%d\r\n", config.Port))
+	buf.WriteString("\r\n# Keyspace\r\n")
+	stat := s.dictStore.Stat()
+	buf.WriteString(fmt.Sprintf("db0:keys=%d,expires=%d,avg_ttl=0\r\n", stat.Key, stat.Expire))
+	return Encode(buf.String(), false)
 }
 
-func cmdGET(args []string) []byte {
-	if len(args) != 1 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'GET' command"), false)
-	}
-	key := args[0]
-	obj := dictStore.Get(key)
-	if obj == nil {
-		return constant.RespNil
-	}
+// cmdKEYPARTITION returns the partition owning the key. The single-threaded server has a single
+// partition, the multi-threaded server answers this command in its coordinator.
+func (s *Storage) cmdKEYPARTITION(args []string) []byte {
+	return constant.RespZero
+}
 
-	if dictStore.HasExpired(key) {
-		return constant.RespNil
-	}
+// ShutdownMode tells whether the final snapshot is taken on shutdown
+type ShutdownMode int
 
-	return Encode(obj.Value, false)
-}
+const (
+	ShutdownDefault ShutdownMode = iota // snapshot if the server has a snapshot hook
+	ShutdownSave
+	ShutdownNoSave
+)
 
-func cmdTTL(args []string) []byte {
-	if len(args) != 1 {
-		return Encode(errors.New("(error) ERR wrong number of arguments for 'TTL' command"), false)
-	}
-	key := args[0]
-	obj := dictStore.Get(key)
-	if obj == nil {
-		return constant.TtlKeyNotExist
-	}
+// ShutdownHandler starts the shutdown sequence of the server running the storages, it is set by the server
+var ShutdownHandler func(mode ShutdownMode)
 
-	exp, isExpirySet := dictStore.GetExpiry(key)
-	if !isExpirySet {
-		return constant.TtlKeyExistNoExpire
+// cmdSHUTDOWN starts the shutdown of the server. There is no reply, the connection is closed
+// once the replies of the commands sent before are written, the ones sent after are not executed.
+func (s *Storage) cmdSHUTDOWN(args []string) []byte {
+	if len(args) > 1 {
+		return Encode(errSyntax, false)
 	}
-
-	remainMs := exp - uint64(time.Now().UnixMilli())
-	if remainMs < 0 {
-		return constant.TtlKeyNotExist
+	mode := ShutdownDefault
+	for _, arg := range args {
+		switch strings.ToUpper(arg) {
+		case "SAVE":
+			mode = ShutdownSave
+		case "NOSAVE":
+			mode = ShutdownNoSave
+		default:
+			return Encode(errSyntax, false)
+		}
 	}
-
-	return Encode(int64(remainMs/1000), false)
-}
-
-func cmdINFO(args []string) []byte {
-	var info []byte
-	buf := bytes.NewBuffer(info)
-	buf.WriteString("# Keyspace\r\n")
-	buf.WriteString(fmt.Sprintf("db0:keys=%d,expires=0,avg_ttl=0\r\n", data_structure.HashKeySpaceStat.Key))
-	return Encode(buf.String(), false)
-}
-
-// ExecuteAndResponse given a Command, executes it and responses
-func ExecuteAndResponse(cmd *Command, connFd int) error {
-	var res []byte
-
-	switch cmd.Cmd {
-	case "INFO":
-		res = cmdINFO(cmd.Args)
-	case "PING":
-		res = cmdPING(cmd.Args)
-	case "SET":
-		res = cmdSET(cmd.Args)
-	case "GET":
-		res = cmdGET(cmd.Args)
-	case "TTL":
-		res = cmdTTL(cmd.Args)
-	case "ZADD":
-		res = cmdZADD(cmd.Args)
-	case "ZSCORE":
-		res = cmdZSCORE(cmd.Args)
-	case "ZRANK":
-		res = cmdZRANK(cmd.Args)
-	case "SADD":
-		res = cmdSADD(cmd.Args)
-	case "SREM":
-		res = cmdSREM(cmd.Args)
-	case "SMEMBERS":
-		res = cmdSMEMBERS(cmd.Args)
-	case "SISMEMBER":
-		res = cmdSISMEMBER(cmd.Args)
-	// Count-Min Sketch
-	case "CMS.INITBYDIM":
-		res = cmdCMSINITBYDIM(cmd.Args)
-	case "CMS.INITBYPROB":
-		res = cmdCMSINITBYPROB(cmd.Args)
-	case "CMS.INCRBY":
-		res = cmdCMSINCRBY(cmd.Args)
-	case "CMS.QUERY":
-		res = cmdCMSQUERY(cmd.Args)
-	// Bloom filter
-	case "BF.RESERVE":
-		res = cmdBFRESERVE(cmd.Args)
-	case "BF.MADD":
-		res = cmdBFMADD(cmd.Args)
-	case "BF.EXISTS":
-		res = cmdBFEXISTS(cmd.Args)
-	default:
-		res = []byte(fmt.Sprintf("-CMD NOT FOUND\r\n"))
+	if ShutdownHandler == nil {
+		return Encode(errors.New("ERR Errors trying to SHUTDOWN. Check logs."), false)
 	}
-	_, err := syscall.Write(connFd, res)
-	return err
+	ShutdownHandler(mode)
+	return nil
 }
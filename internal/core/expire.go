@@ -2,21 +2,65 @@ package core
 
 import (
 	"Nietzsche/internal/constant"
+	"Nietzsche/internal/data_structure"
 	"time"
 )
 
+// ActiveDeleteExpiredKeys runs the active expire cycle on the keyspace of the single-threaded server
 func ActiveDeleteExpiredKeys() {
+	defaultStorage.ActiveDeleteExpiredKeys()
+}
+
+func (s *Storage) ActiveDeleteExpiredKeys() {
 	for {
 		var expiredCount = 0
 		var sampleCountRemain = constant.ActiveExpireSampleSize
-		for key, expiredTime := range dictStore.GetExpireDictStore() {
+		for key, expiredTime := range s.dictStore.GetExpireDictStore() {
 			sampleCountRemain--
 			if sampleCountRemain < 0 {
 				break
 			}
 			if time.Now().UnixMilli() > int64(expiredTime) {
-				dictStore.Del(key)
+				s.dictStore.Del(key)
+				expiredCount++
+			}
+		}
+
+		if float64(expiredCount)/float64(constant.ActiveExpireSampleSize) <= constant.ActiveExpireThreshold {
+			break
+		}
+	}
+	s.activeDeleteExpiredFields()
+}
+
+// activeDeleteExpiredFields deletes the expired fields of a sample of the hashes having fields
+// with a time to live, and the keys of the hashes left empty. Like for the keys, the sampling is
+// repeated while enough of the sampled hashes had expired fields.
+func (s *Storage) activeDeleteExpiredFields() {
+	for {
+		var expiredCount = 0
+		var sampleCountRemain = constant.ActiveExpireSampleSize
+		nowMs := uint64(time.Now().UnixMilli())
+		for key := range s.dictStore.GetVolatileHashes() {
+			sampleCountRemain--
+			if sampleCountRemain < 0 {
+				break
+			}
+			obj := s.dictStore.Peek(key)
+			if obj == nil {
+				s.dictStore.DelVolatileHash(key)
+				continue
+			}
+			hash, ok := obj.Value.(*data_structure.Hash)
+			if !ok || !hash.HasVolatileFields() {
+				s.dictStore.DelVolatileHash(key)
+				continue
+			}
+			if hash.DelExpiredFields(nowMs, constant.ActiveExpireFieldsPerHash) > 0 {
 				expiredCount++
+				if hash.Len() == 0 {
+					s.dictStore.Del(key)
+				}
 			}
 		}
 
@@ -4,17 +4,24 @@ package io_multiplexing
 
 import (
 	"Nietzsche/internal/config"
+	"golang.org/x/sys/unix"
 	"log"
 	"syscall"
+	"time"
 )
 
 type Epoll struct {
 	fd            int
+	mode          TriggerMode
 	epollEvents   []syscall.EpollEvent
 	genericEvents []Event
 }
 
 func CreateIOMultiplexer() (*Epoll, error) {
+	return CreateIOMultiplexerWithMode(LevelTriggered)
+}
+
+func CreateIOMultiplexerWithMode(mode TriggerMode) (*Epoll, error) {
 	epollFD, err := syscall.EpollCreate1(0)
 	if err != nil {
 		log.Fatal(err)
@@ -23,19 +30,45 @@ func CreateIOMultiplexer() (*Epoll, error) {
 
 	return &Epoll{
 		fd:            epollFD,
-		epollEvents:   make([]syscall.EpollEvent, config.MaxConnection),
-		genericEvents: make([]Event, config.MaxConnection),
+		mode:          mode,
+		epollEvents:   make([]syscall.EpollEvent, config.MaxEventsPerLoop),
+		genericEvents: make([]Event, config.MaxEventsPerLoop),
 	}, nil
 }
 
-func (ep *Epoll) Monitor(event Event) error {
+func (ep *Epoll) toNative(event Event) syscall.EpollEvent {
 	epollEvent := event.toNative()
+	if ep.mode == EdgeTriggered {
+		epollEvent.Events |= unix.EPOLLET
+	}
+	return epollEvent
+}
+
+func (ep *Epoll) Monitor(event Event) error {
+	epollEvent := ep.toNative(event)
 	// Add event.Fd to the monitoring list of ep.fd
 	return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_ADD, event.Fd, &epollEvent)
 }
 
+func (ep *Epoll) Modify(event Event) error {
+	epollEvent := ep.toNative(event)
+	return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_MOD, event.Fd, &epollEvent)
+}
+
+func (ep *Epoll) Unmonitor(fd int) error {
+	return syscall.EpollCtl(ep.fd, syscall.EPOLL_CTL_DEL, fd, nil)
+}
+
 func (ep *Epoll) Wait() ([]Event, error) {
-	n, err := syscall.EpollWait(ep.fd, ep.epollEvents, -1)
+	return ep.WaitTimeout(-1)
+}
+
+func (ep *Epoll) WaitTimeout(timeout time.Duration) ([]Event, error) {
+	msec := -1
+	if timeout >= 0 {
+		msec = int(timeout.Milliseconds())
+	}
+	n, err := syscall.EpollWait(ep.fd, ep.epollEvents, msec)
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +79,10 @@ func (ep *Epoll) Wait() ([]Event, error) {
 	return ep.genericEvents[:n], nil
 }
 
+func (ep *Epoll) Mode() TriggerMode {
+	return ep.mode
+}
+
 func (ep *Epoll) Close() error {
 	return syscall.Close(ep.fd)
 }
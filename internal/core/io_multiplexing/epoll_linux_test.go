@@ -0,0 +1,186 @@
+//go:build linux
+
+package io_multiplexing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const testWaitTimeout = 50 * time.Millisecond
+
+func newSocketPair(t *testing.T) (int, int) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, syscall.SetNonblock(fds[0], true))
+	assert.Nil(t, syscall.SetNonblock(fds[1], true))
+	t.Cleanup(func() {
+		syscall.Close(fds[0])
+		syscall.Close(fds[1])
+	})
+	return fds[0], fds[1]
+}
+
+func newEpoll(t *testing.T, mode TriggerMode) *Epoll {
+	ep, err := CreateIOMultiplexerWithMode(mode)
+	assert.Nil(t, err)
+	t.Cleanup(func() { ep.Close() })
+	return ep
+}
+
+func TestEpoll_MonitorRead(t *testing.T) {
+	ep := newEpoll(t, LevelTriggered)
+	local, remote := newSocketPair(t)
+	assert.Nil(t, ep.Monitor(Event{Fd: local, Op: OpRead}))
+
+	events, err := ep.WaitTimeout(testWaitTimeout)
+	assert.Nil(t, err)
+	assert.Len(t, events, 0)
+
+	syscall.Write(remote, []byte("ping"))
+	events, err = ep.WaitTimeout(testWaitTimeout)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []Event{{Fd: local, Op: OpRead}}, events)
+}
+
+func TestEpoll_Modify(t *testing.T) {
+	ep := newEpoll(t, LevelTriggered)
+	local, remote := newSocketPair(t)
+	assert.Nil(t, ep.Monitor(Event{Fd: local, Op: OpRead}))
+
+	// an empty send buffer is writable right away
+	assert.Nil(t, ep.Modify(Event{Fd: local, Op: OpRead | OpWrite}))
+	events, _ := ep.WaitTimeout(testWaitTimeout)
+	assert.EqualValues(t, []Event{{Fd: local, Op: OpWrite}}, events)
+
+	syscall.Write(remote, []byte("ping"))
+	events, _ = ep.WaitTimeout(testWaitTimeout)
+	assert.EqualValues(t, []Event{{Fd: local, Op: OpRead | OpWrite}}, events)
+
+	assert.Nil(t, ep.Modify(Event{Fd: local, Op: OpRead}))
+	events, _ = ep.WaitTimeout(testWaitTimeout)
+	assert.EqualValues(t, []Event{{Fd: local, Op: OpRead}}, events)
+}
+
+func TestEpoll_Unmonitor(t *testing.T) {
+	ep := newEpoll(t, LevelTriggered)
+	local, remote := newSocketPair(t)
+	assert.Nil(t, ep.Monitor(Event{Fd: local, Op: OpRead}))
+	assert.Nil(t, ep.Unmonitor(local))
+
+	syscall.Write(remote, []byte("ping"))
+	events, err := ep.WaitTimeout(testWaitTimeout)
+	assert.Nil(t, err)
+	assert.Len(t, events, 0)
+
+	// the fd is not monitored anymore
+	assert.NotNil(t, ep.Unmonitor(local))
+	assert.NotNil(t, ep.Modify(Event{Fd: local, Op: OpRead}))
+}
+
+func TestEpoll_HangUpIsReadable(t *testing.T) {
+	ep := newEpoll(t, LevelTriggered)
+	local, remote := newSocketPair(t)
+	assert.Nil(t, ep.Monitor(Event{Fd: local, Op: OpRead}))
+
+	syscall.Shutdown(remote, syscall.SHUT_WR)
+	events, _ := ep.WaitTimeout(testWaitTimeout)
+	assert.EqualValues(t, []Event{{Fd: local, Op: OpRead}}, events)
+	n, err := syscall.Read(local, make([]byte, 16))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, n)
+}
+
+func TestEpoll_LevelTriggeredReportsUntilDrained(t *testing.T) {
+	ep := newEpoll(t, LevelTriggered)
+	local, remote := newSocketPair(t)
+	assert.Nil(t, ep.Monitor(Event{Fd: local, Op: OpRead}))
+	syscall.Write(remote, []byte("ping"))
+
+	for i := 0; i < 3; i++ {
+		events, _ := ep.WaitTimeout(testWaitTimeout)
+		assert.Len(t, events, 1)
+	}
+	syscall.Read(local, make([]byte, 16))
+	events, _ := ep.WaitTimeout(testWaitTimeout)
+	assert.Len(t, events, 0)
+}
+
+func TestEpoll_EdgeTriggeredReportsOnce(t *testing.T) {
+	ep := newEpoll(t, EdgeTriggered)
+	assert.EqualValues(t, EdgeTriggered, ep.Mode())
+	local, remote := newSocketPair(t)
+	assert.Nil(t, ep.Monitor(Event{Fd: local, Op: OpRead}))
+	syscall.Write(remote, []byte("ping"))
+
+	events, _ := ep.WaitTimeout(testWaitTimeout)
+	assert.Len(t, events, 1)
+	// data is still pending, but no new edge happened
+	events, _ = ep.WaitTimeout(testWaitTimeout)
+	assert.Len(t, events, 0)
+
+	// a partial read does not re-arm the event either, the reader has to drain until EAGAIN
+	buf := make([]byte, 2)
+	n, err := syscall.Read(local, buf)
+	assert.EqualValues(t, 2, n)
+	assert.Nil(t, err)
+	events, _ = ep.WaitTimeout(testWaitTimeout)
+	assert.Len(t, events, 0)
+	syscall.Read(local, buf)
+	_, err = syscall.Read(local, buf)
+	assert.Equal(t, syscall.EAGAIN, err)
+
+	// new data is a new edge
+	syscall.Write(remote, []byte("pong"))
+	events, _ = ep.WaitTimeout(testWaitTimeout)
+	assert.EqualValues(t, []Event{{Fd: local, Op: OpRead}}, events)
+}
+
+func TestEpoll_LevelAndEdgeTriggeredSideBySide(t *testing.T) {
+	lt := newEpoll(t, LevelTriggered)
+	et := newEpoll(t, EdgeTriggered)
+	ltLocal, ltRemote := newSocketPair(t)
+	etLocal, etRemote := newSocketPair(t)
+	assert.Nil(t, lt.Monitor(Event{Fd: ltLocal, Op: OpRead}))
+	assert.Nil(t, et.Monitor(Event{Fd: etLocal, Op: OpRead}))
+	syscall.Write(ltRemote, []byte("ping"))
+	syscall.Write(etRemote, []byte("ping"))
+
+	for i := 0; i < 2; i++ {
+		events, _ := lt.WaitTimeout(testWaitTimeout)
+		assert.Len(t, events, 1)
+	}
+	events, _ := et.WaitTimeout(testWaitTimeout)
+	assert.Len(t, events, 1)
+	events, _ = et.WaitTimeout(testWaitTimeout)
+	assert.Len(t, events, 0)
+}
+
+func TestEpoll_EdgeTriggeredWritable(t *testing.T) {
+	ep := newEpoll(t, EdgeTriggered)
+	local, remote := newSocketPair(t)
+	assert.Nil(t, ep.Monitor(Event{Fd: local, Op: OpRead | OpWrite}))
+	events, _ := ep.WaitTimeout(testWaitTimeout)
+	assert.EqualValues(t, []Event{{Fd: local, Op: OpWrite}}, events)
+
+	// fill the send buffer until EAGAIN
+	chunk := make([]byte, 64*1024)
+	for {
+		if _, err := syscall.Write(local, chunk); err == syscall.EAGAIN {
+			break
+		}
+	}
+	events, _ = ep.WaitTimeout(testWaitTimeout)
+	assert.Len(t, events, 0)
+
+	// the peer drains the buffer, the fd becomes writable again
+	for {
+		if _, err := syscall.Read(remote, chunk); err == syscall.EAGAIN {
+			break
+		}
+	}
+	events, _ = ep.WaitTimeout(testWaitTimeout)
+	assert.EqualValues(t, []Event{{Fd: local, Op: OpWrite}}, events)
+}
@@ -1,10 +1,21 @@
 package io_multiplexing
 
-const OpRead = 0
-const OpWrite = 1
+import "time"
+
+// Operations are bit flags, so a fd can be monitored for OpRead|OpWrite
+const OpRead = 1
+const OpWrite = 2
 
 type Operation uint32
 
+// Trigger modes of a multiplexer.
+// In edge-triggered mode an event is reported only when the fd becomes ready,
+// so the caller must read or write until EAGAIN before waiting again.
+const LevelTriggered = 0
+const EdgeTriggered = 1
+
+type TriggerMode int
+
 type Event struct {
 	Fd int
 	Op Operation
@@ -12,6 +23,13 @@ type Event struct {
 
 type IOMultiplexer interface {
 	Monitor(event Event) error
+	// Modify replaces the operations monitored on event.Fd with event.Op
+	Modify(event Event) error
+	// Unmonitor removes fd from the monitoring list, it must be called before closing fd
+	Unmonitor(fd int) error
 	Wait() ([]Event, error)
+	// WaitTimeout is like Wait but returns no event after timeout, a negative timeout blocks forever
+	WaitTimeout(timeout time.Duration) ([]Event, error)
+	Mode() TriggerMode
 	Close() error
 }
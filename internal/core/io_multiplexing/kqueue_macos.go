@@ -6,15 +6,21 @@ import (
 	"Nietzsche/internal/config"
 	"log"
 	"syscall"
+	"time"
 )
 
 type KQueue struct {
 	fd            int
+	mode          TriggerMode
 	kqEvents      []syscall.Kevent_t
 	genericEvents []Event
 }
 
 func CreateIOMultiplexer() (*KQueue, error) {
+	return CreateIOMultiplexerWithMode(LevelTriggered)
+}
+
+func CreateIOMultiplexerWithMode(mode TriggerMode) (*KQueue, error) {
 	epollFD, err := syscall.Kqueue()
 	if err != nil {
 		log.Fatal(err)
@@ -23,20 +29,60 @@ func CreateIOMultiplexer() (*KQueue, error) {
 
 	return &KQueue{
 		fd:            epollFD,
-		kqEvents:      make([]syscall.Kevent_t, config.MaxConnection),
-		genericEvents: make([]Event, config.MaxConnection),
+		mode:          mode,
+		kqEvents:      make([]syscall.Kevent_t, config.MaxEventsPerLoop),
+		genericEvents: make([]Event, config.MaxEventsPerLoop),
 	}, nil
 }
 
+func (kq *KQueue) addFlags() uint16 {
+	if kq.mode == EdgeTriggered {
+		return syscall.EV_ADD | syscall.EV_CLEAR
+	}
+	return syscall.EV_ADD
+}
+
+// remove deletes the filters of event.Op, a filter that was never added fails with ENOENT, which is fine
+func (kq *KQueue) remove(event Event) error {
+	for _, kqEvent := range event.toNative(syscall.EV_DELETE) {
+		if _, err := syscall.Kevent(kq.fd, []syscall.Kevent_t{kqEvent}, nil, nil); err != nil && err != syscall.ENOENT {
+			return err
+		}
+	}
+	return nil
+}
+
 func (kq *KQueue) Monitor(event Event) error {
-	kqEvent := event.toNative(syscall.EV_ADD)
+	kqEvents := event.toNative(kq.addFlags())
 	// Add event.Fd to the monitoring list of kq.fd
-	_, err := syscall.Kevent(kq.fd, []syscall.Kevent_t{kqEvent}, nil, nil)
+	_, err := syscall.Kevent(kq.fd, kqEvents, nil, nil)
 	return err
 }
 
+func (kq *KQueue) Modify(event Event) error {
+	// kqueue has no "modify": add the filters in event.Op and delete the others
+	if err := kq.remove(Event{Fd: event.Fd, Op: (OpRead | OpWrite) &^ event.Op}); err != nil {
+		return err
+	}
+	_, err := syscall.Kevent(kq.fd, event.toNative(kq.addFlags()), nil, nil)
+	return err
+}
+
+func (kq *KQueue) Unmonitor(fd int) error {
+	return kq.remove(Event{Fd: fd, Op: OpRead | OpWrite})
+}
+
 func (kq *KQueue) Wait() ([]Event, error) {
-	n, err := syscall.Kevent(kq.fd, nil, kq.kqEvents, nil)
+	return kq.WaitTimeout(-1)
+}
+
+func (kq *KQueue) WaitTimeout(timeout time.Duration) ([]Event, error) {
+	var ts *syscall.Timespec
+	if timeout >= 0 {
+		t := syscall.NsecToTimespec(timeout.Nanoseconds())
+		ts = &t
+	}
+	n, err := syscall.Kevent(kq.fd, nil, kq.kqEvents, ts)
 	if err != nil {
 		return nil, err
 	}
@@ -47,6 +93,10 @@ func (kq *KQueue) Wait() ([]Event, error) {
 	return kq.genericEvents[:n], nil
 }
 
+func (kq *KQueue) Mode() TriggerMode {
+	return kq.mode
+}
+
 func (kq *KQueue) Close() error {
 	return syscall.Close(kq.fd)
 }
@@ -5,9 +5,12 @@ package io_multiplexing
 import "syscall"
 
 func (e Event) toNative() syscall.EpollEvent {
-	var event uint32 = syscall.EPOLLIN
-	if e.Op == OpWrite {
-		event = syscall.EPOLLOUT
+	var event uint32
+	if e.Op&OpRead != 0 {
+		event |= syscall.EPOLLIN
+	}
+	if e.Op&OpWrite != 0 {
+		event |= syscall.EPOLLOUT
 	}
 	return syscall.EpollEvent{
 		Fd:     int32(e.Fd),
@@ -16,9 +19,13 @@ func (e Event) toNative() syscall.EpollEvent {
 }
 
 func createEvent(ep syscall.EpollEvent) Event {
-	var op Operation = OpRead
-	if ep.Events == syscall.EPOLLOUT {
-		op = OpWrite
+	var op Operation
+	// errors and hang-ups are reported as readable so that the next read returns the error
+	if ep.Events&(syscall.EPOLLIN|syscall.EPOLLHUP|syscall.EPOLLERR) != 0 {
+		op |= OpRead
+	}
+	if ep.Events&syscall.EPOLLOUT != 0 {
+		op |= OpWrite
 	}
 	return Event{
 		Fd: int(ep.Fd),
@@ -4,16 +4,24 @@ package io_multiplexing
 
 import "syscall"
 
-func (e Event) toNative(flags uint16) syscall.Kevent_t {
-	var filter int16 = syscall.EVFILT_WRITE
-	if e.Op == OpRead {
-		filter = syscall.EVFILT_READ
+// toNative returns one kevent per filter, since kqueue monitors read and write separately
+func (e Event) toNative(flags uint16) []syscall.Kevent_t {
+	var res []syscall.Kevent_t
+	if e.Op&OpRead != 0 {
+		res = append(res, syscall.Kevent_t{
+			Ident:  uint64(e.Fd),
+			Filter: syscall.EVFILT_READ,
+			Flags:  flags,
+		})
 	}
-	return syscall.Kevent_t{
-		Ident:  uint64(e.Fd),
-		Filter: filter,
-		Flags:  flags,
+	if e.Op&OpWrite != 0 {
+		res = append(res, syscall.Kevent_t{
+			Ident:  uint64(e.Fd),
+			Filter: syscall.EVFILT_WRITE,
+			Flags:  flags,
+		})
 	}
+	return res
 }
 
 func createEvent(kq syscall.Kevent_t) Event {
@@ -1,14 +1,25 @@
 package core
 
 import (
+	"Nietzsche/internal/config"
 	"bytes"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
 const CRLF string = "\r\n"
 
+// Limits applied to client input, same as the defaults of Redis (see networking.c).
+// The length of a bulk string is limited by config.ProtoMaxBulkLen.
+const maxLineLength = 64 * 1024
+const maxMultiBulkLength = 1024 * 1024
+
+// ErrProtocol is wrapped by every error caused by malformed client input.
+// The connection can not be resynchronized after such an error and must be closed.
+var ErrProtocol = errors.New("Protocol error")
+
 var RespNil = []byte("$-1\r\n")
 
 // +OK\r\n => OK, 5
@@ -51,14 +62,22 @@ func readLen(data []byte) (int, int) {
 }
 
 // $5\r\nhello\r\n => "hello"
-func readBulkString(data []byte) (string, int, error) {
+func readBulkString(data []byte) (interface{}, int, error) {
 	length, pos := readLen(data)
+	if length < 0 {
+		// null bulk string
+		return nil, pos, nil
+	}
 	return string(data[pos:(pos + length)]), pos + length + 2, nil
 }
 
 // *2\r\n$5\r\nhello\r\n$5\r\nworld\r\n => {"hello", "world"}
 func readArray(data []byte) (interface{}, int, error) {
 	length, pos := readLen(data)
+	if length < 0 {
+		// null array
+		return nil, pos, nil
+	}
 	var res []interface{} = make([]interface{}, length)
 
 	for i := range res {
@@ -157,3 +176,270 @@ func ParseCmd(data []byte) (*Command, error) {
 	res := &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}
 	return res, nil
 }
+
+func protocolError(reason string) error {
+	return fmt.Errorf("%w: %s", ErrProtocol, reason)
+}
+
+// lineEnd returns the index of the first "\r\n" in data starting from pos, or -1 if
+// data does not contain a complete line yet.
+func lineEnd(data []byte, pos int) (int, error) {
+	idx := bytes.Index(data[pos:], []byte(CRLF))
+	if idx < 0 {
+		if len(data)-pos > maxLineLength {
+			return -1, protocolError("too big request line")
+		}
+		return -1, nil
+	}
+	return pos + idx, nil
+}
+
+// frameHeader parses the integer of a '$' or '*' header line starting at pos.
+// It returns the integer and the position right after the header line, or -1 if incomplete.
+func frameHeader(data []byte, pos int) (int64, int, error) {
+	end, err := lineEnd(data, pos)
+	if err != nil || end < 0 {
+		return 0, -1, err
+	}
+	n, err := strconv.ParseInt(string(data[pos+1:end]), 10, 64)
+	if err != nil {
+		if data[pos] == '$' {
+			return 0, -1, protocolError("invalid bulk length")
+		}
+		return 0, -1, protocolError("invalid multibulk length")
+	}
+	return n, end + 2, nil
+}
+
+// frameEnd returns the position right after the RESP frame starting at pos,
+// or -1 if data holds only a prefix of the frame. It is used on the replies of the workers,
+// whose nested arrays are built by the server; client input is parsed by CommandReader.
+func frameEnd(data []byte, pos int) (int, error) {
+	if pos >= len(data) {
+		return -1, nil
+	}
+	switch data[pos] {
+	case '+', '-', ':':
+		end, err := lineEnd(data, pos)
+		if err != nil || end < 0 {
+			return -1, err
+		}
+		return end + 2, nil
+	case '$':
+		length, next, err := frameHeader(data, pos)
+		if err != nil || next < 0 {
+			return -1, err
+		}
+		if length < 0 {
+			return next, nil
+		}
+		if length > int64(config.ProtoMaxBulkLen) {
+			return -1, protocolError("invalid bulk length")
+		}
+		if int64(len(data)-next) < length+2 {
+			return -1, nil
+		}
+		end := next + int(length)
+		if data[end] != '\r' || data[end+1] != '\n' {
+			return -1, protocolError("expected '\\r\\n' after bulk string")
+		}
+		return end + 2, nil
+	case '*':
+		count, next, err := frameHeader(data, pos)
+		if err != nil || next < 0 {
+			return -1, err
+		}
+		if count > maxMultiBulkLength {
+			return -1, protocolError("invalid multibulk length")
+		}
+		for i := int64(0); i < count; i++ {
+			next, err = frameEnd(data, next)
+			if err != nil || next < 0 {
+				return -1, err
+			}
+		}
+		return next, nil
+	}
+	return -1, protocolError(fmt.Sprintf("unexpected byte '%c'", data[pos]))
+}
+
+// CommandReader is the per-connection input buffer. Bytes read from the socket are
+// appended with Feed, and complete commands are taken out one by one with Next,
+// so a command may arrive split across several reads and a single read may carry
+// many pipelined commands.
+type CommandReader struct {
+	buf []byte
+	pos int // start of the first unconsumed byte in buf
+	// The array command being parsed, kept between the calls to Next so that the bytes of a
+	// command arriving in many reads are scanned once, like the multibulk state of a Redis client.
+	// The bulk strings already parsed are consumed from buf and kept in tokens.
+	multibulkLen int // number of bulk strings not parsed yet, 0 if no array command is in progress
+	bulkLen      int // length of the next bulk string, -1 if its header is not parsed yet
+	tokens       []string
+	err          error // set by Feed when the input buffer exceeds its limit
+}
+
+func NewCommandReader() *CommandReader {
+	return &CommandReader{}
+}
+
+// Feed appends data received from the connection to the input buffer. It returns an error
+// wrapping ErrProtocol once the unconsumed input exceeds config.ClientQueryBufferLimit, the data
+// is then dropped and Next returns the error after the complete commands.
+func (r *CommandReader) Feed(data []byte) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.Buffered()+len(data) > config.ClientQueryBufferLimit {
+		r.err = protocolError("client query buffer limit exceeded")
+		return r.err
+	}
+	if r.pos > 0 && r.pos == len(r.buf) {
+		// everything has been consumed, reuse the buffer from the beginning
+		r.buf = r.buf[:0]
+		r.pos = 0
+	} else if r.pos > 0 && r.pos >= cap(r.buf)/2 {
+		// compact to keep the pending part of the buffer from growing forever
+		n := copy(r.buf, r.buf[r.pos:])
+		r.buf = r.buf[:n]
+		r.pos = 0
+	}
+	r.buf = append(r.buf, data...)
+	return nil
+}
+
+// Buffered returns the number of bytes that have been received but not consumed yet.
+func (r *CommandReader) Buffered() int {
+	return len(r.buf) - r.pos
+}
+
+// Next returns the next complete command in the buffer. It returns nil, nil when
+// the buffer holds no complete command; the remaining bytes are kept until more data arrives.
+// An error wrapping ErrProtocol means the input is malformed.
+func (r *CommandReader) Next() (*Command, error) {
+	for r.multibulkLen > 0 || r.pos < len(r.buf) {
+		if r.multibulkLen == 0 {
+			data := r.buf[r.pos:]
+			if data[0] != '*' {
+				// inline command, e.g. "PING\r\n" typed in telnet
+				end := bytes.IndexByte(data, '\n')
+				if end < 0 {
+					if len(data) > maxLineLength {
+						return nil, protocolError("too big inline request")
+					}
+					return nil, r.err
+				}
+				tokens := strings.Fields(string(data[:end]))
+				r.pos += end + 1
+				// blank lines are silently skipped as Redis does
+				if len(tokens) > 0 {
+					return &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}, nil
+				}
+				continue
+			}
+			count, next, err := frameHeader(data, 0)
+			if err != nil {
+				return nil, err
+			}
+			if next < 0 {
+				return nil, r.err
+			}
+			if count > maxMultiBulkLength {
+				return nil, protocolError("invalid multibulk length")
+			}
+			r.pos += next
+			// empty arrays are silently skipped as Redis does
+			if count > 0 {
+				r.multibulkLen, r.bulkLen = int(count), -1
+				r.tokens = make([]string, 0, min(count, 1024))
+			}
+			continue
+		}
+		if ok, err := r.parseBulk(); !ok || err != nil {
+			return nil, err
+		}
+		if r.multibulkLen == 0 {
+			tokens := r.tokens
+			r.tokens = nil
+			return &Command{Cmd: strings.ToUpper(tokens[0]), Args: tokens[1:]}, nil
+		}
+	}
+	return nil, r.err
+}
+
+// parseBulk parses the next bulk string of the array command in progress. A command is a flat
+// array of bulk strings, any other element is rejected. ok is false if the bulk string is not
+// complete yet.
+func (r *CommandReader) parseBulk() (ok bool, err error) {
+	data := r.buf[r.pos:]
+	if r.bulkLen == -1 {
+		if len(data) == 0 {
+			return false, r.err
+		}
+		if data[0] != '$' {
+			return false, protocolError(fmt.Sprintf("expected '$', got '%c'", data[0]))
+		}
+		n, next, err := frameHeader(data, 0)
+		if err != nil {
+			return false, err
+		}
+		if next < 0 {
+			return false, r.err
+		}
+		if n < 0 || n > int64(config.ProtoMaxBulkLen) {
+			return false, protocolError("invalid bulk length")
+		}
+		r.bulkLen = int(n)
+		r.pos += next
+		data = data[next:]
+	}
+	if len(data) < r.bulkLen+2 {
+		return false, r.err
+	}
+	if data[r.bulkLen] != '\r' || data[r.bulkLen+1] != '\n' {
+		return false, protocolError("expected '\\r\\n' after bulk string")
+	}
+	r.tokens = append(r.tokens, string(data[:r.bulkLen]))
+	r.pos += r.bulkLen + 2
+	r.bulkLen = -1
+	r.multibulkLen--
+	return true, nil
+}
+
+// SplitArray returns the RESP encoded elements of an encoded array, without decoding them
+func SplitArray(data []byte) ([][]byte, error) {
+	if len(data) == 0 || data[0] != '*' {
+		return nil, errors.New("not an array")
+	}
+	count, pos, err := frameHeader(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pos < 0 {
+		return nil, errors.New("incomplete array")
+	}
+	items := make([][]byte, 0, count)
+	for i := int64(0); i < count; i++ {
+		end, err := frameEnd(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		if end < 0 {
+			return nil, errors.New("incomplete array")
+		}
+		items = append(items, data[pos:end])
+		pos = end
+	}
+	return items, nil
+}
+
+// EncodeRawArray encodes elements that are already RESP encoded as an array
+func EncodeRawArray(items [][]byte) []byte {
+	var b []byte
+	buf := bytes.NewBuffer(b)
+	buf.WriteString(fmt.Sprintf("*%d\r\n", len(items)))
+	for _, item := range items {
+		buf.Write(item)
+	}
+	return buf.Bytes()
+}
@@ -1,9 +1,11 @@
 package core_test
 
 import (
+	"Nietzsche/internal/config"
 	"Nietzsche/internal/core"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -114,3 +116,112 @@ func TestParseCmd(t *testing.T) {
 		}
 	}
 }
+
+func TestCommandReaderPartialFrame(t *testing.T) {
+	r := core.NewCommandReader()
+	frame := "*3\r\n$3\r\nset\r\n$5\r\nhello\r\n$5\r\nworld\r\n"
+	for i := 0; i < len(frame)-1; i++ {
+		r.Feed([]byte{frame[i]})
+		cmd, err := r.Next()
+		assert.Nil(t, err)
+		assert.Nil(t, cmd)
+	}
+	r.Feed([]byte{frame[len(frame)-1]})
+	cmd, err := r.Next()
+	assert.Nil(t, err)
+	assert.EqualValues(t, "SET", cmd.Cmd)
+	assert.EqualValues(t, []string{"hello", "world"}, cmd.Args)
+	assert.EqualValues(t, 0, r.Buffered())
+}
+
+func TestCommandReaderPipeline(t *testing.T) {
+	r := core.NewCommandReader()
+	r.Feed([]byte("*1\r\n$4\r\nping\r\n*2\r\n$3\r\nget\r\n$1\r\na\r\n*0\r\nPING hi\r\n*2\r\n$3\r\nget"))
+	var cmds []string
+	for {
+		cmd, err := r.Next()
+		assert.Nil(t, err)
+		if cmd == nil {
+			break
+		}
+		cmds = append(cmds, fmt.Sprintf("%s %v", cmd.Cmd, cmd.Args))
+	}
+	assert.EqualValues(t, []string{"PING []", "GET [a]", "PING [hi]"}, cmds)
+
+	r.Feed([]byte("\r\n$1\r\nb\r\n"))
+	cmd, err := r.Next()
+	assert.Nil(t, err)
+	assert.EqualValues(t, "GET", cmd.Cmd)
+	assert.EqualValues(t, []string{"b"}, cmd.Args)
+}
+
+func TestCommandReaderLargeBulk(t *testing.T) {
+	r := core.NewCommandReader()
+	value := strings.Repeat("x", 100000)
+	frame := fmt.Sprintf("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$%d\r\n%s\r\n", len(value), value)
+	for i := 0; i < len(frame); i += 512 {
+		end := i + 512
+		if end > len(frame) {
+			end = len(frame)
+		}
+		r.Feed([]byte(frame[i:end]))
+	}
+	cmd, err := r.Next()
+	assert.Nil(t, err)
+	assert.EqualValues(t, value, cmd.Args[1])
+}
+
+func TestCommandReaderProtocolError(t *testing.T) {
+	cases := []string{
+		"*1\r\n$x\r\n",
+		"*1\r\n:1\r\n",
+		"*1\r\n$3\r\nabcd\r\n",
+		"*1\r\n*1\r\n$1\r\na\r\n",
+	}
+	for _, c := range cases {
+		r := core.NewCommandReader()
+		r.Feed([]byte(c))
+		_, err := r.Next()
+		assert.ErrorIs(t, err, core.ErrProtocol, c)
+	}
+}
+
+func TestCommandReaderNestedArrays(t *testing.T) {
+	// a command is a flat array, nested arrays are rejected without being walked
+	r := core.NewCommandReader()
+	r.Feed([]byte(strings.Repeat("*1\r\n", 1000000)))
+	_, err := r.Next()
+	assert.EqualError(t, err, "Protocol error: expected '$', got '*'")
+}
+
+func TestCommandReaderQueryBufferLimit(t *testing.T) {
+	old := config.ClientQueryBufferLimit
+	config.ClientQueryBufferLimit = 100
+	t.Cleanup(func() { config.ClientQueryBufferLimit = old })
+
+	r := core.NewCommandReader()
+	assert.Nil(t, r.Feed([]byte("*1\r\n$4\r\nping\r\n*2\r\n$3\r\nget\r\n$200\r\n")))
+	err := r.Feed([]byte(strings.Repeat("x", 100)))
+	assert.ErrorIs(t, err, core.ErrProtocol)
+	// the commands received before the limit are still returned, then the error
+	cmd, err := r.Next()
+	assert.Nil(t, err)
+	assert.EqualValues(t, "PING", cmd.Cmd)
+	_, err = r.Next()
+	assert.EqualError(t, err, "Protocol error: client query buffer limit exceeded")
+}
+
+func TestCommandReaderIncremental(t *testing.T) {
+	// the parsed bulk strings of a command are consumed before the command is complete
+	r := core.NewCommandReader()
+	r.Feed([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\nva"))
+	cmd, err := r.Next()
+	assert.Nil(t, err)
+	assert.Nil(t, cmd)
+	assert.EqualValues(t, 2, r.Buffered(), "only the partial bulk string is buffered")
+	r.Feed([]byte("lue\r\n"))
+	cmd, err = r.Next()
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"k", "value"}, cmd.Args)
+	assert.EqualValues(t, 0, r.Buffered())
+}
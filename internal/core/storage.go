@@ -2,16 +2,33 @@ package core
 
 import "Nietzsche/internal/data_structure"
 
-var dictStore *data_structure.Dict
-var zsetStore map[string]*data_structure.ZSet
-var setStore map[string]*data_structure.SimpleSet
-var cmsStore map[string]*data_structure.CMS
-var bloomStore map[string]*data_structure.Bloom
+// Storage is a complete keyspace. Every key maps to one Obj tagged with the type of its value,
+// so that a key can not hold a string and a set at the same time, and all the types are
+// expired and evicted alike.
+// The single-threaded server uses defaultStorage, each Worker owns its own Storage.
+type Storage struct {
+	dictStore *data_structure.Dict
+	blocking  blockingState // clients waiting for keys, see blocking.go
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		dictStore: data_structure.CreateDict(),
+		blocking:  newBlockingState(),
+	}
+}
+
+var defaultStorage = NewStorage()
 
-func init() {
-	dictStore = data_structure.CreateDict()
-	zsetStore = make(map[string]*data_structure.ZSet)
-	setStore = make(map[string]*data_structure.SimpleSet)
-	cmsStore = make(map[string]*data_structure.CMS)
-	bloomStore = make(map[string]*data_structure.Bloom)
+// lookup returns the object of the key, nil if it does not exist or has expired.
+// It returns errWrongType if the key holds a value of another type than objType.
+func (s *Storage) lookup(key string, objType uint8) (*data_structure.Obj, error) {
+	obj := s.dictStore.Get(key)
+	if obj == nil {
+		return nil, nil
+	}
+	if obj.Type != objType {
+		return nil, errWrongType
+	}
+	return obj, nil
 }
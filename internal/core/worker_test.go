@@ -0,0 +1,48 @@
+package core_test
+
+import (
+	"Nietzsche/internal/core"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func execOnWorker(w *core.Worker, cmd string, args ...string) string {
+	replyCh := make(chan []byte, 1)
+	w.TaskCh <- &core.Task{Command: &core.Command{Cmd: cmd, Args: args}, ReplyCh: replyCh}
+	return string(<-replyCh)
+}
+
+func TestWorker_AllCommandTypes(t *testing.T) {
+	w := core.NewWorker(0, 16)
+	assert.EqualValues(t, "+OK\r\n", execOnWorker(w, "SET", "k", "v"))
+	assert.EqualValues(t, ":2\r\n", execOnWorker(w, "ZADD", "z", "1", "a", "2", "b"))
+	assert.EqualValues(t, ":1\r\n", execOnWorker(w, "ZRANK", "z", "b"))
+	assert.EqualValues(t, ":1\r\n", execOnWorker(w, "SADD", "s", "a"))
+	assert.EqualValues(t, ":1\r\n", execOnWorker(w, "SISMEMBER", "s", "a"))
+	assert.EqualValues(t, "+OK\r\n", execOnWorker(w, "CMS.INITBYDIM", "c", "10", "2"))
+	assert.EqualValues(t, "*1\r\n$1\r\n3\r\n", execOnWorker(w, "CMS.INCRBY", "c", "x", "3"))
+	assert.EqualValues(t, "*1\r\n$1\r\n1\r\n", execOnWorker(w, "BF.MADD", "b", "x"))
+	assert.EqualValues(t, ":1\r\n", execOnWorker(w, "BF.EXISTS", "b", "x"))
+	assert.Contains(t, execOnWorker(w, "INFO"), "db0:keys=5,")
+
+	// workers own independent keyspaces
+	other := core.NewWorker(1, 16)
+	assert.EqualValues(t, "$-1\r\n", execOnWorker(other, "GET", "k"))
+}
+
+func TestWorker_StopRunsQueuedTasks(t *testing.T) {
+	w := core.NewWorker(0, 16)
+	release := make(chan struct{})
+	// hold the worker so that the next tasks stay in its queue
+	w.TaskCh <- &core.Task{Fn: func(*core.Storage) { <-release }}
+	replyChs := make([]chan []byte, 5)
+	for i := range replyChs {
+		replyChs[i] = make(chan []byte, 1)
+		w.TaskCh <- &core.Task{Command: &core.Command{Cmd: "SADD", Args: []string{"s", string(rune('a' + i))}}, ReplyCh: replyChs[i]}
+	}
+	close(release)
+	w.Stop()
+	for _, replyCh := range replyChs {
+		assert.EqualValues(t, ":1\r\n", string(<-replyCh))
+	}
+}
@@ -0,0 +1,128 @@
+package data_structure
+
+import "math/bits"
+
+// Bitmaps are string values seen as arrays of bits. Unlike the bits of Bloom.bf, the bit 0 is
+// the most significant bit of the first byte, as in Redis, so that the bitmaps are interchangeable.
+
+// GetBit returns the bit at offset, the bits after the end of b are 0
+func GetBit(b []byte, offset uint64) int {
+	byteIdx := offset >> 3
+	if byteIdx >= uint64(len(b)) {
+		return 0
+	}
+	return int(b[byteIdx]>>(7-offset&7)) & 1
+}
+
+// SetBit sets the bit at offset to on, b is grown with zero bytes to hold the offset.
+// It returns the updated slice, which may have been reallocated, and the previous bit.
+func SetBit(b []byte, offset uint64, on bool) ([]byte, int) {
+	byteIdx := offset >> 3
+	if byteIdx >= uint64(len(b)) {
+		// append grows the buffer geometrically, so that setting increasing offsets is amortized O(1)
+		b = append(b, make([]byte, byteIdx+1-uint64(len(b)))...)
+	}
+	mask := byte(1) << (7 - offset&7)
+	old := 0
+	if b[byteIdx]&mask != 0 {
+		old = 1
+	}
+	if on {
+		b[byteIdx] |= mask
+	} else {
+		b[byteIdx] &^= mask
+	}
+	return b, old
+}
+
+// BitCount returns the number of bits set to 1
+func BitCount(b []byte) int64 {
+	var count int
+	for len(b) >= 8 {
+		count += bits.OnesCount64(uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24 |
+			uint64(b[4])<<32 | uint64(b[5])<<40 | uint64(b[6])<<48 | uint64(b[7])<<56)
+		b = b[8:]
+	}
+	for _, c := range b {
+		count += bits.OnesCount8(c)
+	}
+	return int64(count)
+}
+
+// BitPos returns the position of the first bit equal to bit. If there is none, it returns -1
+// when looking for a 1, and the position following the last bit when looking for a 0,
+// like the zero bits padding the string.
+func BitPos(b []byte, bit int) int64 {
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for i, c := range b {
+		if c != skip {
+			if bit == 0 {
+				c = ^c
+			}
+			return int64(i)*8 + int64(bits.LeadingZeros8(c))
+		}
+	}
+	if bit == 1 {
+		return -1
+	}
+	return int64(len(b)) * 8
+}
+
+// BitOp computes AND, OR, XOR or NOT of the sources, NOT has a single source.
+// The result is as long as the longest source, the shorter ones are padded with zero bytes.
+func BitOp(op string, sources [][]byte) []byte {
+	maxLen := 0
+	for _, src := range sources {
+		maxLen = max(maxLen, len(src))
+	}
+	res := make([]byte, maxLen)
+	if op == "NOT" {
+		for i, c := range sources[0] {
+			res[i] = ^c
+		}
+		return res
+	}
+	copy(res, sources[0])
+	for _, src := range sources[1:] {
+		for i := range res {
+			var c byte
+			if i < len(src) {
+				c = src[i]
+			}
+			switch op {
+			case "AND":
+				res[i] &= c
+			case "OR":
+				res[i] |= c
+			case "XOR":
+				res[i] ^= c
+			}
+		}
+	}
+	return res
+}
+
+// GetBitfield returns the integer of width bits stored at offset, most significant bit first.
+// A signed integer is sign extended from its highest bit.
+func GetBitfield(b []byte, offset uint64, width int, signed bool) int64 {
+	var v uint64
+	for i := 0; i < width; i++ {
+		v = v<<1 | uint64(GetBit(b, offset+uint64(i)))
+	}
+	if signed && width < 64 {
+		return int64(v<<(64-width)) >> (64 - width)
+	}
+	return int64(v)
+}
+
+// SetBitfield stores the low width bits of value at offset, b is grown like in SetBit.
+// It returns the updated slice.
+func SetBitfield(b []byte, offset uint64, width int, value int64) []byte {
+	for i := 0; i < width; i++ {
+		b, _ = SetBit(b, offset+uint64(i), uint64(value)>>(width-1-i)&1 == 1)
+	}
+	return b
+}
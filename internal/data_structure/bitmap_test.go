@@ -0,0 +1,54 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSetBit_GetBit(t *testing.T) {
+	var b []byte
+	b, old := SetBit(b, 7, true)
+	assert.EqualValues(t, 0, old)
+	assert.EqualValues(t, []byte{0x01}, b)
+	b, _ = SetBit(b, 8, true)
+	assert.EqualValues(t, []byte{0x01, 0x80}, b)
+	b, old = SetBit(b, 7, false)
+	assert.EqualValues(t, 1, old)
+	assert.EqualValues(t, []byte{0x00, 0x80}, b)
+	assert.EqualValues(t, 1, GetBit(b, 8))
+	assert.EqualValues(t, 0, GetBit(b, 9))
+	assert.EqualValues(t, 0, GetBit(b, 1000))
+}
+
+func TestBitCount_BitPos(t *testing.T) {
+	b := []byte("foobar-foobar")
+	assert.EqualValues(t, 56, BitCount(b))
+	assert.EqualValues(t, 0, BitCount(nil))
+	assert.EqualValues(t, 12, BitPos([]byte{0xff, 0xf0, 0x00}, 0))
+	assert.EqualValues(t, 24, BitPos([]byte{0xff, 0xff, 0xff}, 0))
+	assert.EqualValues(t, 10, BitPos([]byte{0x00, 0x20}, 1))
+	assert.EqualValues(t, -1, BitPos([]byte{0x00, 0x00}, 1))
+}
+
+func TestBitOp(t *testing.T) {
+	a, b := []byte{0xf0, 0xff}, []byte{0x3c}
+	assert.EqualValues(t, []byte{0x30, 0x00}, BitOp("AND", [][]byte{a, b}))
+	assert.EqualValues(t, []byte{0xfc, 0xff}, BitOp("OR", [][]byte{a, b}))
+	assert.EqualValues(t, []byte{0xcc, 0xff}, BitOp("XOR", [][]byte{a, b}))
+	assert.EqualValues(t, []byte{0x0f, 0x00}, BitOp("NOT", [][]byte{a}))
+	assert.Empty(t, BitOp("OR", [][]byte{nil, nil}))
+}
+
+func TestBitfield(t *testing.T) {
+	var b []byte
+	b = SetBitfield(b, 4, 8, 0xab)
+	assert.EqualValues(t, []byte{0x0a, 0xb0}, b)
+	assert.EqualValues(t, 0xab, GetBitfield(b, 4, 8, false))
+	assert.EqualValues(t, -85, GetBitfield(b, 4, 8, true))
+	assert.EqualValues(t, 0x0a, GetBitfield(b, 0, 8, true))
+	assert.EqualValues(t, 0xb000, GetBitfield(b, 8, 16, false))
+
+	b = SetBitfield(nil, 0, 64, -1)
+	assert.EqualValues(t, -1, GetBitfield(b, 0, 64, true))
+	assert.EqualValues(t, 1<<63-1, GetBitfield(b, 1, 63, false))
+}
@@ -106,3 +106,10 @@ func (b *Bloom) ExistHash(initHash HashValue) bool {
 	}
 	return true
 }
+
+// Clone returns a copy of the filter
+func (b *Bloom) Clone() *Bloom {
+	clone := *b
+	clone.bf = append([]uint8(nil), b.bf...)
+	return &clone
+}
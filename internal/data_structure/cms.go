@@ -95,3 +95,12 @@ func (c *CMS) Count(item string) uint32 {
 	}
 	return minCount
 }
+
+// Clone returns a copy of the sketch with the same dimensions and counters.
+func (c *CMS) Clone() *CMS {
+	clone := CreateCMS(c.width, c.depth)
+	for i := range c.counter {
+		copy(clone.counter[i], c.counter[i])
+	}
+	return clone
+}
@@ -3,27 +3,233 @@ package data_structure
 import (
 	"Nietzsche/internal/config"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Types of an Obj, like the OBJ_* types of Redis
+const (
+	ObjTypeString = iota // Value is encoded as EncodingRaw or EncodingInt
+	ObjTypeZSet          // Value is a *ZSet
+	ObjTypeSet           // Value is a *SimpleSet
+	ObjTypeCMS           // Value is a *CMS
+	ObjTypeBloom         // Value is a *Bloom
+	ObjTypeHash          // Value is a *Hash
+	ObjTypeList          // Value is a *Quicklist
+	ObjTypeStream        // Value is a *Stream
+)
+
+// objTypeNames are the type names reported by TYPE, the names of the probabilistic types
+// are the ones of the RedisBloom module
+var objTypeNames = map[uint8]string{
+	ObjTypeString: "string",
+	ObjTypeZSet:   "zset",
+	ObjTypeSet:    "set",
+	ObjTypeCMS:    "CMSk-TYPE",
+	ObjTypeBloom:  "MBbloom--",
+	ObjTypeHash:   "hash",
+	ObjTypeList:   "list",
+	ObjTypeStream: "stream",
+}
+
+// Encodings of an Obj, like the OBJ_ENCODING_* of Redis
+const (
+	EncodingRaw       = iota // Value is a string, or a []byte once modified in place by APPEND or SETRANGE
+	EncodingInt              // Value is an int64, so that counters are not parsed on every increment
+	EncodingHashtable        // Value is a set or a hash backed by a map
+	EncodingSkiplist         // Value is a sorted set backed by a skiplist and a map
+	EncodingListpack         // Value is a small collection serialized in a Listpack
+	EncodingQuicklist        // Value is a list backed by a linked list of listpacks
+	EncodingStream           // Value is a stream backed by a radix tree of listpacks
+)
+
 type Obj struct {
 	Value          interface{}
+	Type           uint8
+	Encoding       uint8
 	LastAccessTime uint32
 }
 
+// TypeName returns the name of the type of the object, as reported by TYPE
+func (o *Obj) TypeName() string {
+	return objTypeNames[o.Type]
+}
+
+// ObjTypeByName returns the type with the given name, ignoring the case, ok is false if there is none
+func ObjTypeByName(name string) (objType uint8, ok bool) {
+	for t, typeName := range objTypeNames {
+		if strings.EqualFold(typeName, name) {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+func newObj(objType, encoding uint8, value interface{}) *Obj {
+	return &Obj{Value: value, Type: objType, Encoding: encoding, LastAccessTime: now()}
+}
+
+// NewZSetObj creates a sorted set object
+func NewZSetObj(zset *ZSet) *Obj {
+	return newObj(ObjTypeZSet, EncodingSkiplist, zset)
+}
+
+// NewSetObj creates a set object
+func NewSetObj(set *SimpleSet) *Obj {
+	return newObj(ObjTypeSet, EncodingHashtable, set)
+}
+
+// NewHashObj creates a hash object
+func NewHashObj(hash *Hash) *Obj {
+	return newObj(ObjTypeHash, hash.Encoding(), hash)
+}
+
+// NewListObj creates a list object
+func NewListObj(list *Quicklist) *Obj {
+	return newObj(ObjTypeList, EncodingQuicklist, list)
+}
+
+// NewStreamObj creates a stream object
+func NewStreamObj(stream *Stream) *Obj {
+	return newObj(ObjTypeStream, EncodingStream, stream)
+}
+
+// NewCMSObj creates a Count-Min Sketch object
+func NewCMSObj(cms *CMS) *Obj {
+	return newObj(ObjTypeCMS, EncodingRaw, cms)
+}
+
+// NewBloomObj creates a Bloom filter object
+func NewBloomObj(bloom *Bloom) *Obj {
+	return newObj(ObjTypeBloom, EncodingRaw, bloom)
+}
+
+// Clone returns a deep copy of the object, used by COPY
+func (o *Obj) Clone() *Obj {
+	clone := newObj(o.Type, o.Encoding, o.Value)
+	switch v := o.Value.(type) {
+	case []byte:
+		clone.Value = append([]byte(nil), v...)
+	case *ZSet:
+		clone.Value = v.Clone()
+	case *SimpleSet:
+		clone.Value = v.Clone()
+	case *CMS:
+		clone.Value = v.Clone()
+	case *Bloom:
+		clone.Value = v.Clone()
+	case *Hash:
+		clone.Value = v.Clone()
+	case *Quicklist:
+		clone.Value = v.Clone()
+	case *Stream:
+		clone.Value = v.Clone()
+	}
+	return clone
+}
+
+// NewStringObj creates a string object, int encoded if the value is the canonical
+// representation of an int64 (no sign "+", no leading zero...)
+func NewStringObj(value string) *Obj {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(n, 10) == value {
+		return NewIntObj(n)
+	}
+	return newObj(ObjTypeString, EncodingRaw, value)
+}
+
+// NewIntObj creates an int encoded string object
+func NewIntObj(n int64) *Obj {
+	return newObj(ObjTypeString, EncodingInt, n)
+}
+
+// StringValue returns the value of a string object, whatever its encoding
+func (o *Obj) StringValue() string {
+	switch v := o.Value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+// Len returns the length of the value of a string object
+func (o *Obj) Len() int {
+	switch v := o.Value.(type) {
+	case int64:
+		return len(strconv.FormatInt(v, 10))
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	}
+	return 0
+}
+
+// IntValue returns the value of a string object as an integer,
+// ok is false if it is not the canonical representation of an int64
+func (o *Obj) IntValue() (n int64, ok bool) {
+	if o.Encoding == EncodingInt {
+		return o.Value.(int64), true
+	}
+	s := o.StringValue()
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil && strconv.FormatInt(n, 10) == s
+}
+
+// MutableBytes converts the value of a string object to a raw []byte, which can be modified
+// in place and grown with append. The caller stores the modified slice back with SetBytes.
+func (o *Obj) MutableBytes() []byte {
+	if b, ok := o.Value.([]byte); ok {
+		return b
+	}
+	b := []byte(o.StringValue())
+	o.SetBytes(b)
+	return b
+}
+
+// SetBytes replaces the value of the object by a raw []byte
+func (o *Obj) SetBytes(b []byte) {
+	o.Value = b
+	o.Encoding = EncodingRaw
+}
+
+// SetInt replaces the value of the object by an int encoded integer
+func (o *Obj) SetInt(n int64) {
+	o.Value = n
+	o.Encoding = EncodingInt
+}
+
 type Dict struct {
 	dictStore        map[string]*Obj
 	expiredDictStore map[string]uint64
+	stat             KeySpaceStat
+	ePool            *EvictionPool
+	scanIndex        *ScanIndex
+	// volatileHashes are the keys holding a hash that may have fields with a time to live,
+	// sampled by the active expire cycle
+	volatileHashes map[string]struct{}
 }
 
 func CreateDict() *Dict {
 	res := Dict{
 		dictStore:        make(map[string]*Obj),
 		expiredDictStore: make(map[string]uint64),
+		ePool:            newEpool(0),
+		scanIndex:        NewScanIndex(),
+		volatileHashes:   make(map[string]struct{}),
 	}
 	return &res
 }
 
+// Stat returns the key counters of the dict
+func (d *Dict) Stat() KeySpaceStat {
+	return d.stat
+}
+
 func (d *Dict) GetExpireDictStore() map[string]uint64 {
 	return d.expiredDictStore
 }
@@ -32,19 +238,23 @@ func (d *Dict) GetDictStore() map[string]*Obj {
 	return d.dictStore
 }
 
-func now() uint32 {
-	return uint32(time.Now().Unix())
+// GetVolatileHashes returns the keys that may hold a hash with fields having a time to live
+func (d *Dict) GetVolatileHashes() map[string]struct{} {
+	return d.volatileHashes
 }
 
-func (d *Dict) NewObj(key string, value interface{}, ttlMs int64) *Obj {
-	obj := &Obj{
-		Value:          value,
-		LastAccessTime: now(),
-	}
-	if ttlMs > 0 {
-		d.SetExpiry(key, ttlMs)
-	}
-	return obj
+// AddVolatileHash records that the hash of the key has fields with a time to live
+func (d *Dict) AddVolatileHash(key string) {
+	d.volatileHashes[key] = struct{}{}
+}
+
+// DelVolatileHash forgets the key, once its hash has no field with a time to live
+func (d *Dict) DelVolatileHash(key string) {
+	delete(d.volatileHashes, key)
+}
+
+func now() uint32 {
+	return uint32(time.Now().Unix())
 }
 
 func (d *Dict) GetExpiry(key string) (uint64, bool) {
@@ -53,7 +263,25 @@ func (d *Dict) GetExpiry(key string) (uint64, bool) {
 }
 
 func (d *Dict) SetExpiry(key string, ttlMs int64) {
-	d.expiredDictStore[key] = uint64(time.Now().UnixMilli()) + uint64(ttlMs)
+	d.SetExpiryAt(key, uint64(time.Now().UnixMilli())+uint64(ttlMs))
+}
+
+// SetExpiryAt sets the expiry of the key to an absolute unix time in milliseconds
+func (d *Dict) SetExpiryAt(key string, expireAtMs uint64) {
+	if _, exist := d.expiredDictStore[key]; !exist {
+		d.stat.Expire++
+	}
+	d.expiredDictStore[key] = expireAtMs
+}
+
+// DelExpiry removes the expiry of the key, it reports whether the key had one
+func (d *Dict) DelExpiry(key string) bool {
+	if _, exist := d.expiredDictStore[key]; !exist {
+		return false
+	}
+	delete(d.expiredDictStore, key)
+	d.stat.Expire--
+	return true
 }
 
 func (d *Dict) HasExpired(key string) bool {
@@ -76,8 +304,22 @@ func (d *Dict) Get(k string) *Obj {
 	return v
 }
 
+// Peek returns the object of the key like Get, without updating its last access time
+func (d *Dict) Peek(k string) *Obj {
+	if d.HasExpired(k) {
+		d.Del(k)
+		return nil
+	}
+	return d.dictStore[k]
+}
+
+// evictionCount returns the number of keys evicted at once, at least one
+func evictionCount() int64 {
+	return max(1, int64(config.EvictionRatio*float64(config.MaxKeyNumber)))
+}
+
 func (d *Dict) evictRandom() {
-	evictCount := int64(config.EvictionRatio * float64(config.MaxKeyNumber))
+	evictCount := evictionCount()
 	log.Print("trigger random eviction")
 	for k := range d.dictStore {
 		d.Del(k)
@@ -91,24 +333,24 @@ func (d *Dict) evictRandom() {
 func (d *Dict) populateEpool() {
 	remain := config.EpoolLruSampleSize
 	for k := range d.dictStore {
-		ePool.Push(k, d.dictStore[k].LastAccessTime)
+		d.ePool.Push(k, d.dictStore[k].LastAccessTime)
 		remain--
 		if remain == 0 {
 			break
 		}
 	}
 	log.Println("EPool:")
-	for _, item := range ePool.pool {
+	for _, item := range d.ePool.pool {
 		log.Println(item.key, item.lastAccessTime)
 	}
 }
 
 func (d *Dict) evictLru() {
 	d.populateEpool()
-	evictCount := int64(config.EvictionRatio * float64(config.MaxKeyNumber))
+	evictCount := evictionCount()
 	log.Print("trigger LRU eviction")
-	for i := 0; i < int(evictCount) && len(ePool.pool) > 0; i++ {
-		item := ePool.Pop()
+	for i := 0; i < int(evictCount) && len(d.ePool.pool) > 0; i++ {
+		item := d.ePool.Pop()
 		if item != nil {
 			d.Del(item.key)
 		}
@@ -125,23 +367,36 @@ func (d *Dict) evict() {
 }
 
 func (d *Dict) Set(k string, obj *Obj) {
-	if len(d.dictStore) == config.MaxKeyNumber {
+	if len(d.dictStore) >= config.MaxKeyNumber {
 		d.evict()
 	}
 	v := d.dictStore[k]
 	if v == nil {
-		HashKeySpaceStat.Key++
+		d.stat.Key++
+		d.scanIndex.Add(k)
 	}
 	d.dictStore[k] = obj
+	// a hash copied or renamed with its fields' time to live
+	if h, ok := obj.Value.(*Hash); ok && h.HasVolatileFields() {
+		d.AddVolatileHash(k)
+	}
 }
 
 func (d *Dict) Del(k string) bool {
 	log.Printf("Delete key %s", k)
 	if _, exist := d.dictStore[k]; exist {
 		delete(d.dictStore, k)
-		delete(d.expiredDictStore, k)
-		HashKeySpaceStat.Key--
+		d.DelExpiry(k)
+		d.DelVolatileHash(k)
+		d.scanIndex.Remove(k)
+		d.stat.Key--
 		return true
 	}
 	return false
 }
+
+// Scan returns the keys from the cursor and the cursor of the next call, see ScanIndex.Scan.
+// The keys may have expired.
+func (d *Dict) Scan(cursor uint64, count int) ([]string, uint64) {
+	return d.scanIndex.Scan(cursor, count)
+}
@@ -0,0 +1,26 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewStringObj_Encoding(t *testing.T) {
+	obj := NewStringObj("-42")
+	assert.EqualValues(t, EncodingInt, obj.Encoding)
+	assert.EqualValues(t, int64(-42), obj.Value)
+	assert.EqualValues(t, "-42", obj.StringValue())
+
+	for _, value := range []string{"abc", "042", "+42", "-0", "4.2", "99999999999999999999"} {
+		obj = NewStringObj(value)
+		assert.EqualValues(t, EncodingRaw, obj.Encoding, value)
+		assert.EqualValues(t, value, obj.StringValue())
+		_, ok := obj.IntValue()
+		assert.False(t, ok, value)
+	}
+
+	obj.SetInt(7)
+	n, ok := obj.IntValue()
+	assert.True(t, ok)
+	assert.EqualValues(t, 7, n)
+}
@@ -52,7 +52,7 @@ func (p *EvictionPool) Push(key string, lastAccessTime uint32) {
 		p.pool = append(p.pool, newItem)
 	}
 	sort.Sort(ByLastAccessTime(p.pool))
-	if len(p.pool) > config.EpoolMaxSize {
+	for len(p.pool) > config.EpoolMaxSize {
 		lastIndex := len(p.pool) - 1
 		key = p.pool[lastIndex].key
 		p.pool = p.pool[:lastIndex]
@@ -74,5 +74,3 @@ func newEpool(size int) *EvictionPool {
 		pool: make([]*EvictionCandidate, size),
 	}
 }
-
-var ePool *EvictionPool = newEpool(0)
@@ -0,0 +1,281 @@
+package data_structure
+
+import (
+	"Nietzsche/internal/config"
+	"math/rand"
+)
+
+// Hash maps fields to values. A small hash is a listpack of alternating fields and values, which
+// is converted to a map once the hash has more than config.HashMaxListpackEntries fields or a
+// field or value longer than config.HashMaxListpackValue. Like in Redis, a hash is never
+// converted back to a listpack.
+// A field can have a time to live, like a key. The expired fields are deleted by DelExpiredFields.
+type Hash struct {
+	lp   *Listpack // nil once converted to dict
+	dict map[string]string
+	// scanIndex is built by the first Scan of a map encoded hash, then kept up to date
+	scanIndex *ScanIndex
+	// expires maps the fields with a time to live to their expiry in unix milliseconds, and
+	// expireIndex orders them by expiry. Both are created by the first SetFieldExpiry.
+	expires     map[string]uint64
+	expireIndex *Skiplist
+}
+
+func NewHash() *Hash {
+	return &Hash{lp: NewListpack()}
+}
+
+// Encoding returns EncodingListpack or EncodingHashtable
+func (h *Hash) Encoding() uint8 {
+	if h.lp != nil {
+		return EncodingListpack
+	}
+	return EncodingHashtable
+}
+
+func (h *Hash) Len() int {
+	if h.lp != nil {
+		return h.lp.Len() / 2
+	}
+	return len(h.dict)
+}
+
+// find returns the position of the field in the listpack, -1 if it does not exist
+func (h *Hash) find(field string) int {
+	for p := h.lp.First(); p != -1; p = h.lp.Next(h.lp.Next(p)) {
+		if h.lp.Equal(p, field) {
+			return p
+		}
+	}
+	return -1
+}
+
+func (h *Hash) convert() {
+	h.dict = make(map[string]string, h.lp.Len()/2)
+	for p := h.lp.First(); p != -1; p = h.lp.Next(p) {
+		v := h.lp.Next(p)
+		h.dict[h.lp.Get(p)] = h.lp.Get(v)
+		p = v
+	}
+	h.lp = nil
+}
+
+// Get returns the value of the field, ok is false if it does not exist
+func (h *Hash) Get(field string) (value string, ok bool) {
+	if h.lp != nil {
+		p := h.find(field)
+		if p == -1 {
+			return "", false
+		}
+		return h.lp.Get(h.lp.Next(p)), true
+	}
+	value, ok = h.dict[field]
+	return value, ok
+}
+
+func (h *Hash) Exists(field string) bool {
+	_, ok := h.Get(field)
+	return ok
+}
+
+// Set sets the value of the field and removes its time to live, it returns true if the field is new
+func (h *Hash) Set(field, value string) bool {
+	h.PersistField(field)
+	return h.SetKeepTTL(field, value)
+}
+
+// SetKeepTTL sets the value of the field like Set, but keeps its time to live
+func (h *Hash) SetKeepTTL(field, value string) bool {
+	if h.lp != nil {
+		p := h.find(field)
+		if len(field) > config.HashMaxListpackValue || len(value) > config.HashMaxListpackValue ||
+			(p == -1 && h.Len() >= config.HashMaxListpackEntries) {
+			h.convert()
+		} else if p != -1 {
+			h.lp.Replace(h.lp.Next(p), value)
+			return false
+		} else {
+			h.lp.Append(field)
+			h.lp.Append(value)
+			return true
+		}
+	}
+
+	_, exist := h.dict[field]
+	h.dict[field] = value
+	if !exist && h.scanIndex != nil {
+		h.scanIndex.Add(field)
+	}
+	return !exist
+}
+
+// Del deletes the field, it returns false if it does not exist
+func (h *Hash) Del(field string) bool {
+	h.PersistField(field)
+	if h.lp != nil {
+		p := h.find(field)
+		if p == -1 {
+			return false
+		}
+		h.lp.Delete(h.lp.Delete(p))
+		return true
+	}
+	if _, exist := h.dict[field]; !exist {
+		return false
+	}
+	delete(h.dict, field)
+	if h.scanIndex != nil {
+		h.scanIndex.Remove(field)
+	}
+	return true
+}
+
+// Range calls fn for every field and value, in insertion order for a listpack, until fn returns false
+func (h *Hash) Range(fn func(field, value string) bool) {
+	if h.lp != nil {
+		for p := h.lp.First(); p != -1; p = h.lp.Next(p) {
+			v := h.lp.Next(p)
+			if !fn(h.lp.Get(p), h.lp.Get(v)) {
+				return
+			}
+			p = v
+		}
+		return
+	}
+	for field, value := range h.dict {
+		if !fn(field, value) {
+			return
+		}
+	}
+}
+
+// Fields returns all the fields
+func (h *Hash) Fields() []string {
+	fields := make([]string, 0, h.Len())
+	h.Range(func(field, _ string) bool {
+		fields = append(fields, field)
+		return true
+	})
+	return fields
+}
+
+// RandomField returns a field picked at random, the hash must not be empty
+func (h *Hash) RandomField() string {
+	if h.lp != nil {
+		return h.lp.Get(h.lp.Seek(2 * rand.Intn(h.Len())))
+	}
+	// the iteration over a map starts at a random position
+	for field := range h.dict {
+		return field
+	}
+	return ""
+}
+
+// RandomFields returns count fields picked at random. If unique, the fields are distinct and
+// all of them are returned if count is at least the length of the hash, otherwise a field may
+// be returned several times.
+func (h *Hash) RandomFields(count int, unique bool) []string {
+	fields := h.Fields()
+	if len(fields) == 0 {
+		return fields
+	}
+	if !unique {
+		res := make([]string, count)
+		for i := range res {
+			res[i] = fields[rand.Intn(len(fields))]
+		}
+		return res
+	}
+	if count >= len(fields) {
+		return fields
+	}
+	// partial Fisher-Yates shuffle
+	for i := 0; i < count; i++ {
+		j := i + rand.Intn(len(fields)-i)
+		fields[i], fields[j] = fields[j], fields[i]
+	}
+	return fields[:count]
+}
+
+// Scan returns the fields from the cursor and the cursor of the next call, see ScanIndex.Scan.
+// Like in Redis, a listpack encoded hash is small enough to be returned at once.
+func (h *Hash) Scan(cursor uint64, count int) ([]string, uint64) {
+	if h.lp != nil {
+		return h.Fields(), 0
+	}
+	if h.scanIndex == nil {
+		h.scanIndex = NewScanIndex()
+		for field := range h.dict {
+			h.scanIndex.Add(field)
+		}
+	}
+	return h.scanIndex.Scan(cursor, count)
+}
+
+// Clone returns a copy of the hash
+func (h *Hash) Clone() *Hash {
+	clone := &Hash{}
+	if h.lp != nil {
+		clone.lp = h.lp.Clone()
+	} else {
+		clone.dict = make(map[string]string, len(h.dict))
+		for field, value := range h.dict {
+			clone.dict[field] = value
+		}
+	}
+	for field, expireAt := range h.expires {
+		clone.SetFieldExpiry(field, expireAt)
+	}
+	return clone
+}
+
+// FieldExpiry returns the expiry of the field in unix milliseconds, ok is false if it has none
+func (h *Hash) FieldExpiry(field string) (expireAt uint64, ok bool) {
+	expireAt, ok = h.expires[field]
+	return expireAt, ok
+}
+
+// SetFieldExpiry sets the expiry of an existing field to an absolute unix time in milliseconds
+func (h *Hash) SetFieldExpiry(field string, expireAt uint64) {
+	if h.expires == nil {
+		h.expires = make(map[string]uint64)
+		h.expireIndex = CreateSkiplist()
+	}
+	if current, exist := h.expires[field]; exist {
+		h.expireIndex.UpdateScore(float64(current), field, float64(expireAt))
+	} else {
+		h.expireIndex.Insert(float64(expireAt), field)
+	}
+	h.expires[field] = expireAt
+}
+
+// PersistField removes the time to live of the field, it returns false if it has none
+func (h *Hash) PersistField(field string) bool {
+	current, exist := h.expires[field]
+	if !exist {
+		return false
+	}
+	delete(h.expires, field)
+	h.expireIndex.Delete(float64(current), field)
+	return true
+}
+
+// HasVolatileFields reports whether a field of the hash has a time to live
+func (h *Hash) HasVolatileFields() bool {
+	return len(h.expires) > 0
+}
+
+// DelExpiredFields deletes at most limit fields that expired at nowMs, all of them if limit
+// is negative, and returns the number of deleted fields
+func (h *Hash) DelExpiredFields(nowMs uint64, limit int) int {
+	deleted := 0
+	for h.expireIndex != nil && deleted != limit {
+		first := h.expireIndex.head.levels[0].forward
+		if first == nil || uint64(first.score) > nowMs {
+			break
+		}
+		h.Del(first.ele)
+		deleted++
+	}
+	return deleted
+}
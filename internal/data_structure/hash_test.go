@@ -0,0 +1,111 @@
+package data_structure
+
+import (
+	"Nietzsche/internal/config"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestHash_Listpack(t *testing.T) {
+	h := NewHash()
+	assert.True(t, h.Set("a", "1"))
+	assert.True(t, h.Set("b", "2"))
+	assert.False(t, h.Set("a", "10"))
+	assert.EqualValues(t, EncodingListpack, h.Encoding())
+	assert.EqualValues(t, 2, h.Len())
+	value, ok := h.Get("a")
+	assert.True(t, ok)
+	assert.EqualValues(t, "10", value)
+	_, ok = h.Get("1")
+	assert.False(t, ok, "values are not fields")
+
+	assert.True(t, h.Del("a"))
+	assert.False(t, h.Del("a"))
+	assert.EqualValues(t, []string{"b"}, h.Fields())
+	fields, cursor := h.Scan(0, 1)
+	assert.EqualValues(t, []string{"b"}, fields)
+	assert.EqualValues(t, 0, cursor)
+}
+
+func TestHash_Convert(t *testing.T) {
+	defer func(entries, value int) {
+		config.HashMaxListpackEntries, config.HashMaxListpackValue = entries, value
+	}(config.HashMaxListpackEntries, config.HashMaxListpackValue)
+	config.HashMaxListpackEntries, config.HashMaxListpackValue = 4, 8
+
+	h := NewHash()
+	for i := 0; i < 4; i++ {
+		h.Set(fmt.Sprintf("f%d", i), "v")
+	}
+	assert.EqualValues(t, EncodingListpack, h.Encoding())
+	h.Set("f0", "updated")
+	assert.EqualValues(t, EncodingListpack, h.Encoding())
+	h.Set("f4", "v")
+	assert.EqualValues(t, EncodingHashtable, h.Encoding())
+	assert.EqualValues(t, 5, h.Len())
+	value, _ := h.Get("f0")
+	assert.EqualValues(t, "updated", value)
+
+	// a long value converts the hash, which is not converted back
+	h = NewHash()
+	h.Set("f", "v")
+	h.Set("f", strings.Repeat("v", 9))
+	assert.EqualValues(t, EncodingHashtable, h.Encoding())
+	h.Del("f")
+	h.Set("f", "v")
+	assert.EqualValues(t, EncodingHashtable, h.Encoding())
+}
+
+func TestHash_RandomFields(t *testing.T) {
+	h := NewHash()
+	for _, f := range []string{"a", "b", "c"} {
+		h.Set(f, f)
+	}
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, h.RandomFields(5, true))
+	fields := h.RandomFields(2, true)
+	assert.Len(t, fields, 2)
+	assert.NotEqual(t, fields[0], fields[1])
+	assert.Len(t, h.RandomFields(10, false), 10)
+	assert.Contains(t, []string{"a", "b", "c"}, h.RandomField())
+}
+
+func TestHash_FieldExpiry(t *testing.T) {
+	h := NewHash()
+	for _, f := range []string{"a", "b", "c", "d"} {
+		h.Set(f, f)
+	}
+	h.SetFieldExpiry("a", 300)
+	h.SetFieldExpiry("b", 100)
+	h.SetFieldExpiry("c", 200)
+	h.SetFieldExpiry("c", 500)
+	assert.True(t, h.HasVolatileFields())
+	expireAt, ok := h.FieldExpiry("c")
+	assert.True(t, ok)
+	assert.EqualValues(t, 500, expireAt)
+
+	// the fields are deleted in expiry order
+	assert.EqualValues(t, 1, h.DelExpiredFields(300, 1))
+	assert.False(t, h.Exists("b"))
+	assert.EqualValues(t, 1, h.DelExpiredFields(300, -1))
+	assert.EqualValues(t, []string{"c", "d"}, h.Fields())
+
+	// Set removes the time to live, SetKeepTTL and Clone keep it
+	h.SetKeepTTL("c", "updated")
+	clone := h.Clone()
+	h.Set("c", "again")
+	_, ok = h.FieldExpiry("c")
+	assert.False(t, ok)
+	assert.False(t, h.HasVolatileFields())
+	assert.EqualValues(t, 0, h.DelExpiredFields(1000, -1))
+	assert.EqualValues(t, 1, clone.DelExpiredFields(1000, -1))
+	assert.EqualValues(t, []string{"d"}, clone.Fields())
+
+	h.SetFieldExpiry("d", 100)
+	assert.True(t, h.PersistField("d"))
+	assert.False(t, h.PersistField("d"))
+	h.SetFieldExpiry("d", 100)
+	h.Del("d")
+	assert.False(t, h.HasVolatileFields())
+}
@@ -0,0 +1,212 @@
+package data_structure
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// Listpack is a sequence of strings serialized in a single []byte, like the listpack of Redis.
+// A small collection stored this way costs a few bytes per element instead of the headers and
+// buckets of a map or the string headers of a slice, at the cost of O(n) lookups and updates.
+// Each entry is
+//
+//	<length uvarint> <data> <backlen>
+//
+// where backlen is the size of the length and the data, written as a varint read backwards,
+// so that the listpack can be walked from both ends. The entries are addressed by the position
+// of their first byte.
+type Listpack struct {
+	buf []byte
+	n   int
+}
+
+func NewListpack() *Listpack {
+	return &Listpack{}
+}
+
+// Len returns the number of entries
+func (lp *Listpack) Len() int {
+	return lp.n
+}
+
+// Bytes returns the size of the serialized entries
+func (lp *Listpack) Bytes() int {
+	return len(lp.buf)
+}
+
+func backlenSize(l int) int {
+	n := 1
+	for l >= 128 {
+		l >>= 7
+		n++
+	}
+	return n
+}
+
+func appendEntry(dst []byte, s string) []byte {
+	start := len(dst)
+	dst = binary.AppendUvarint(dst, uint64(len(s)))
+	dst = append(dst, s...)
+	l := len(dst) - start
+	// the lowest 7 bits are in the last byte, every byte but the first one has the continuation bit
+	k := backlenSize(l) - 1
+	for i := k; i >= 0; i-- {
+		b := byte(l>>(7*i)) & 127
+		if i < k {
+			b |= 128
+		}
+		dst = append(dst, b)
+	}
+	return dst
+}
+
+// entrySize returns the size of the entry holding s
+func entrySize(s string) int {
+	l := backlenSize(len(s)) + len(s)
+	return l + backlenSize(l)
+}
+
+// entry returns the bounds of the data of the entry at p, and the position following the entry
+func (lp *Listpack) entry(p int) (dataStart, dataEnd, end int) {
+	l, n := binary.Uvarint(lp.buf[p:])
+	dataStart = p + n
+	dataEnd = dataStart + int(l)
+	return dataStart, dataEnd, dataEnd + backlenSize(dataEnd-p)
+}
+
+// entryBefore returns the position of the entry ending at end
+func (lp *Listpack) entryBefore(end int) int {
+	l, shift := 0, 0
+	for {
+		end--
+		b := lp.buf[end]
+		l |= int(b&127) << shift
+		shift += 7
+		if b&128 == 0 {
+			return end - l
+		}
+	}
+}
+
+// First returns the position of the first entry, -1 if the listpack is empty
+func (lp *Listpack) First() int {
+	if lp.n == 0 {
+		return -1
+	}
+	return 0
+}
+
+// Last returns the position of the last entry, -1 if the listpack is empty
+func (lp *Listpack) Last() int {
+	if lp.n == 0 {
+		return -1
+	}
+	return lp.entryBefore(len(lp.buf))
+}
+
+// Next returns the position of the entry following the one at p, -1 if it is the last one
+func (lp *Listpack) Next(p int) int {
+	_, _, end := lp.entry(p)
+	if end == len(lp.buf) {
+		return -1
+	}
+	return end
+}
+
+// Prev returns the position of the entry preceding the one at p, -1 if it is the first one
+func (lp *Listpack) Prev(p int) int {
+	if p == 0 {
+		return -1
+	}
+	return lp.entryBefore(p)
+}
+
+// Get returns the entry at p
+func (lp *Listpack) Get(p int) string {
+	start, end, _ := lp.entry(p)
+	return string(lp.buf[start:end])
+}
+
+// Equal reports whether the entry at p is s, without copying the entry
+func (lp *Listpack) Equal(p int, s string) bool {
+	start, end, _ := lp.entry(p)
+	return string(lp.buf[start:end]) == s
+}
+
+// Seek returns the position of the entry at index, negative indexes count from the end.
+// It returns -1 if the index is out of range. The listpack is walked from the nearest end.
+func (lp *Listpack) Seek(index int) int {
+	if index < 0 {
+		index += lp.n
+	}
+	if index < 0 || index >= lp.n {
+		return -1
+	}
+	if index < lp.n/2 {
+		p := 0
+		for ; index > 0; index-- {
+			p = lp.Next(p)
+		}
+		return p
+	}
+	p := lp.Last()
+	for i := lp.n - 1; i > index; i-- {
+		p = lp.Prev(p)
+	}
+	return p
+}
+
+// Insert inserts s before the entry at p, or appends it if p is Bytes()
+func (lp *Listpack) Insert(p int, s string) {
+	lp.buf = slices.Insert(lp.buf, p, appendEntry(nil, s)...)
+	lp.n++
+}
+
+// Append appends s after the last entry
+func (lp *Listpack) Append(s string) {
+	lp.buf = appendEntry(lp.buf, s)
+	lp.n++
+}
+
+// Replace replaces the entry at p by s
+func (lp *Listpack) Replace(p int, s string) {
+	_, _, end := lp.entry(p)
+	lp.buf = slices.Replace(lp.buf, p, end, appendEntry(nil, s)...)
+}
+
+// Delete deletes the entry at p, it returns the position of the entry that followed it,
+// -1 if it was the last one
+func (lp *Listpack) Delete(p int) int {
+	_, _, end := lp.entry(p)
+	lp.buf = slices.Delete(lp.buf, p, end)
+	lp.n--
+	if p == len(lp.buf) {
+		return -1
+	}
+	return p
+}
+
+// Split moves the entries from the one at p to the end to a new listpack, which is returned
+func (lp *Listpack) Split(p int) *Listpack {
+	right := &Listpack{buf: slices.Clone(lp.buf[p:])}
+	for q := p; q < len(lp.buf); right.n++ {
+		_, _, q = lp.entry(q)
+	}
+	lp.buf = lp.buf[:p]
+	lp.n -= right.n
+	return right
+}
+
+// Entries returns all the entries in order
+func (lp *Listpack) Entries() []string {
+	entries := make([]string, 0, lp.n)
+	for p := lp.First(); p != -1; p = lp.Next(p) {
+		entries = append(entries, lp.Get(p))
+	}
+	return entries
+}
+
+// Clone returns a copy of the listpack
+func (lp *Listpack) Clone() *Listpack {
+	return &Listpack{buf: slices.Clone(lp.buf), n: lp.n}
+}
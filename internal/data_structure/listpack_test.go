@@ -0,0 +1,74 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestListpack_Walk(t *testing.T) {
+	lp := NewListpack()
+	// entries with 1, 2 and 3 bytes long lengths and backlens
+	entries := []string{"", "a", strings.Repeat("b", 127), strings.Repeat("c", 128), strings.Repeat("d", 20000), "e"}
+	for _, e := range entries {
+		lp.Append(e)
+	}
+	assert.EqualValues(t, len(entries), lp.Len())
+	assert.EqualValues(t, entries, lp.Entries())
+
+	var backward []string
+	for p := lp.Last(); p != -1; p = lp.Prev(p) {
+		backward = append([]string{lp.Get(p)}, backward...)
+	}
+	assert.EqualValues(t, entries, backward)
+
+	for i, e := range entries {
+		assert.EqualValues(t, e, lp.Get(lp.Seek(i)))
+		assert.EqualValues(t, e, lp.Get(lp.Seek(i-len(entries))))
+	}
+	assert.EqualValues(t, -1, lp.Seek(len(entries)))
+	assert.EqualValues(t, -1, lp.Seek(-len(entries)-1))
+	assert.EqualValues(t, -1, NewListpack().First())
+	assert.EqualValues(t, -1, NewListpack().Last())
+}
+
+func TestListpack_Update(t *testing.T) {
+	lp := NewListpack()
+	lp.Append("b")
+	lp.Insert(0, "a")
+	lp.Insert(lp.Bytes(), "d")
+	lp.Insert(lp.Seek(2), "c")
+	assert.EqualValues(t, []string{"a", "b", "c", "d"}, lp.Entries())
+
+	lp.Replace(lp.Seek(1), strings.Repeat("x", 200))
+	assert.True(t, lp.Equal(lp.Seek(1), strings.Repeat("x", 200)))
+	assert.EqualValues(t, "c", lp.Get(lp.Next(lp.Seek(1))))
+	lp.Replace(lp.Seek(1), "B")
+	assert.EqualValues(t, []string{"a", "B", "c", "d"}, lp.Entries())
+
+	p := lp.Delete(lp.Seek(1))
+	assert.EqualValues(t, "c", lp.Get(p))
+	assert.EqualValues(t, -1, lp.Delete(lp.Last()))
+	assert.EqualValues(t, []string{"a", "c"}, lp.Entries())
+
+	clone := lp.Clone()
+	lp.Delete(lp.First())
+	assert.EqualValues(t, []string{"c"}, lp.Entries())
+	assert.EqualValues(t, []string{"a", "c"}, clone.Entries())
+}
+
+func TestListpack_Split(t *testing.T) {
+	lp := NewListpack()
+	for _, e := range []string{"a", strings.Repeat("b", 300), "c", "d"} {
+		lp.Append(e)
+	}
+	right := lp.Split(lp.Seek(2))
+	assert.EqualValues(t, []string{"a", strings.Repeat("b", 300)}, lp.Entries())
+	assert.EqualValues(t, 2, lp.Len())
+	assert.EqualValues(t, []string{"c", "d"}, right.Entries())
+	assert.EqualValues(t, 2, right.Len())
+	assert.EqualValues(t, "d", right.Get(right.Last()))
+
+	lp.Append("e")
+	assert.EqualValues(t, []string{"c", "d"}, right.Entries(), "the listpacks do not share memory")
+}
@@ -0,0 +1,333 @@
+package data_structure
+
+import "Nietzsche/internal/config"
+
+// Quicklist is a list of strings stored as a doubly linked list of listpacks, like the quicklist
+// of Redis. Pushing and popping at both ends is O(1), and an element is found by its index in
+// O(number of listpacks): the walk skips whole listpacks by their length, from the nearest end,
+// then seeks the element in a single listpack. The size of the listpacks is bounded by
+// config.ListMaxListpackSize.
+type Quicklist struct {
+	head, tail *quicklistNode
+	count      int // number of elements
+	nodes      int // number of listpacks
+}
+
+type quicklistNode struct {
+	prev, next *quicklistNode
+	lp         *Listpack
+}
+
+func NewQuicklist() *Quicklist {
+	return &Quicklist{}
+}
+
+// Len returns the number of elements
+func (ql *Quicklist) Len() int {
+	return ql.count
+}
+
+// listpackSizeLimits are the sizes in bytes of the negative values of config.ListMaxListpackSize
+var listpackSizeLimits = [...]int{4096, 8192, 16384, 32768, 65536}
+
+// allowInsert reports whether s can be added to the node without exceeding the size of a listpack.
+// An empty node accepts any element.
+func allowInsert(node *quicklistNode, s string) bool {
+	if node == nil {
+		return false
+	}
+	if node.lp.Len() == 0 {
+		return true
+	}
+	fill := config.ListMaxListpackSize
+	if fill >= 0 {
+		return node.lp.Len() < max(fill, 1)
+	}
+	limit := listpackSizeLimits[min(-fill, len(listpackSizeLimits))-1]
+	return node.lp.Bytes()+entrySize(s) <= limit
+}
+
+// insertNode links a new node holding lp after prev, or at the head if prev is nil
+func (ql *Quicklist) insertNode(prev *quicklistNode, lp *Listpack) *quicklistNode {
+	node := &quicklistNode{prev: prev, lp: lp}
+	if prev == nil {
+		node.next = ql.head
+		ql.head = node
+	} else {
+		node.next = prev.next
+		prev.next = node
+	}
+	if node.next == nil {
+		ql.tail = node
+	} else {
+		node.next.prev = node
+	}
+	ql.nodes++
+	return node
+}
+
+func (ql *Quicklist) unlinkNode(node *quicklistNode) {
+	if node.prev == nil {
+		ql.head = node.next
+	} else {
+		node.prev.next = node.next
+	}
+	if node.next == nil {
+		ql.tail = node.prev
+	} else {
+		node.next.prev = node.prev
+	}
+	ql.nodes--
+}
+
+// deleteAt deletes the element at p in node, and the node if it becomes empty. It returns the
+// position in node of the element that followed it, -1 if it was the last one of the node.
+func (ql *Quicklist) deleteAt(node *quicklistNode, p int) int {
+	p = node.lp.Delete(p)
+	ql.count--
+	if node.lp.Len() == 0 {
+		ql.unlinkNode(node)
+		return -1
+	}
+	return p
+}
+
+func (ql *Quicklist) PushHead(s string) {
+	if !allowInsert(ql.head, s) {
+		ql.insertNode(nil, NewListpack())
+	}
+	ql.head.lp.Insert(0, s)
+	ql.count++
+}
+
+func (ql *Quicklist) PushTail(s string) {
+	if !allowInsert(ql.tail, s) {
+		ql.insertNode(ql.tail, NewListpack())
+	}
+	ql.tail.lp.Append(s)
+	ql.count++
+}
+
+// PopHead removes and returns the first element, ok is false if the list is empty
+func (ql *Quicklist) PopHead() (s string, ok bool) {
+	if ql.head == nil {
+		return "", false
+	}
+	p := ql.head.lp.First()
+	s = ql.head.lp.Get(p)
+	ql.deleteAt(ql.head, p)
+	return s, true
+}
+
+// PopTail removes and returns the last element, ok is false if the list is empty
+func (ql *Quicklist) PopTail() (s string, ok bool) {
+	if ql.tail == nil {
+		return "", false
+	}
+	p := ql.tail.lp.Last()
+	s = ql.tail.lp.Get(p)
+	ql.deleteAt(ql.tail, p)
+	return s, true
+}
+
+// locate returns the node and the position in its listpack of the element at index,
+// which must be in [0, Len())
+func (ql *Quicklist) locate(index int) (*quicklistNode, int) {
+	if index < ql.count/2 {
+		node := ql.head
+		for index >= node.lp.Len() {
+			index -= node.lp.Len()
+			node = node.next
+		}
+		return node, node.lp.Seek(index)
+	}
+	index = ql.count - 1 - index
+	node := ql.tail
+	for index >= node.lp.Len() {
+		index -= node.lp.Len()
+		node = node.prev
+	}
+	return node, node.lp.Seek(-1 - index)
+}
+
+// normalize converts a negative index, counted from the tail, to an index from the head.
+// ok is false if the index is out of range.
+func (ql *Quicklist) normalize(index int) (int, bool) {
+	if index < 0 {
+		index += ql.count
+	}
+	return index, index >= 0 && index < ql.count
+}
+
+// Index returns the element at index, negative indexes count from the tail.
+// ok is false if the index is out of range.
+func (ql *Quicklist) Index(index int) (s string, ok bool) {
+	index, ok = ql.normalize(index)
+	if !ok {
+		return "", false
+	}
+	node, p := ql.locate(index)
+	return node.lp.Get(p), true
+}
+
+// Set replaces the element at index like Index, it returns false if the index is out of range
+func (ql *Quicklist) Set(index int, s string) bool {
+	index, ok := ql.normalize(index)
+	if !ok {
+		return false
+	}
+	node, p := ql.locate(index)
+	node.lp.Replace(p, s)
+	return true
+}
+
+// Range returns the elements from start to stop included, 0 <= start <= stop < Len()
+func (ql *Quicklist) Range(start, stop int) []string {
+	n := stop - start + 1
+	res := make([]string, 0, n)
+	node, p := ql.locate(start)
+	for len(res) < n {
+		res = append(res, node.lp.Get(p))
+		if p = node.lp.Next(p); p == -1 && node.next != nil {
+			node = node.next
+			p = node.lp.First()
+		}
+	}
+	return res
+}
+
+// DelRange deletes n elements from the index start, 0 <= start < Len().
+// The listpacks holding only deleted elements are unlinked at once.
+func (ql *Quicklist) DelRange(start, n int) {
+	if n <= 0 {
+		return
+	}
+	node, p := ql.locate(start)
+	for n > 0 && node != nil {
+		next := node.next
+		if p == 0 && node.lp.Len() <= n {
+			n -= node.lp.Len()
+			ql.count -= node.lp.Len()
+			ql.unlinkNode(node)
+		} else {
+			for n > 0 && p != -1 {
+				p = ql.deleteAt(node, p)
+				n--
+			}
+		}
+		node, p = next, 0
+	}
+}
+
+// insert inserts s before the element at p in node, or after it if after is true.
+// If the node is full, s goes to the neighbor node when it is inserted at an end of the node,
+// otherwise the node is split at the insertion point.
+func (ql *Quicklist) insert(node *quicklistNode, p int, s string, after bool) {
+	ql.count++
+	at := p
+	if after {
+		if at = node.lp.Next(p); at == -1 {
+			at = node.lp.Bytes()
+		}
+	}
+	if allowInsert(node, s) {
+		node.lp.Insert(at, s)
+		return
+	}
+	switch {
+	case at == 0 && allowInsert(node.prev, s):
+		node.prev.lp.Append(s)
+	case at == 0:
+		ql.insertNode(node.prev, NewListpack()).lp.Append(s)
+	case at == node.lp.Bytes() && allowInsert(node.next, s):
+		node.next.lp.Insert(0, s)
+	case at == node.lp.Bytes():
+		ql.insertNode(node, NewListpack()).lp.Append(s)
+	default:
+		right := ql.insertNode(node, node.lp.Split(at))
+		switch {
+		case allowInsert(node, s):
+			node.lp.Append(s)
+		case allowInsert(right, s):
+			right.lp.Insert(0, s)
+		default:
+			ql.insertNode(node, NewListpack()).lp.Append(s)
+		}
+	}
+}
+
+// Clone returns a copy of the list
+func (ql *Quicklist) Clone() *Quicklist {
+	clone := NewQuicklist()
+	for node := ql.head; node != nil; node = node.next {
+		clone.insertNode(clone.tail, node.lp.Clone())
+	}
+	clone.count = ql.count
+	return clone
+}
+
+// QuicklistIter walks the elements of a quicklist from the head or from the tail
+type QuicklistIter struct {
+	ql      *Quicklist
+	forward bool
+	// the current element, node is nil before the first call to Next
+	node *quicklistNode
+	p    int
+	// the element returned by the next call to Next, nextNode is nil at the end of the list
+	nextNode *quicklistNode
+	nextP    int
+}
+
+// Iter returns an iterator from the head, or from the tail if fromHead is false
+func (ql *Quicklist) Iter(fromHead bool) *QuicklistIter {
+	it := &QuicklistIter{ql: ql, forward: fromHead}
+	if fromHead && ql.head != nil {
+		it.nextNode, it.nextP = ql.head, ql.head.lp.First()
+	} else if !fromHead && ql.tail != nil {
+		it.nextNode, it.nextP = ql.tail, ql.tail.lp.Last()
+	}
+	return it
+}
+
+// Next moves to the next element and returns it, ok is false at the end of the list
+func (it *QuicklistIter) Next() (s string, ok bool) {
+	if it.nextNode == nil {
+		return "", false
+	}
+	it.node, it.p = it.nextNode, it.nextP
+	if it.forward {
+		if it.nextP = it.node.lp.Next(it.p); it.nextP == -1 {
+			if it.nextNode = it.node.next; it.nextNode != nil {
+				it.nextP = it.nextNode.lp.First()
+			}
+		}
+	} else {
+		if it.nextP = it.node.lp.Prev(it.p); it.nextP == -1 {
+			if it.nextNode = it.node.prev; it.nextNode != nil {
+				it.nextP = it.nextNode.lp.Last()
+			}
+		}
+	}
+	return it.node.lp.Get(it.p), true
+}
+
+// Equal reports whether the current element is s, without copying it
+func (it *QuicklistIter) Equal(s string) bool {
+	return it.node.lp.Equal(it.p, s)
+}
+
+// Delete deletes the current element, the iteration goes on with the element following it
+func (it *QuicklistIter) Delete() {
+	sameNode := it.nextNode == it.node
+	it.ql.deleteAt(it.node, it.p)
+	if it.forward && sameNode {
+		// the elements following the deleted one moved back to its position
+		it.nextP = it.p
+	}
+}
+
+// Insert inserts s before or after the current element, the iterator can not be used anymore
+func (it *QuicklistIter) Insert(s string, after bool) {
+	it.ql.insert(it.node, it.p, s, after)
+	it.nextNode = nil
+}
@@ -0,0 +1,244 @@
+package data_structure
+
+import (
+	"Nietzsche/internal/config"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func withListpackSize(t *testing.T, size int) {
+	old := config.ListMaxListpackSize
+	config.ListMaxListpackSize = size
+	t.Cleanup(func() { config.ListMaxListpackSize = old })
+}
+
+// checkQuicklist asserts that ql holds expected and that its nodes are consistent
+func checkQuicklist(t *testing.T, ql *Quicklist, expected []string) {
+	t.Helper()
+	var elements []string
+	nodes := 0
+	var prev *quicklistNode
+	for node := ql.head; node != nil; node = node.next {
+		assert.Same(t, prev, node.prev)
+		assert.NotZero(t, node.lp.Len(), "no empty node")
+		if config.ListMaxListpackSize > 0 {
+			assert.LessOrEqual(t, node.lp.Len(), config.ListMaxListpackSize)
+		}
+		elements = append(elements, node.lp.Entries()...)
+		prev = node
+		nodes++
+	}
+	assert.Same(t, prev, ql.tail)
+	assert.EqualValues(t, nodes, ql.nodes)
+	assert.EqualValues(t, len(expected), ql.Len())
+	assert.EqualValues(t, len(expected), len(elements))
+	for i := range min(len(expected), len(elements)) {
+		assert.EqualValues(t, expected[i], elements[i])
+	}
+}
+
+func TestQuicklist_PushPop(t *testing.T) {
+	withListpackSize(t, 3)
+	ql := NewQuicklist()
+	for i := 0; i < 5; i++ {
+		ql.PushTail(fmt.Sprint(i))
+		ql.PushHead(fmt.Sprint(-i - 1))
+	}
+	checkQuicklist(t, ql, []string{"-5", "-4", "-3", "-2", "-1", "0", "1", "2", "3", "4"})
+	assert.EqualValues(t, 4, ql.nodes)
+
+	s, ok := ql.PopHead()
+	assert.True(t, ok)
+	assert.EqualValues(t, "-5", s)
+	s, _ = ql.PopTail()
+	assert.EqualValues(t, "4", s)
+	checkQuicklist(t, ql, []string{"-4", "-3", "-2", "-1", "0", "1", "2", "3"})
+
+	for ql.Len() > 0 {
+		ql.PopTail()
+	}
+	checkQuicklist(t, ql, nil)
+	_, ok = ql.PopHead()
+	assert.False(t, ok)
+	_, ok = ql.PopTail()
+	assert.False(t, ok)
+}
+
+func TestQuicklist_SizeInBytes(t *testing.T) {
+	withListpackSize(t, -1)
+	ql := NewQuicklist()
+	for i := 0; i < 10; i++ {
+		ql.PushTail(strings.Repeat("x", 1000))
+	}
+	assert.EqualValues(t, 3, ql.nodes, "4 elements of ~1 KB per 4 KB listpack")
+	ql.PushTail(strings.Repeat("y", 10000))
+	assert.EqualValues(t, 4, ql.nodes, "an element larger than the limit has its own listpack")
+	for node := ql.head; node != nil; node = node.next {
+		assert.True(t, node.lp.Bytes() <= 4096 || node.lp.Len() == 1)
+	}
+}
+
+func TestQuicklist_Index(t *testing.T) {
+	withListpackSize(t, 4)
+	ql := NewQuicklist()
+	var expected []string
+	for i := 0; i < 30; i++ {
+		ql.PushTail(fmt.Sprint(i))
+		expected = append(expected, fmt.Sprint(i))
+	}
+	for i := range expected {
+		s, ok := ql.Index(i)
+		assert.True(t, ok)
+		assert.EqualValues(t, expected[i], s)
+		s, _ = ql.Index(i - len(expected))
+		assert.EqualValues(t, expected[i], s)
+	}
+	_, ok := ql.Index(30)
+	assert.False(t, ok)
+	_, ok = ql.Index(-31)
+	assert.False(t, ok)
+
+	assert.True(t, ql.Set(13, "thirteen"))
+	assert.True(t, ql.Set(-1, "last"))
+	assert.False(t, ql.Set(30, "x"))
+	expected[13], expected[29] = "thirteen", "last"
+	checkQuicklist(t, ql, expected)
+
+	assert.EqualValues(t, expected[2:17], ql.Range(2, 16))
+	assert.EqualValues(t, expected[29:], ql.Range(29, 29))
+	assert.EqualValues(t, expected, ql.Range(0, 29))
+}
+
+func TestQuicklist_DelRange(t *testing.T) {
+	withListpackSize(t, 4)
+	for _, tc := range [][2]int{{0, 30}, {0, 4}, {0, 5}, {2, 3}, {3, 12}, {4, 8}, {25, 5}, {29, 1}} {
+		ql := NewQuicklist()
+		var expected []string
+		for i := 0; i < 30; i++ {
+			ql.PushTail(fmt.Sprint(i))
+			expected = append(expected, fmt.Sprint(i))
+		}
+		ql.DelRange(tc[0], tc[1])
+		checkQuicklist(t, ql, slices.Delete(expected, tc[0], tc[0]+tc[1]))
+	}
+}
+
+func TestQuicklist_Iter(t *testing.T) {
+	withListpackSize(t, 3)
+	ql := NewQuicklist()
+	for _, s := range []string{"a", "x", "b", "x", "x", "c", "x"} {
+		ql.PushTail(s)
+	}
+	var seen []string
+	it := ql.Iter(false)
+	for s, ok := it.Next(); ok; s, ok = it.Next() {
+		seen = append(seen, s)
+	}
+	assert.EqualValues(t, []string{"x", "c", "x", "x", "b", "x", "a"}, seen)
+
+	seen = nil
+	it = ql.Iter(true)
+	for s, ok := it.Next(); ok; s, ok = it.Next() {
+		if it.Equal("x") {
+			it.Delete()
+		} else {
+			seen = append(seen, s)
+		}
+	}
+	assert.EqualValues(t, []string{"a", "b", "c"}, seen)
+	checkQuicklist(t, ql, []string{"a", "b", "c"})
+
+	it = NewQuicklist().Iter(true)
+	_, ok := it.Next()
+	assert.False(t, ok)
+}
+
+func TestQuicklist_Insert(t *testing.T) {
+	withListpackSize(t, 3)
+	// insert s before or after the element at index in a full list of 9 elements
+	for index := 0; index < 9; index++ {
+		for _, after := range []bool{false, true} {
+			ql := NewQuicklist()
+			var expected []string
+			for i := 0; i < 9; i++ {
+				ql.PushTail(fmt.Sprint(i))
+				expected = append(expected, fmt.Sprint(i))
+			}
+			it := ql.Iter(true)
+			for i := 0; i <= index; i++ {
+				it.Next()
+			}
+			it.Insert("s", after)
+			at := index
+			if after {
+				at++
+			}
+			checkQuicklist(t, ql, slices.Insert(expected, at, "s"))
+		}
+	}
+}
+
+func TestQuicklist_Random(t *testing.T) {
+	withListpackSize(t, 5)
+	ql := NewQuicklist()
+	var expected []string
+	for i := 0; i < 5000; i++ {
+		s := fmt.Sprint(rand.Intn(20))
+		switch op := rand.Intn(12); {
+		case op == 0:
+			ql.PushHead(s)
+			expected = slices.Insert(expected, 0, s)
+		case op == 1 || op >= 8:
+			ql.PushTail(s)
+			expected = append(expected, s)
+		case op == 2 && len(expected) > 0:
+			ql.PopHead()
+			expected = expected[1:]
+		case op == 3 && len(expected) > 0:
+			ql.PopTail()
+			expected = expected[:len(expected)-1]
+		case op == 4 && len(expected) > 0:
+			index := rand.Intn(len(expected))
+			ql.Set(index, s)
+			expected[index] = s
+		case op == 5 && len(expected) > 0:
+			start := rand.Intn(len(expected))
+			n := rand.Intn(min(len(expected)-start, 8) + 1)
+			ql.DelRange(start, n)
+			expected = slices.Delete(expected, start, start+n)
+		case op == 6 && len(expected) > 0:
+			index := rand.Intn(len(expected))
+			it := ql.Iter(true)
+			for j := 0; j <= index; j++ {
+				it.Next()
+			}
+			after := rand.Intn(2) == 0
+			it.Insert(s, after)
+			if after {
+				index++
+			}
+			expected = slices.Insert(expected, index, s)
+		case op == 7:
+			// delete the first occurrence of s
+			it := ql.Iter(true)
+			for _, ok := it.Next(); ok; _, ok = it.Next() {
+				if it.Equal(s) {
+					it.Delete()
+					break
+				}
+			}
+			if index := slices.Index(expected, s); index != -1 {
+				expected = slices.Delete(expected, index, index+1)
+			}
+		}
+		if i%500 == 0 {
+			checkQuicklist(t, ql, expected)
+		}
+	}
+	checkQuicklist(t, ql, expected)
+	checkQuicklist(t, ql.Clone(), expected)
+}
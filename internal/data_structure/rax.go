@@ -0,0 +1,263 @@
+package data_structure
+
+// Rax is a radix tree mapping strings to values, like the rax of Redis. The edges are compressed:
+// a node with a single child and no value is merged with its child, so that a chain of keys
+// sharing a long prefix, like the big endian IDs of a stream, costs a few nodes only.
+// The keys are ordered bytewise, and the tree can be searched for the nearest key of any string.
+type Rax struct {
+	root raxNode
+	size int
+}
+
+type raxNode struct {
+	prefix   string     // the label of the edge from the parent
+	children []*raxNode // sorted by the first byte of their prefix
+	isKey    bool       // whether the path to the node is a key, value is then its value
+	value    any
+}
+
+func NewRax() *Rax {
+	return &Rax{}
+}
+
+// Len returns the number of keys
+func (r *Rax) Len() int {
+	return r.size
+}
+
+func commonPrefixLen(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// childIndex returns the index of the child whose prefix starts with b, or the index where such
+// a child would be inserted and false
+func (n *raxNode) childIndex(b byte) (int, bool) {
+	lo, hi := 0, len(n.children)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if n.children[mid].prefix[0] < b {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(n.children) && n.children[lo].prefix[0] == b
+}
+
+// Insert sets the value of the key, it returns false if the key already existed
+func (r *Rax) Insert(key string, value any) bool {
+	n := &r.root
+	for key != "" {
+		i, ok := n.childIndex(key[0])
+		if !ok {
+			leaf := &raxNode{prefix: key}
+			n.children = append(n.children, nil)
+			copy(n.children[i+1:], n.children[i:])
+			n.children[i] = leaf
+			n = leaf
+			break
+		}
+		child := n.children[i]
+		l := commonPrefixLen(child.prefix, key)
+		if l < len(child.prefix) {
+			// split the edge at the end of the common prefix
+			mid := &raxNode{prefix: child.prefix[:l], children: []*raxNode{child}}
+			child.prefix = child.prefix[l:]
+			n.children[i] = mid
+			child = mid
+		}
+		n, key = child, key[l:]
+	}
+	added := !n.isKey
+	n.isKey, n.value = true, value
+	if added {
+		r.size++
+	}
+	return added
+}
+
+// Find returns the value of the key, ok is false if the key does not exist
+func (r *Rax) Find(key string) (value any, ok bool) {
+	n := &r.root
+	for key != "" {
+		i, found := n.childIndex(key[0])
+		if !found || len(key) < len(n.children[i].prefix) || key[:len(n.children[i].prefix)] != n.children[i].prefix {
+			return nil, false
+		}
+		n, key = n.children[i], key[len(n.children[i].prefix):]
+	}
+	return n.value, n.isKey
+}
+
+// Remove deletes the key, it returns false if the key did not exist
+func (r *Rax) Remove(key string) bool {
+	if !r.root.remove(key) {
+		return false
+	}
+	r.size--
+	return true
+}
+
+// remove deletes the key below n, then removes or merges the nodes left without a value
+func (n *raxNode) remove(key string) bool {
+	if key == "" {
+		if !n.isKey {
+			return false
+		}
+		n.isKey, n.value = false, nil
+		return true
+	}
+	i, ok := n.childIndex(key[0])
+	if !ok {
+		return false
+	}
+	child := n.children[i]
+	if len(key) < len(child.prefix) || key[:len(child.prefix)] != child.prefix || !child.remove(key[len(child.prefix):]) {
+		return false
+	}
+	if !child.isKey {
+		switch len(child.children) {
+		case 0:
+			n.children = append(n.children[:i], n.children[i+1:]...)
+		case 1:
+			grandchild := child.children[0]
+			grandchild.prefix = child.prefix + grandchild.prefix
+			n.children[i] = grandchild
+		}
+	}
+	return true
+}
+
+// first returns the smallest key of the subtree of n, whose path is path
+func (n *raxNode) first(path string) (string, *raxNode) {
+	for !n.isKey {
+		if len(n.children) == 0 {
+			return "", nil
+		}
+		n = n.children[0]
+		path += n.prefix
+	}
+	return path, n
+}
+
+// last returns the greatest key of the subtree of n, whose path is path
+func (n *raxNode) last(path string) (string, *raxNode) {
+	for len(n.children) > 0 {
+		n = n.children[len(n.children)-1]
+		path += n.prefix
+	}
+	if !n.isKey {
+		return "", nil
+	}
+	return path, n
+}
+
+// ceiling returns the smallest key of the subtree of n greater than or equal to path+key,
+// or strictly greater if strict
+func (n *raxNode) ceiling(path, key string, strict bool) (string, *raxNode) {
+	if key == "" && n.isKey && !strict {
+		return path, n
+	}
+	for _, child := range n.children {
+		if key != "" && child.prefix[0] < key[0] {
+			continue
+		}
+		if key == "" || child.prefix[0] > key[0] {
+			// all the keys of the child are greater
+			if k, found := child.first(path + child.prefix); found != nil {
+				return k, found
+			}
+			continue
+		}
+		l := commonPrefixLen(child.prefix, key)
+		switch {
+		case l == len(child.prefix):
+			if k, found := child.ceiling(path+child.prefix, key[l:], strict); found != nil {
+				return k, found
+			}
+		case l == len(key) || child.prefix[l] > key[l]:
+			return child.first(path + child.prefix)
+		}
+	}
+	return "", nil
+}
+
+// floor returns the greatest key of the subtree of n less than or equal to path+key,
+// or strictly less if strict
+func (n *raxNode) floor(path, key string, strict bool) (string, *raxNode) {
+	if key == "" {
+		// the keys of the children are greater
+		if n.isKey && !strict {
+			return path, n
+		}
+		return "", nil
+	}
+	for i := len(n.children) - 1; i >= 0; i-- {
+		child := n.children[i]
+		if child.prefix[0] > key[0] {
+			continue
+		}
+		if child.prefix[0] < key[0] {
+			if k, found := child.last(path + child.prefix); found != nil {
+				return k, found
+			}
+			continue
+		}
+		l := commonPrefixLen(child.prefix, key)
+		switch {
+		case l == len(child.prefix):
+			if k, found := child.floor(path+child.prefix, key[l:], strict); found != nil {
+				return k, found
+			}
+		case l < len(key) && child.prefix[l] < key[l]:
+			if k, found := child.last(path + child.prefix); found != nil {
+				return k, found
+			}
+		}
+	}
+	if n.isKey {
+		return path, n
+	}
+	return "", nil
+}
+
+func raxResult(key string, n *raxNode) (string, any, bool) {
+	if n == nil {
+		return "", nil, false
+	}
+	return key, n.value, true
+}
+
+// First returns the smallest key and its value, ok is false if the tree is empty
+func (r *Rax) First() (key string, value any, ok bool) {
+	return raxResult(r.root.first(""))
+}
+
+// Last returns the greatest key and its value, ok is false if the tree is empty
+func (r *Rax) Last() (key string, value any, ok bool) {
+	return raxResult(r.root.last(""))
+}
+
+// Ceiling returns the smallest key greater than or equal to key, ok is false if there is none
+func (r *Rax) Ceiling(key string) (k string, value any, ok bool) {
+	return raxResult(r.root.ceiling("", key, false))
+}
+
+// Higher returns the smallest key strictly greater than key, ok is false if there is none
+func (r *Rax) Higher(key string) (k string, value any, ok bool) {
+	return raxResult(r.root.ceiling("", key, true))
+}
+
+// Floor returns the greatest key less than or equal to key, ok is false if there is none
+func (r *Rax) Floor(key string) (k string, value any, ok bool) {
+	return raxResult(r.root.floor("", key, false))
+}
+
+// Lower returns the greatest key strictly less than key, ok is false if there is none
+func (r *Rax) Lower(key string) (k string, value any, ok bool) {
+	return raxResult(r.root.floor("", key, true))
+}
@@ -0,0 +1,126 @@
+package data_structure
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestRax_InsertFindRemove(t *testing.T) {
+	r := NewRax()
+	assert.True(t, r.Insert("romane", 1))
+	assert.True(t, r.Insert("romanus", 2))
+	assert.True(t, r.Insert("romulus", 3))
+	assert.True(t, r.Insert("rom", 4))
+	assert.False(t, r.Insert("romane", 5))
+	assert.EqualValues(t, 4, r.Len())
+
+	v, ok := r.Find("romane")
+	assert.True(t, ok)
+	assert.EqualValues(t, 5, v)
+	_, ok = r.Find("roma")
+	assert.False(t, ok, "a prefix of keys is not a key")
+	_, ok = r.Find("romanes")
+	assert.False(t, ok)
+
+	assert.False(t, r.Remove("roma"))
+	assert.True(t, r.Remove("rom"))
+	assert.True(t, r.Remove("romanus"))
+	assert.EqualValues(t, 2, r.Len())
+	// the edges of the removed keys are merged back
+	assert.Len(t, r.root.children, 1)
+	assert.EqualValues(t, "rom", r.root.children[0].prefix)
+	assert.EqualValues(t, []string{"ane", "ulus"}, []string{r.root.children[0].children[0].prefix, r.root.children[0].children[1].prefix})
+}
+
+func TestRax_Seek(t *testing.T) {
+	r := NewRax()
+	for _, key := range []string{"b", "ba", "bab", "bc", "d"} {
+		r.Insert(key, key)
+	}
+	tests := []struct {
+		key                           string
+		ceiling, higher, floor, lower string
+	}{
+		{"", "b", "b", "", ""},
+		{"a", "b", "b", "", ""},
+		{"b", "b", "ba", "b", ""},
+		{"ba", "ba", "bab", "ba", "b"},
+		{"baa", "bab", "bab", "ba", "ba"},
+		{"bb", "bc", "bc", "bab", "bab"},
+		{"c", "d", "d", "bc", "bc"},
+		{"e", "", "", "d", "d"},
+	}
+	for _, tt := range tests {
+		k, _, _ := r.Ceiling(tt.key)
+		assert.EqualValues(t, tt.ceiling, k, "ceiling of %q", tt.key)
+		k, _, _ = r.Higher(tt.key)
+		assert.EqualValues(t, tt.higher, k, "higher of %q", tt.key)
+		k, _, _ = r.Floor(tt.key)
+		assert.EqualValues(t, tt.floor, k, "floor of %q", tt.key)
+		k, _, _ = r.Lower(tt.key)
+		assert.EqualValues(t, tt.lower, k, "lower of %q", tt.key)
+	}
+	k, v, ok := r.First()
+	assert.True(t, ok)
+	assert.EqualValues(t, "b", k)
+	assert.EqualValues(t, "b", v)
+	k, _, _ = r.Last()
+	assert.EqualValues(t, "d", k)
+
+	_, _, ok = NewRax().First()
+	assert.False(t, ok)
+}
+
+func TestRax_Random(t *testing.T) {
+	r := NewRax()
+	model := make(map[string]int)
+	randomKey := func() string {
+		b := make([]byte, rand.Intn(5))
+		for i := range b {
+			b[i] = "abc"[rand.Intn(3)]
+		}
+		return string(b)
+	}
+	for i := 0; i < 5000; i++ {
+		key := randomKey()
+		if rand.Intn(3) == 0 {
+			_, exists := model[key]
+			assert.EqualValues(t, exists, r.Remove(key))
+			delete(model, key)
+		} else {
+			_, exists := model[key]
+			assert.EqualValues(t, !exists, r.Insert(key, i))
+			model[key] = i
+		}
+	}
+	assert.EqualValues(t, len(model), r.Len())
+
+	var keys []string
+	for key := range model {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	var walked []string
+	for key, value, ok := r.First(); ok; key, value, ok = r.Higher(key) {
+		assert.EqualValues(t, model[key], value)
+		walked = append(walked, key)
+	}
+	assert.EqualValues(t, keys, walked)
+	walked = nil
+	for key, _, ok := r.Last(); ok; key, _, ok = r.Lower(key) {
+		walked = append(walked, key)
+	}
+	slices.Reverse(walked)
+	assert.EqualValues(t, keys, walked)
+	for i := 0; i < 100; i++ {
+		key := randomKey()
+		i, _ := slices.BinarySearch(keys, key)
+		k, _, ok := r.Ceiling(key)
+		assert.EqualValues(t, i < len(keys), ok)
+		if ok {
+			assert.EqualValues(t, keys[i], k)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package data_structure
+
+import (
+	"github.com/spaolacci/murmur3"
+	"math"
+)
+
+// ScanIndex orders the elements of a collection by the hash of their name, so that the collection
+// can be iterated with a cursor like Redis SCAN. The cursor is the hash to resume from: adding or
+// removing elements does not move the others, so an element present during the whole iteration
+// is returned at least once, whatever happens to the collection between two calls.
+type ScanIndex struct {
+	sl *Skiplist // the score of a node is the hash of its element
+}
+
+func NewScanIndex() *ScanIndex {
+	return &ScanIndex{sl: CreateSkiplist()}
+}
+
+func scanHash(ele string) float64 {
+	// a 32-bit hash is represented exactly by a float64 score
+	return float64(murmur3.Sum32([]byte(ele)))
+}
+
+// Add indexes an element, the caller checks that it is not indexed yet
+func (idx *ScanIndex) Add(ele string) {
+	idx.sl.Insert(scanHash(ele), ele)
+}
+
+func (idx *ScanIndex) Remove(ele string) {
+	idx.sl.Delete(scanHash(ele), ele)
+}
+
+// Scan returns at least count elements from the cursor, in hash order, or the remaining ones.
+// The elements sharing the hash of the last one are returned too, so that the next call resumes
+// at the next hash. The returned cursor is 0 once the iteration is complete.
+func (idx *ScanIndex) Scan(cursor uint64, count int) ([]string, uint64) {
+	if cursor > math.MaxUint32 {
+		return nil, 0
+	}
+	from := float64(cursor)
+	// find the last node with a hash lower than the cursor
+	x := idx.sl.head
+	for i := idx.sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.score < from {
+			x = x.levels[i].forward
+		}
+	}
+
+	var elements []string
+	last := -1.0
+	for x = x.levels[0].forward; x != nil && (len(elements) < count || x.score == last); x = x.levels[0].forward {
+		elements = append(elements, x.ele)
+		last = x.score
+	}
+	if x == nil {
+		return elements, 0
+	}
+	return elements, uint64(x.score)
+}
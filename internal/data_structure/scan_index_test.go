@@ -0,0 +1,46 @@
+package data_structure
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScanIndex_ReturnsStableElements(t *testing.T) {
+	idx := NewScanIndex()
+	for i := 0; i < 1000; i++ {
+		idx.Add(fmt.Sprintf("stable%d", i))
+	}
+	seen := make(map[string]int)
+	var cursor uint64
+	for round := 0; ; round++ {
+		var elements []string
+		elements, cursor = idx.Scan(cursor, 10)
+		for _, ele := range elements {
+			seen[ele]++
+		}
+		// elements added and removed during the iteration do not move the others
+		idx.Add(fmt.Sprintf("added%d", round))
+		if round%2 == 1 {
+			idx.Remove(fmt.Sprintf("added%d", round-1))
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		assert.EqualValues(t, 1, seen[fmt.Sprintf("stable%d", i)], i)
+	}
+}
+
+func TestScanIndex_Empty(t *testing.T) {
+	idx := NewScanIndex()
+	elements, cursor := idx.Scan(0, 10)
+	assert.Empty(t, elements)
+	assert.EqualValues(t, 0, cursor)
+
+	idx.Add("a")
+	elements, cursor = idx.Scan(1<<40, 10)
+	assert.Empty(t, elements)
+	assert.EqualValues(t, 0, cursor)
+}
@@ -3,6 +3,8 @@ package data_structure
 type SimpleSet struct {
 	key  string
 	dict map[string]struct{}
+	// scanIndex is built by the first Scan, then kept up to date
+	scanIndex *ScanIndex
 }
 
 func NewSimpleSet(key string) *SimpleSet {
@@ -17,6 +19,9 @@ func (s *SimpleSet) Add(members ...string) int {
 	for _, m := range members {
 		if _, exist := s.dict[m]; !exist {
 			s.dict[m] = struct{}{}
+			if s.scanIndex != nil {
+				s.scanIndex.Add(m)
+			}
 			added++
 		}
 	}
@@ -28,6 +33,9 @@ func (s *SimpleSet) Rem(members ...string) int {
 	for _, m := range members {
 		if _, exist := s.dict[m]; exist {
 			delete(s.dict, m)
+			if s.scanIndex != nil {
+				s.scanIndex.Remove(m)
+			}
 			removed++
 		}
 	}
@@ -49,3 +57,84 @@ func (s *SimpleSet) Members() []string {
 	}
 	return m
 }
+
+func (s *SimpleSet) Len() int {
+	return len(s.dict)
+}
+
+// Scan returns the members from the cursor and the cursor of the next call, see ScanIndex.Scan
+func (s *SimpleSet) Scan(cursor uint64, count int) ([]string, uint64) {
+	if s.scanIndex == nil {
+		s.scanIndex = NewScanIndex()
+		for m := range s.dict {
+			s.scanIndex.Add(m)
+		}
+	}
+	return s.scanIndex.Scan(cursor, count)
+}
+
+// Clone returns a copy of the set
+func (s *SimpleSet) Clone() *SimpleSet {
+	clone := NewSimpleSet(s.key)
+	for m := range s.dict {
+		clone.dict[m] = struct{}{}
+	}
+	return clone
+}
+
+// Intersection returns a new set with the members present in every set
+func Intersection(sets ...*SimpleSet) *SimpleSet {
+	res := NewSimpleSet("")
+	if len(sets) == 0 {
+		return res
+	}
+	// iterate over the smallest set, as Redis does
+	smallest := sets[0]
+	for _, s := range sets[1:] {
+		if s.Len() < smallest.Len() {
+			smallest = s
+		}
+	}
+	for m := range smallest.dict {
+		inAll := true
+		for _, s := range sets {
+			if _, exist := s.dict[m]; !exist {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			res.dict[m] = struct{}{}
+		}
+	}
+	return res
+}
+
+// Union returns a new set with the members present in at least one set
+func Union(sets ...*SimpleSet) *SimpleSet {
+	res := NewSimpleSet("")
+	for _, s := range sets {
+		for m := range s.dict {
+			res.dict[m] = struct{}{}
+		}
+	}
+	return res
+}
+
+// Difference returns a new set with the members of first that are in none of the others
+func Difference(first *SimpleSet, others ...*SimpleSet) *SimpleSet {
+	res := NewSimpleSet("")
+	for m := range first.dict {
+		inOther := false
+		for _, s := range others {
+			if _, exist := s.dict[m]; exist {
+				inOther = true
+				break
+			}
+		}
+		if !inOther {
+			res.dict[m] = struct{}{}
+		}
+	}
+	return res
+}
@@ -4,6 +4,8 @@ type ZSet struct {
 	zskiplist *Skiplist
 	// map from ele to score
 	dict map[string]float64
+	// scanIndex is built by the first Scan, then kept up to date
+	scanIndex *ScanIndex
 }
 
 func CreateZSet() *ZSet {
@@ -28,6 +30,9 @@ func (zs *ZSet) Add(score float64, ele string) int {
 
 	znode := zs.zskiplist.Insert(score, ele)
 	zs.dict[ele] = znode.score
+	if zs.scanIndex != nil {
+		zs.scanIndex.Add(ele)
+	}
 	return 1
 }
 
@@ -63,3 +68,38 @@ func (zs *ZSet) GetScore(ele string) (int, float64) {
 func (zs *ZSet) Len() int {
 	return len(zs.dict)
 }
+
+// Scan returns the members from the cursor and the cursor of the next call, see ScanIndex.Scan
+func (zs *ZSet) Scan(cursor uint64, count int) ([]string, uint64) {
+	if zs.scanIndex == nil {
+		zs.scanIndex = NewScanIndex()
+		for ele := range zs.dict {
+			zs.scanIndex.Add(ele)
+		}
+	}
+	return zs.scanIndex.Scan(cursor, count)
+}
+
+// Clone returns a copy of the sorted set
+func (zs *ZSet) Clone() *ZSet {
+	clone := CreateZSet()
+	for ele, score := range zs.dict {
+		clone.Add(score, ele)
+	}
+	return clone
+}
+
+// PopMin removes and returns the member with the lowest score, ok is false if the set is empty
+func (zs *ZSet) PopMin() (ele string, score float64, ok bool) {
+	first := zs.zskiplist.head.levels[0].forward
+	if first == nil {
+		return "", 0, false
+	}
+	ele, score = first.ele, first.score
+	zs.zskiplist.Delete(score, ele)
+	delete(zs.dict, ele)
+	if zs.scanIndex != nil {
+		zs.scanIndex.Remove(ele)
+	}
+	return ele, score, true
+}
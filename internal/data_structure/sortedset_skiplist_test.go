@@ -48,3 +48,21 @@ func TestZSet_Skiplist_GetRank(t *testing.T) {
 	assert.EqualValues(t, 7, rank)
 	assert.EqualValues(t, 80.0, score)
 }
+
+func TestZSet_PopMin(t *testing.T) {
+	ss := CreateZSet()
+	ss.Add(20.0, "b")
+	ss.Add(10.0, "a")
+	ss.Add(10.0, "c")
+
+	for _, expected := range []string{"a", "c", "b"} {
+		ele, _, ok := ss.PopMin()
+		assert.True(t, ok)
+		assert.EqualValues(t, expected, ele)
+	}
+	assert.EqualValues(t, 0, ss.Len())
+	_, _, ok := ss.PopMin()
+	assert.False(t, ok)
+	rank, _ := ss.GetRank("a", false)
+	assert.EqualValues(t, -1, rank)
+}
@@ -1,8 +1,7 @@
 package data_structure
 
+// KeySpaceStat counts the keys of a keyspace, it is reported by INFO
 type KeySpaceStat struct {
 	Key    int64
 	Expire int64
 }
-
-var HashKeySpaceStat = KeySpaceStat{Key: 0, Expire: 0}
@@ -0,0 +1,453 @@
+package data_structure
+
+import (
+	"Nietzsche/internal/config"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// StreamID is the ID of a stream entry, <ms>-<seq>: the unix time in milliseconds of the entry
+// and a sequence number for the entries of the same millisecond
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d-%d", id.Ms, id.Seq)
+}
+
+// Compare returns -1, 0 or 1 if id is less than, equal to or greater than other
+func (id StreamID) Compare(other StreamID) int {
+	switch {
+	case id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq):
+		return -1
+	case id == other:
+		return 0
+	}
+	return 1
+}
+
+// Incr returns the ID following id, ok is false if id is the greatest ID
+func (id StreamID) Incr() (next StreamID, ok bool) {
+	switch {
+	case id.Seq < 1<<64-1:
+		return StreamID{id.Ms, id.Seq + 1}, true
+	case id.Ms < 1<<64-1:
+		return StreamID{id.Ms + 1, 0}, true
+	}
+	return id, false
+}
+
+// Decr returns the ID preceding id, ok is false if id is 0-0
+func (id StreamID) Decr() (prev StreamID, ok bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{id.Ms, id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{id.Ms - 1, 1<<64 - 1}, true
+	}
+	return id, false
+}
+
+// raxKey returns the ID as a big endian string, so that the keys of the rax are ordered like the IDs
+func (id StreamID) raxKey() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], id.Ms)
+	binary.BigEndian.PutUint64(b[8:], id.Seq)
+	return string(b[:])
+}
+
+func streamIDFromRaxKey(key string) StreamID {
+	return StreamID{binary.BigEndian.Uint64([]byte(key[:8])), binary.BigEndian.Uint64([]byte(key[8:]))}
+}
+
+// StreamEntry is an entry of a stream, Fields alternates its fields and values
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// Stream is an append only log of entries ordered by ID, like the stream of Redis. The entries are
+// stored in listpacks of at most config.StreamNodeMaxEntries entries and config.StreamNodeMaxBytes
+// bytes, indexed by the ID of their first entry in a radix tree. A listpack starts with a master
+// entry
+//
+//	<count> <deleted> <number of fields> <field 1> ... <field N> 0
+//
+// holding the number of valid and deleted entries of the listpack and the fields of its first
+// entry, followed by the entries
+//
+//	<flags> <ms - master ms> <seq - master seq> [<number of fields> <field 1> <value 1> ... | <value 1> ...] <lp-count>
+//
+// The fields of an entry having the same fields as the master entry are not repeated. lp-count is
+// the number of listpack entries of the entry before it, so that the entries can be walked
+// backwards. Deleted entries are flagged, and a listpack is freed once all its entries are deleted.
+type Stream struct {
+	rax    *Rax
+	length int
+	// lastID is the ID of the last entry added, an added entry must have a greater ID even
+	// if this one was deleted
+	lastID StreamID
+}
+
+const (
+	streamEntryDeleted    = 1
+	streamEntrySameFields = 2
+)
+
+func NewStream() *Stream {
+	return &Stream{rax: NewRax()}
+}
+
+// Len returns the number of entries
+func (s *Stream) Len() int {
+	return s.length
+}
+
+// LastID returns the ID of the last entry added to the stream, 0-0 if none was added
+func (s *Stream) LastID() StreamID {
+	return s.lastID
+}
+
+// SetLastID sets the ID of the last entry, for an empty stream created with a given ID
+func (s *Stream) SetLastID(id StreamID) {
+	s.lastID = id
+}
+
+// streamNode is a listpack of the stream and the ID of its master entry
+type streamNode struct {
+	lp     *Listpack
+	master StreamID
+}
+
+func nodeOf(key string, value any) streamNode {
+	return streamNode{lp: value.(*Listpack), master: streamIDFromRaxKey(key)}
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func (n streamNode) count() int {
+	return atoi(n.lp.Get(n.lp.First()))
+}
+
+func (n streamNode) deleted() int {
+	return atoi(n.lp.Get(n.lp.Next(n.lp.First())))
+}
+
+// setCounts updates the numbers of valid and deleted entries of the master entry
+func (n streamNode) setCounts(count, deleted int) {
+	p := n.lp.First()
+	n.lp.Replace(p, strconv.Itoa(count))
+	n.lp.Replace(n.lp.Next(p), strconv.Itoa(deleted))
+}
+
+// masterFields returns the fields of the master entry and the position of its first entry
+func (n streamNode) masterFields() ([]string, int) {
+	p := n.lp.Next(n.lp.Next(n.lp.First()))
+	fields := make([]string, atoi(n.lp.Get(p)))
+	for i := range fields {
+		p = n.lp.Next(p)
+		fields[i] = n.lp.Get(p)
+	}
+	// skip the terminator of the master entry
+	return fields, n.lp.Next(n.lp.Next(p))
+}
+
+// streamEntryPos is the position of an entry in a listpack, with its ID and flags
+type streamEntryPos struct {
+	id    StreamID
+	flags int
+	p     int // the position of the flags
+	end   int // the position of the lp-count
+}
+
+// entryAt reads the entry whose flags are at p, numMaster is the number of fields of the master entry
+func (n streamNode) entryAt(p, numMaster int) streamEntryPos {
+	e := streamEntryPos{p: p, flags: atoi(n.lp.Get(p))}
+	q := n.lp.Next(p)
+	msDiff, _ := strconv.ParseInt(n.lp.Get(q), 10, 64)
+	q = n.lp.Next(q)
+	seqDiff, _ := strconv.ParseInt(n.lp.Get(q), 10, 64)
+	e.id = StreamID{n.master.Ms + uint64(msDiff), n.master.Seq + uint64(seqDiff)}
+	skip := numMaster
+	if e.flags&streamEntrySameFields == 0 {
+		q = n.lp.Next(q)
+		skip = 2 * atoi(n.lp.Get(q))
+	}
+	for ; skip >= 0; skip-- {
+		q = n.lp.Next(q)
+	}
+	e.end = q
+	return e
+}
+
+// entryBefore returns the position of the entry whose lp-count is at end, -1 if end is the
+// terminator of the master entry, the only element read as an lp-count of 0
+func (n streamNode) entryBefore(end int) int {
+	k := atoi(n.lp.Get(end))
+	if k == 0 {
+		return -1
+	}
+	for ; k > 0; k-- {
+		end = n.lp.Prev(end)
+	}
+	return end
+}
+
+// fields returns the fields and values of the entry
+func (n streamNode) fields(e streamEntryPos, master []string) []string {
+	q := n.lp.Next(n.lp.Next(e.p))
+	if e.flags&streamEntrySameFields != 0 {
+		fields := make([]string, 0, 2*len(master))
+		for _, f := range master {
+			q = n.lp.Next(q)
+			fields = append(fields, f, n.lp.Get(q))
+		}
+		return fields
+	}
+	q = n.lp.Next(q)
+	fields := make([]string, 2*atoi(n.lp.Get(q)))
+	for i := range fields {
+		q = n.lp.Next(q)
+		fields[i] = n.lp.Get(q)
+	}
+	return fields
+}
+
+// sameFields reports whether fields, alternating fields and values, has the fields of master
+func sameFields(fields, master []string) bool {
+	if len(fields) != 2*len(master) {
+		return false
+	}
+	for i, f := range master {
+		if fields[2*i] != f {
+			return false
+		}
+	}
+	return true
+}
+
+// newStreamNode returns a listpack whose master entry has the fields of the first entry
+func newStreamNode(fields []string) *Listpack {
+	lp := NewListpack()
+	lp.Append("0")
+	lp.Append("0")
+	lp.Append(strconv.Itoa(len(fields) / 2))
+	for i := 0; i < len(fields); i += 2 {
+		lp.Append(fields[i])
+	}
+	lp.Append("0")
+	return lp
+}
+
+// Add appends an entry, id must be greater than LastID. fields alternates fields and values.
+func (s *Stream) Add(id StreamID, fields []string) {
+	var n streamNode
+	key, value, ok := s.rax.Last()
+	if ok {
+		n = nodeOf(key, value)
+		if !n.fits(fields) {
+			ok = false
+		}
+	}
+	if !ok {
+		n = streamNode{lp: newStreamNode(fields), master: id}
+		s.rax.Insert(id.raxKey(), n.lp)
+	}
+	master, _ := n.masterFields()
+	flags, lpCount := 0, 3
+	if sameFields(fields, master) {
+		flags = streamEntrySameFields
+	}
+	n.lp.Append(strconv.Itoa(flags))
+	n.lp.Append(strconv.FormatInt(int64(id.Ms-n.master.Ms), 10))
+	n.lp.Append(strconv.FormatInt(int64(id.Seq-n.master.Seq), 10))
+	if flags&streamEntrySameFields != 0 {
+		for i := 1; i < len(fields); i += 2 {
+			n.lp.Append(fields[i])
+		}
+		lpCount += len(master)
+	} else {
+		n.lp.Append(strconv.Itoa(len(fields) / 2))
+		for _, f := range fields {
+			n.lp.Append(f)
+		}
+		lpCount += 1 + len(fields)
+	}
+	n.lp.Append(strconv.Itoa(lpCount))
+	n.setCounts(n.count()+1, n.deleted())
+	s.length++
+	s.lastID = id
+}
+
+// fits reports whether an entry with the given fields can be appended to the node without
+// exceeding the limits of config.StreamNodeMaxEntries and config.StreamNodeMaxBytes, 0 meaning no limit
+func (n streamNode) fits(fields []string) bool {
+	if config.StreamNodeMaxEntries > 0 && n.count()+n.deleted() >= config.StreamNodeMaxEntries {
+		return false
+	}
+	if config.StreamNodeMaxBytes > 0 {
+		size := n.lp.Bytes()
+		for _, f := range fields {
+			size += entrySize(f)
+		}
+		if size > config.StreamNodeMaxBytes {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteEntry flags the entry as deleted, and frees the node if it has no entry left
+func (s *Stream) deleteEntry(key string, n streamNode, e streamEntryPos) {
+	n.lp.Replace(e.p, strconv.Itoa(e.flags|streamEntryDeleted))
+	count := n.count() - 1
+	if count == 0 {
+		s.rax.Remove(key)
+	} else {
+		n.setCounts(count, n.deleted()+1)
+	}
+	s.length--
+}
+
+// Delete deletes the entry with the given ID, it returns false if there is none
+func (s *Stream) Delete(id StreamID) bool {
+	key, value, ok := s.rax.Floor(id.raxKey())
+	if !ok {
+		return false
+	}
+	n := nodeOf(key, value)
+	master, p := n.masterFields()
+	for p != -1 {
+		e := n.entryAt(p, len(master))
+		if c := e.id.Compare(id); c > 0 {
+			return false
+		} else if c == 0 {
+			if e.flags&streamEntryDeleted != 0 {
+				return false
+			}
+			s.deleteEntry(key, n, e)
+			return true
+		}
+		p = n.lp.Next(e.end)
+	}
+	return false
+}
+
+// Range returns the entries from start to end included, at most count entries if count is
+// positive. The entries are in reverse order, from end to start, if rev is true.
+func (s *Stream) Range(start, end StreamID, count int, rev bool) []StreamEntry {
+	var entries []StreamEntry
+	if start.Compare(end) > 0 {
+		return entries
+	}
+	full := func() bool { return count > 0 && len(entries) == count }
+	if !rev {
+		key, value, ok := s.rax.Floor(start.raxKey())
+		if !ok {
+			key, value, ok = s.rax.First()
+		}
+		for ; ok && !full(); key, value, ok = s.rax.Higher(key) {
+			n := nodeOf(key, value)
+			master, p := n.masterFields()
+			for p != -1 && !full() {
+				e := n.entryAt(p, len(master))
+				if e.id.Compare(end) > 0 {
+					return entries
+				}
+				if e.flags&streamEntryDeleted == 0 && e.id.Compare(start) >= 0 {
+					entries = append(entries, StreamEntry{e.id, n.fields(e, master)})
+				}
+				p = n.lp.Next(e.end)
+			}
+		}
+		return entries
+	}
+	key, value, ok := s.rax.Floor(end.raxKey())
+	for ; ok && !full(); key, value, ok = s.rax.Lower(key) {
+		n := nodeOf(key, value)
+		master, _ := n.masterFields()
+		for p := n.entryBefore(n.lp.Last()); p != -1 && !full(); {
+			e := n.entryAt(p, len(master))
+			if e.id.Compare(start) < 0 {
+				return entries
+			}
+			if e.flags&streamEntryDeleted == 0 && e.id.Compare(end) <= 0 {
+				entries = append(entries, StreamEntry{e.id, n.fields(e, master)})
+			}
+			p = n.entryBefore(n.lp.Prev(e.p))
+		}
+	}
+	return entries
+}
+
+// Trim deletes the oldest entries, while the stream has more than maxLen entries, or if byID is
+// true while they have an ID less than minID. If approx is true, only whole listpacks are freed,
+// so that more than maxLen entries, or entries less than minID, may be kept. limit, if positive,
+// is the maximum number of entries deleted. Trim returns the number of deleted entries.
+func (s *Stream) Trim(maxLen int, minID StreamID, byID, approx bool, limit int) int {
+	deleted := 0
+	for {
+		key, value, ok := s.rax.First()
+		if !ok || (!byID && s.length <= maxLen) {
+			return deleted
+		}
+		n := nodeOf(key, value)
+		count := n.count()
+		if limit > 0 && deleted+count > limit {
+			return deleted
+		}
+		master, _ := n.masterFields()
+		var removeNode bool
+		if byID {
+			last := n.entryAt(n.entryBefore(n.lp.Last()), len(master))
+			removeNode = last.id.Compare(minID) < 0
+		} else {
+			removeNode = s.length-count >= maxLen
+		}
+		if removeNode {
+			s.rax.Remove(key)
+			s.length -= count
+			deleted += count
+			continue
+		}
+		if approx {
+			return deleted
+		}
+		// the last entries to delete are in this node. They are flagged first, flagging does not
+		// move the entries, then the counts of the master entry are updated.
+		_, p := n.masterFields()
+		flagged := 0
+		for p != -1 {
+			e := n.entryAt(p, len(master))
+			if (byID && e.id.Compare(minID) >= 0) || (!byID && s.length <= maxLen) {
+				break
+			}
+			if e.flags&streamEntryDeleted == 0 {
+				n.lp.Replace(e.p, strconv.Itoa(e.flags|streamEntryDeleted))
+				s.length--
+				flagged++
+			}
+			p = n.lp.Next(e.end)
+		}
+		if flagged == count {
+			s.rax.Remove(key)
+		} else {
+			n.setCounts(count-flagged, n.deleted()+flagged)
+		}
+		return deleted + flagged
+	}
+}
+
+// Clone returns a copy of the stream
+func (s *Stream) Clone() *Stream {
+	clone := &Stream{rax: NewRax(), length: s.length, lastID: s.lastID}
+	for key, value, ok := s.rax.First(); ok; key, value, ok = s.rax.Higher(key) {
+		clone.rax.Insert(key, value.(*Listpack).Clone())
+	}
+	return clone
+}
@@ -0,0 +1,178 @@
+package data_structure
+
+import (
+	"Nietzsche/internal/config"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func withStreamNodeMaxEntries(t *testing.T, n int) {
+	old := config.StreamNodeMaxEntries
+	config.StreamNodeMaxEntries = n
+	t.Cleanup(func() { config.StreamNodeMaxEntries = old })
+}
+
+func streamIDs(entries []StreamEntry) []string {
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID.String()
+	}
+	return ids
+}
+
+var maxStreamID = StreamID{1<<64 - 1, 1<<64 - 1}
+
+func TestStreamID(t *testing.T) {
+	id := StreamID{5, 1<<64 - 1}
+	next, ok := id.Incr()
+	assert.True(t, ok)
+	assert.EqualValues(t, StreamID{6, 0}, next)
+	prev, ok := next.Decr()
+	assert.True(t, ok)
+	assert.EqualValues(t, id, prev)
+	_, ok = maxStreamID.Incr()
+	assert.False(t, ok)
+	_, ok = StreamID{}.Decr()
+	assert.False(t, ok)
+	assert.EqualValues(t, -1, StreamID{1, 9}.Compare(StreamID{2, 0}))
+	assert.EqualValues(t, 1, StreamID{2, 1}.Compare(StreamID{2, 0}))
+	assert.EqualValues(t, "2-1", StreamID{2, 1}.String())
+	assert.EqualValues(t, StreamID{2, 1}, streamIDFromRaxKey(StreamID{2, 1}.raxKey()))
+}
+
+func TestStream_AddRange(t *testing.T) {
+	withStreamNodeMaxEntries(t, 3)
+	s := NewStream()
+	s.Add(StreamID{1, 0}, []string{"a", "1", "b", "2"})
+	s.Add(StreamID{1, 1}, []string{"a", "3", "b", "4"}) // same fields as the master entry
+	s.Add(StreamID{2, 0}, []string{"c", "5"})
+	s.Add(StreamID{3, 0}, []string{"a", "6"}) // in a new node
+	assert.EqualValues(t, 4, s.Len())
+	assert.EqualValues(t, 2, s.rax.Len())
+	assert.EqualValues(t, StreamID{3, 0}, s.LastID())
+
+	entries := s.Range(StreamID{}, maxStreamID, 0, false)
+	assert.EqualValues(t, []StreamEntry{
+		{StreamID{1, 0}, []string{"a", "1", "b", "2"}},
+		{StreamID{1, 1}, []string{"a", "3", "b", "4"}},
+		{StreamID{2, 0}, []string{"c", "5"}},
+		{StreamID{3, 0}, []string{"a", "6"}},
+	}, entries)
+
+	assert.EqualValues(t, []string{"1-1", "2-0"}, streamIDs(s.Range(StreamID{1, 1}, StreamID{2, 5}, 0, false)))
+	assert.EqualValues(t, []string{"1-1", "2-0"}, streamIDs(s.Range(StreamID{1, 1}, maxStreamID, 2, false)))
+	assert.EqualValues(t, []string{"3-0", "2-0", "1-1", "1-0"}, streamIDs(s.Range(StreamID{}, maxStreamID, 0, true)))
+	assert.EqualValues(t, []string{"2-0", "1-1"}, streamIDs(s.Range(StreamID{1, 1}, StreamID{2, 5}, 0, true)))
+	assert.EqualValues(t, []string{"3-0"}, streamIDs(s.Range(StreamID{}, maxStreamID, 1, true)))
+	assert.Empty(t, s.Range(StreamID{2, 1}, StreamID{2, 9}, 0, false))
+	assert.Empty(t, s.Range(StreamID{3, 0}, StreamID{1, 0}, 0, false))
+}
+
+func TestStream_Delete(t *testing.T) {
+	withStreamNodeMaxEntries(t, 2)
+	s := NewStream()
+	for i := 1; i <= 5; i++ {
+		s.Add(StreamID{uint64(i), 0}, []string{"f", fmt.Sprint(i)})
+	}
+	assert.EqualValues(t, 3, s.rax.Len())
+	assert.True(t, s.Delete(StreamID{2, 0}))
+	assert.False(t, s.Delete(StreamID{2, 0}), "already deleted")
+	assert.False(t, s.Delete(StreamID{2, 1}))
+	assert.False(t, s.Delete(StreamID{9, 0}))
+	assert.EqualValues(t, []string{"1-0", "3-0", "4-0", "5-0"}, streamIDs(s.Range(StreamID{}, maxStreamID, 0, false)))
+	assert.EqualValues(t, []string{"5-0", "4-0", "3-0", "1-0"}, streamIDs(s.Range(StreamID{}, maxStreamID, 0, true)))
+
+	// the node of 3-0 and 4-0 is freed with its last entry
+	assert.True(t, s.Delete(StreamID{3, 0}))
+	assert.True(t, s.Delete(StreamID{4, 0}))
+	assert.EqualValues(t, 2, s.rax.Len())
+	assert.EqualValues(t, 2, s.Len())
+	// the last ID does not change
+	assert.True(t, s.Delete(StreamID{5, 0}))
+	assert.EqualValues(t, StreamID{5, 0}, s.LastID())
+}
+
+func TestStream_Trim(t *testing.T) {
+	withStreamNodeMaxEntries(t, 3)
+	fill := func() *Stream {
+		s := NewStream()
+		for i := 1; i <= 10; i++ {
+			s.Add(StreamID{uint64(i), 0}, []string{"f", "v"})
+		}
+		return s
+	}
+
+	s := fill()
+	assert.EqualValues(t, 6, s.Trim(4, StreamID{}, false, false, 0))
+	assert.EqualValues(t, []string{"7-0", "8-0", "9-0", "10-0"}, streamIDs(s.Range(StreamID{}, maxStreamID, 0, false)))
+
+	// only the whole nodes 1-3 and 4-6 are freed
+	s = fill()
+	assert.EqualValues(t, 6, s.Trim(2, StreamID{}, false, true, 0))
+	assert.EqualValues(t, 4, s.Len())
+
+	s = fill()
+	assert.EqualValues(t, 3, s.Trim(0, StreamID{}, false, true, 5), "limited to the first node")
+	assert.EqualValues(t, 7, s.Len())
+
+	s = fill()
+	assert.EqualValues(t, 4, s.Trim(0, StreamID{5, 0}, true, false, 0))
+	assert.EqualValues(t, "5-0", s.Range(StreamID{}, maxStreamID, 1, false)[0].ID.String())
+	assert.EqualValues(t, 0, s.Trim(0, StreamID{5, 0}, true, false, 0))
+
+	s = fill()
+	assert.EqualValues(t, 3, s.Trim(0, StreamID{5, 0}, true, true, 0))
+	assert.EqualValues(t, "4-0", s.Range(StreamID{}, maxStreamID, 1, false)[0].ID.String())
+}
+
+func TestStream_Random(t *testing.T) {
+	withStreamNodeMaxEntries(t, 5)
+	s := NewStream()
+	var model []StreamEntry
+	id := StreamID{}
+	for i := 0; i < 3000; i++ {
+		switch op := rand.Intn(10); {
+		case op < 6:
+			if rand.Intn(2) == 0 {
+				id = StreamID{id.Ms + 1, 0}
+			} else {
+				id, _ = id.Incr()
+			}
+			fields := []string{"a", fmt.Sprint(i)}
+			if rand.Intn(3) == 0 {
+				fields = append(fields, "b", "x")
+			}
+			s.Add(id, fields)
+			model = append(model, StreamEntry{id, fields})
+		case op < 9 && len(model) > 0:
+			j := rand.Intn(len(model))
+			assert.True(t, s.Delete(model[j].ID))
+			model = slices.Delete(model, j, j+1)
+		default:
+			maxLen := len(model) - rand.Intn(3)
+			deleted := s.Trim(maxLen, StreamID{}, false, false, 0)
+			assert.EqualValues(t, max(len(model)-max(maxLen, 0), 0), deleted)
+			model = model[deleted:]
+		}
+		assert.EqualValues(t, len(model), s.Len())
+	}
+	entries := s.Range(StreamID{}, maxStreamID, 0, false)
+	assert.EqualValues(t, len(model), len(entries))
+	for i := range min(len(model), len(entries)) {
+		assert.EqualValues(t, model[i], entries[i])
+	}
+	entries = s.Range(StreamID{}, maxStreamID, 0, true)
+	slices.Reverse(entries)
+	assert.EqualValues(t, len(model), len(entries))
+	for i := range min(len(model), len(entries)) {
+		assert.EqualValues(t, model[i].ID, entries[i].ID)
+	}
+
+	clone := s.Clone()
+	s.Trim(0, StreamID{}, false, false, 0)
+	assert.EqualValues(t, 0, s.Len())
+	assert.EqualValues(t, len(model), len(clone.Range(StreamID{}, maxStreamID, 0, false)))
+}
@@ -0,0 +1,168 @@
+// Package glob implements the glob-style patterns of Redis, used by KEYS, SCAN MATCH and CONFIG GET.
+// The matching is the same as the stringmatchlen function of Redis:
+//   - * matches any sequence of characters, ? matches any character
+//   - [abc] matches one of the characters, [^abc] any other character and [a-z] a range
+//   - \ escapes the next character, a trailing backslash matches itself
+//
+// Like Redis, an empty string only matches an empty pattern, an unterminated class "[abc" ends
+// with the pattern, and a pattern nesting more than maxNesting stars never matches.
+package glob
+
+// maxNesting is the number of stars followed by other characters that a pattern can have,
+// beyond which Redis protects itself against abusive patterns by not matching
+const maxNesting = 1000
+
+// Match reports whether s matches the pattern
+func Match(pattern, s string) bool {
+	return match(pattern, s, false)
+}
+
+// MatchNoCase reports whether s matches the pattern, ignoring the case of ASCII letters
+func MatchNoCase(pattern, s string) bool {
+	return match(pattern, s, true)
+}
+
+func match(pattern, s string, nocase bool) bool {
+	if len(s) == 0 {
+		return len(pattern) == 0
+	}
+	if nestedStars(pattern) > maxNesting {
+		return false
+	}
+	p, i := 0, 0
+	// position of the last star in the pattern and of the string it matched up to, to backtrack
+	starP, starI := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starI = p, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if ok, next := matchClass(pattern, p, s[i], nocase); ok {
+					p = next
+					i++
+					continue
+				}
+			case '\\':
+				c := pattern[p]
+				if p+1 < len(pattern) {
+					c = pattern[p+1]
+				}
+				if equal(c, s[i], nocase) {
+					p = min(p+2, len(pattern))
+					i++
+					continue
+				}
+			default:
+				if equal(pattern[p], s[i], nocase) {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		// let the last star match one more character
+		starI++
+		p, i = starP+1, starI
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass matches c with the class of characters starting at the bracket pattern[p].
+// It returns the position following the class, an unterminated class ends with the pattern.
+// As in Redis, a '-' right before the closing bracket makes a range ending with the bracket.
+func matchClass(pattern string, p int, c byte, nocase bool) (bool, int) {
+	j := p + 1
+	negate := j < len(pattern) && pattern[j] == '^'
+	if negate {
+		j++
+	}
+	matched := false
+	for ; j < len(pattern) && pattern[j] != ']'; j++ {
+		switch {
+		case pattern[j] == '\\' && j+1 < len(pattern):
+			j++
+			matched = matched || pattern[j] == c
+		case j+2 < len(pattern) && pattern[j+1] == '-':
+			start, end, c := pattern[j], pattern[j+2], c
+			if start > end {
+				start, end = end, start
+			}
+			if nocase {
+				start, end, c = toLower(start), toLower(end), toLower(c)
+			}
+			matched = matched || (c >= start && c <= end)
+			j += 2
+		default:
+			matched = matched || equal(pattern[j], c, nocase)
+		}
+	}
+	return matched != negate, min(j+1, len(pattern))
+}
+
+// nestedStars returns the number of groups of stars followed by other characters in the pattern
+func nestedStars(pattern string) int {
+	count := 0
+	for p := 0; p < len(pattern); {
+		switch pattern[p] {
+		case '*':
+			for p < len(pattern) && pattern[p] == '*' {
+				p++
+			}
+			if p < len(pattern) {
+				count++
+			}
+		case '[':
+			_, p = matchClass(pattern, p, 0, false)
+		case '\\':
+			p += 2
+		default:
+			p++
+		}
+	}
+	return count
+}
+
+func equal(a, b byte, nocase bool) bool {
+	if nocase {
+		return toLower(a) == toLower(b)
+	}
+	return a == b
+}
+
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// LiteralPrefix returns the characters that every string matching the pattern starts with,
+// the escaped characters being unescaped. literal is true if the pattern matches only this string.
+func LiteralPrefix(pattern string) (prefix string, literal bool) {
+	buf := make([]byte, 0, len(pattern))
+	for p := 0; p < len(pattern); p++ {
+		switch pattern[p] {
+		case '*', '?', '[':
+			return string(buf), false
+		case '\\':
+			if p+1 < len(pattern) {
+				p++
+			}
+		}
+		buf = append(buf, pattern[p])
+	}
+	return string(buf), true
+}
@@ -0,0 +1,195 @@
+package glob
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*", "anything", true},
+		{"*", "", false},
+		{"", "", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hllo", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`[\]]`, "]", true},
+		{`ab\`, `ab\`, true},
+		{"[abc", "b", true},
+		{"[^", "x", true},
+		{"[a-]x", "]", true},
+		{"*a*b*c", "xxaxxbxxc", true},
+		{"*a*b*c", "xxaxxcxxb", false},
+		{"user:*", "user:1", true},
+		{"user:*", "usr:1", false},
+	} {
+		assert.EqualValues(t, tc.match, Match(tc.pattern, tc.s), "%q %q", tc.pattern, tc.s)
+	}
+}
+
+func TestMatchNoCase(t *testing.T) {
+	assert.True(t, MatchNoCase("MAX*", "maxmemory"))
+	assert.True(t, MatchNoCase("[A-C]x", "bX"))
+	assert.False(t, Match("MAX*", "maxmemory"))
+}
+
+func TestMatch_AbusivePattern(t *testing.T) {
+	pattern := strings.Repeat("*a", 1001)
+	s := strings.Repeat("a", 1001)
+	assert.False(t, Match(pattern, s))
+	assert.True(t, Match(strings.Repeat("*a", 1000), s))
+	assert.True(t, Match(strings.Repeat("*a", 1000)+"*", s))
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, prefix string
+		literal         bool
+	}{
+		{"user:{42}:*", "user:{42}:", false},
+		{`a\*b`, "a*b", true},
+		{`a\`, `a\`, true},
+		{"?", "", false},
+		{"ab[cd]", "ab", false},
+		{"", "", true},
+	} {
+		prefix, literal := LiteralPrefix(tc.pattern)
+		assert.EqualValues(t, tc.prefix, prefix, tc.pattern)
+		assert.EqualValues(t, tc.literal, literal, tc.pattern)
+	}
+}
+
+// stringmatchlen is a line by line port of the function of Redis (src/util.c), used as a reference
+func stringmatchlen(pattern, str string, nocase bool, skipLongerMatches *bool, nesting int) bool {
+	if nesting > 1000 {
+		return false
+	}
+	// at reads the pattern like a C string, with a NUL terminator
+	at := func(i int) byte {
+		if i < len(pattern) {
+			return pattern[i]
+		}
+		return 0
+	}
+	lower := func(c byte) byte {
+		if nocase {
+			return toLower(c)
+		}
+		return c
+	}
+	p, s := 0, 0
+	for p < len(pattern) && s < len(str) {
+		switch pattern[p] {
+		case '*':
+			for p < len(pattern) && at(p+1) == '*' {
+				p++
+			}
+			if len(pattern)-p == 1 {
+				return true
+			}
+			for s < len(str) {
+				if stringmatchlen(pattern[p+1:], str[s:], nocase, skipLongerMatches, nesting+1) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+				s++
+			}
+			*skipLongerMatches = true
+			return false
+		case '?':
+			s++
+		case '[':
+			p++
+			not := at(p) == '^'
+			if not {
+				p++
+			}
+			match := false
+			for {
+				if at(p) == '\\' && len(pattern)-p >= 2 {
+					p++
+					if pattern[p] == str[s] {
+						match = true
+					}
+				} else if at(p) == ']' {
+					break
+				} else if len(pattern)-p == 0 {
+					p--
+					break
+				} else if len(pattern)-p >= 3 && pattern[p+1] == '-' {
+					start, end, c := pattern[p], pattern[p+2], str[s]
+					if start > end {
+						start, end = end, start
+					}
+					start, end, c = lower(start), lower(end), lower(c)
+					p += 2
+					if c >= start && c <= end {
+						match = true
+					}
+				} else if lower(pattern[p]) == lower(str[s]) {
+					match = true
+				}
+				p++
+			}
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			s++
+		case '\\':
+			if len(pattern)-p >= 2 {
+				p++
+			}
+			fallthrough
+		default:
+			if lower(pattern[p]) != lower(str[s]) {
+				return false
+			}
+			s++
+		}
+		p++
+		if s == len(str) {
+			for at(p) == '*' {
+				p++
+			}
+			break
+		}
+	}
+	return p >= len(pattern) && s == len(str)
+}
+
+func FuzzMatch(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"*", ""}, {"h?llo", "hello"}, {"h[^e]llo", "hallo"}, {"[a-]x", "]x"}, {`\`, `\`},
+		{"[^", "x"}, {"*a*b", "aXbab"}, {"[Z-a]", "z"}, {`[\`, `\`}, {"a**?", "ab"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, pattern, s string) {
+		for _, nocase := range []bool{false, true} {
+			skip := false
+			want := stringmatchlen(pattern, s, nocase, &skip, 0)
+			if got := match(pattern, s, nocase); got != want {
+				t.Fatalf("match(%q, %q, nocase=%v) = %v, stringmatchlen = %v", pattern, s, nocase, got, want)
+			}
+		}
+	})
+}
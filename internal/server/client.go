@@ -0,0 +1,207 @@
+package server
+
+import (
+	"Nietzsche/internal/constant"
+	"Nietzsche/internal/core"
+	"Nietzsche/internal/core/io_multiplexing"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+)
+
+// client holds the per-connection state shared by all server modes
+type client struct {
+	fd      int
+	readBuf []byte
+	reader  *core.CommandReader // input buffer, keeps incomplete commands between reads
+	// output buffer, replies that could not be written to the socket yet, from sent
+	outBuf []byte
+	sent   int // bytes of outBuf already written
+	// true while the multiplexer also monitors the fd for writability
+	waitingWritable bool
+	// bc receives the replies of the blocking commands, it is created by the first one.
+	// While blocked, the commands following the blocking one wait in the input buffer.
+	bc        *core.BlockedClient
+	blocked   bool
+	blockedOn int // the worker the client is blocked in, for the multi-threaded server
+	// set once the client's SHUTDOWN is accepted, the commands it sent after are not executed
+	shuttingDown bool
+}
+
+func newClient(fd int) *client {
+	return &client{
+		fd:      fd,
+		readBuf: make([]byte, constant.IOBufferSize),
+		reader:  core.NewCommandReader(),
+	}
+}
+
+// readInput reads the available bytes from the socket into the input buffer.
+// With drain set it keeps reading until EAGAIN, as required by an edge-triggered multiplexer
+// which reports no new event for data already waiting in the socket.
+func (c *client) readInput(drain bool) error {
+	for {
+		n, err := syscall.Read(c.fd, c.readBuf)
+		if err == syscall.EAGAIN {
+			// no more data for now, or spurious wakeup
+			return nil
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.EOF
+		}
+		if err := c.reader.Feed(c.readBuf[:n]); err != nil {
+			// the commands already received are executed before the error is replied
+			return err
+		}
+		if !drain {
+			return nil
+		}
+	}
+}
+
+// nextCommand returns the next complete command in the input buffer, or nil if there is none
+func (c *client) nextCommand() (*core.Command, error) {
+	return c.reader.Next()
+}
+
+// executeFunc runs a command of the client and returns its reply. A blocking command
+// can block the client instead, it then sets c.blocked and returns nil.
+type executeFunc func(c *client, cmd *core.Command) []byte
+
+// processInput executes every complete command in the input buffer in arrival order
+// and queues the replies in the output buffer in the same order. It stops at a command
+// blocking the client, see resume, or at an accepted SHUTDOWN.
+func (c *client) processInput(execute executeFunc) error {
+	for !c.blocked && !c.shuttingDown {
+		cmd, err := c.nextCommand()
+		if err != nil {
+			if errors.Is(err, core.ErrProtocol) {
+				// tell the client why its input is rejected before the connection is closed
+				c.addReply(core.Encode(fmt.Errorf("ERR %v", err), false))
+				_ = c.flushOutput()
+			}
+			return err
+		}
+		if cmd == nil {
+			return nil
+		}
+		res := execute(c, cmd)
+		// SHUTDOWN has no reply unless it fails
+		c.shuttingDown = res == nil && !c.blocked && strings.EqualFold(cmd.Cmd, "shutdown")
+		c.addReply(res)
+	}
+	return nil
+}
+
+// isBlocking reports whether the command may block the client, like BLPOP
+func isBlocking(cmd *core.Command) bool {
+	spec := core.LookupCommand(cmd.Cmd)
+	return spec != nil && spec.HasFlag(core.FlagBlocking) && spec.CheckArity(cmd.Args)
+}
+
+// resume queues the reply of the command the client was blocked on, then executes the commands
+// that followed it and writes the replies, like handleEvent
+func (c *client) resume(res []byte, multiplexer io_multiplexing.IOMultiplexer, execute executeFunc) error {
+	c.blocked = false
+	c.addReply(res)
+	if err := c.processInput(execute); err != nil {
+		return err
+	}
+	if err := c.flushOutput(); err != nil {
+		return err
+	}
+	return c.updateWriteInterest(multiplexer)
+}
+
+// handleEvent reacts to a readiness event of the client's fd: it reads and executes the
+// pending commands, then writes the replies without blocking. Any error means the
+// connection must be closed.
+func (c *client) handleEvent(op io_multiplexing.Operation, multiplexer io_multiplexing.IOMultiplexer,
+	execute executeFunc) error {
+	var readErr error
+	if op&io_multiplexing.OpRead != 0 {
+		readErr = c.readInput(multiplexer.Mode() == io_multiplexing.EdgeTriggered)
+		// commands received before EOF are still executed and answered
+		if err := c.processInput(execute); err != nil {
+			return err
+		}
+	}
+	if err := c.flushOutput(); err != nil {
+		return err
+	}
+	if readErr != nil {
+		return readErr
+	}
+	return c.updateWriteInterest(multiplexer)
+}
+
+// close removes the fd from the monitoring list and closes it
+func (c *client) close(multiplexer io_multiplexing.IOMultiplexer) {
+	_ = multiplexer.Unmonitor(c.fd)
+	_ = syscall.Close(c.fd)
+}
+
+// addReply queues a reply in the output buffer, it is sent by the next flushOutput
+func (c *client) addReply(res []byte) {
+	c.outBuf = append(c.outBuf, res...)
+}
+
+// flushOutput writes as much of the output buffer as the socket accepts without blocking.
+// The fd must be in non-blocking mode.
+func (c *client) flushOutput() error {
+	for c.sent < len(c.outBuf) {
+		n, err := syscall.Write(c.fd, c.outBuf[c.sent:])
+		if err == syscall.EAGAIN {
+			// socket send buffer is full, the rest is written when the fd becomes writable
+			break
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.sent += n
+	}
+	if c.sent == len(c.outBuf) {
+		c.sent = 0
+		// release the memory of a large reply once it has been sent
+		if cap(c.outBuf) > constant.IOBufferSize {
+			c.outBuf = nil
+		} else {
+			c.outBuf = c.outBuf[:0]
+		}
+	}
+	return nil
+}
+
+// hasPendingOutput reports whether some replies are still waiting in the output buffer
+func (c *client) hasPendingOutput() bool {
+	return c.sent < len(c.outBuf)
+}
+
+// updateWriteInterest monitors the fd for writability while the output buffer is not empty,
+// and goes back to read-only monitoring once it has been drained.
+func (c *client) updateWriteInterest(multiplexer io_multiplexing.IOMultiplexer) error {
+	pending := c.hasPendingOutput()
+	if pending == c.waitingWritable {
+		return nil
+	}
+	var op io_multiplexing.Operation = io_multiplexing.OpRead
+	if pending {
+		op |= io_multiplexing.OpWrite
+	}
+	if err := multiplexer.Modify(io_multiplexing.Event{Fd: c.fd, Op: op}); err != nil {
+		return err
+	}
+	c.waitingWritable = pending
+	return nil
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"Nietzsche/internal/core"
+	"Nietzsche/internal/core/io_multiplexing"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client on one end of a non-blocking socket pair with a small send
+// buffer, and the fd of the peer
+func newTestClient(t *testing.T) (*client, int) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, syscall.SetNonblock(fds[0], true))
+	assert.Nil(t, syscall.SetNonblock(fds[1], true))
+	assert.Nil(t, syscall.SetsockoptInt(fds[0], syscall.SOL_SOCKET, syscall.SO_SNDBUF, 4096))
+	t.Cleanup(func() {
+		syscall.Close(fds[0])
+		syscall.Close(fds[1])
+	})
+	return newClient(fds[0]), fds[1]
+}
+
+// readAvailable reads from the fd until EAGAIN
+func readAvailable(fd int) []byte {
+	var res []byte
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err != nil || n == 0 {
+			return res
+		}
+		res = append(res, buf[:n]...)
+	}
+}
+
+// modifyRecorder records the events passed to Modify
+type modifyRecorder struct {
+	io_multiplexing.IOMultiplexer
+	modified []io_multiplexing.Event
+}
+
+func (m *modifyRecorder) Modify(event io_multiplexing.Event) error {
+	m.modified = append(m.modified, event)
+	return m.IOMultiplexer.Modify(event)
+}
+
+func TestClient_FlushOutputPartialWrite(t *testing.T) {
+	c, peer := newTestClient(t)
+	reply := bytes.Repeat([]byte("0123456789"), 100*1024)
+	c.addReply(reply)
+
+	// the socket accepts only a part of the reply, the rest waits for the peer
+	assert.Nil(t, c.flushOutput())
+	assert.True(t, c.hasPendingOutput())
+	pending := len(c.outBuf) - c.sent
+	assert.Less(t, pending, len(reply))
+	// EAGAIN is not an error
+	assert.Nil(t, c.flushOutput())
+	assert.EqualValues(t, pending, len(c.outBuf)-c.sent)
+
+	var received []byte
+	for i := 0; c.hasPendingOutput() && i < 10000; i++ {
+		received = append(received, readAvailable(peer)...)
+		assert.Nil(t, c.flushOutput())
+	}
+	received = append(received, readAvailable(peer)...)
+	assert.False(t, c.hasPendingOutput())
+	assert.Equal(t, reply, received)
+	assert.Nil(t, c.outBuf, "the memory of a large reply is released")
+
+	c.addReply([]byte("+OK\r\n"))
+	assert.Nil(t, c.flushOutput())
+	assert.EqualValues(t, "+OK\r\n", readAvailable(peer))
+	assert.NotNil(t, c.outBuf, "a small buffer is kept")
+}
+
+func TestClient_FlushOutputClosedPeer(t *testing.T) {
+	c, peer := newTestClient(t)
+	syscall.Close(peer)
+	c.addReply([]byte("+OK\r\n"))
+	assert.NotNil(t, c.flushOutput())
+}
+
+func TestClient_UpdateWriteInterest(t *testing.T) {
+	c, peer := newTestClient(t)
+	ep, err := io_multiplexing.CreateIOMultiplexer()
+	assert.Nil(t, err)
+	t.Cleanup(func() { ep.Close() })
+	multiplexer := &modifyRecorder{IOMultiplexer: ep}
+	assert.Nil(t, multiplexer.Monitor(io_multiplexing.Event{Fd: c.fd, Op: io_multiplexing.OpRead}))
+
+	// nothing to write, the fd stays monitored for reading only
+	assert.Nil(t, c.updateWriteInterest(multiplexer))
+	assert.Empty(t, multiplexer.modified)
+
+	c.addReply(bytes.Repeat([]byte("x"), 1024*1024))
+	assert.Nil(t, c.flushOutput())
+	assert.Nil(t, c.updateWriteInterest(multiplexer))
+	assert.EqualValues(t, []io_multiplexing.Event{{Fd: c.fd, Op: io_multiplexing.OpRead | io_multiplexing.OpWrite}},
+		multiplexer.modified)
+	assert.True(t, c.waitingWritable)
+	// no change while the output is pending
+	assert.Nil(t, c.updateWriteInterest(multiplexer))
+	assert.Len(t, multiplexer.modified, 1)
+
+	// the fd is reported writable once the peer reads
+	readAvailable(peer)
+	events, err := multiplexer.WaitTimeout(time.Second)
+	assert.Nil(t, err)
+	assert.EqualValues(t, []io_multiplexing.Event{{Fd: c.fd, Op: io_multiplexing.OpWrite}}, events)
+
+	for i := 0; c.hasPendingOutput() && i < 10000; i++ {
+		readAvailable(peer)
+		assert.Nil(t, c.flushOutput())
+	}
+	assert.Nil(t, c.updateWriteInterest(multiplexer))
+	assert.EqualValues(t, io_multiplexing.Event{Fd: c.fd, Op: io_multiplexing.OpRead}, multiplexer.modified[1])
+	assert.False(t, c.waitingWritable)
+	events, err = multiplexer.WaitTimeout(50 * time.Millisecond)
+	assert.Nil(t, err)
+	assert.Empty(t, events, "a writable fd is not reported anymore")
+}
+
+func TestClient_InputAfterShutdownIsIgnored(t *testing.T) {
+	c, _ := newTestClient(t)
+	var executed []string
+	execute := func(c *client, cmd *core.Command) []byte {
+		executed = append(executed, cmd.Cmd)
+		if cmd.Cmd == "SHUTDOWN" {
+			return nil
+		}
+		return []byte("+OK\r\n")
+	}
+	input := append(core.Encode([]string{"SET", "a", "1"}, false), core.Encode([]string{"SHUTDOWN"}, false)...)
+	assert.Nil(t, c.reader.Feed(append(input, core.Encode([]string{"SET", "b", "2"}, false)...)))
+	assert.Nil(t, c.processInput(execute))
+	assert.EqualValues(t, []string{"SET", "SHUTDOWN"}, executed)
+	assert.EqualValues(t, "+OK\r\n", string(c.outBuf))
+
+	assert.Nil(t, c.reader.Feed(core.Encode([]string{"SET", "c", "3"}, false)))
+	assert.Nil(t, c.processInput(execute))
+	assert.Len(t, executed, 2)
+}
@@ -0,0 +1,592 @@
+package server
+
+import (
+	"Nietzsche/internal/constant"
+	"Nietzsche/internal/core"
+	"Nietzsche/internal/data_structure"
+	"Nietzsche/internal/glob"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The coordinator runs a command on the workers owning its keys and merges their replies.
+// Like a Redis cluster proxy, it routes a command with the request_policy tip of its spec:
+//   - all_shards: the command is broadcast to every worker, e.g. DBSIZE, INFO, FLUSHALL
+//   - multi_shard: the keys are split by partition and every worker runs the command
+//     on its own keys, e.g. DEL k1 k2
+//   - no policy: the command runs on the worker owning all its keys, or any worker if it has no key.
+//
+// The replies are merged with the response_policy tip: agg_sum sums the integer replies,
+// all_succeeded returns the first error if any, and without policy the array replies are
+// concatenated (broadcast) or reordered to match the order of the keys (multi_shard).
+//
+// The commands that must be atomic across workers, like MSET or CONFIG SET, put the workers
+// involved on hold and access their storages directly, see hold.
+
+var errCrossPartition = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+
+// subCommand is the part of a command run by one worker
+type subCommand struct {
+	workerID int
+	cmd      *core.Command
+	keyPos   []int // position in the original command of each key of this part
+}
+
+// crossPartitionHandlers compute the commands that can not be split by partition from the
+// values of their keys, when these keys are owned by several workers
+var crossPartitionHandlers = map[string]func(s *Server, cmd *core.Command, keys []string) []byte{
+	"SINTER": (*Server).executeSetAlgebra,
+	"SUNION": (*Server).executeSetAlgebra,
+	"SDIFF":  (*Server).executeSetAlgebra,
+	"LCS":    (*Server).executeOnValues,
+}
+
+// atomicCommands are multi_shard commands whose parts run while all their workers are on hold,
+// see executeAtomic
+var atomicCommands = map[string]bool{
+	"MGET": true,
+	"MSET": true,
+}
+
+// coordinatorCommands are answered by the coordinator itself, without involving a worker
+var coordinatorCommands = map[string]func(s *Server, cmd *core.Command) []byte{
+	"KEYPARTITION": (*Server).cmdKEYPARTITION,
+	"KEYS":         (*Server).executeKEYS,
+	"MSETNX":       (*Server).executeMSETNX,
+	"SCAN":         (*Server).executeSCAN,
+	// CONFIG SET changes variables read by all the workers
+	"CONFIG": (*Server).executeExclusive,
+}
+
+// execute runs a command on the workers owning its keys and returns the merged reply
+func (s *Server) execute(cmd *core.Command) []byte {
+	spec := core.LookupCommand(cmd.Cmd)
+	if spec == nil || !spec.CheckArity(cmd.Args) {
+		// any worker replies with the error
+		return s.executeOn(rand.Intn(s.numWorkers), cmd)
+	}
+	if handler, ok := coordinatorCommands[cmd.Cmd]; ok {
+		return handler(s, cmd)
+	}
+	switch spec.Tip("request_policy") {
+	case "all_shards":
+		return s.broadcast(spec, cmd)
+	case "multi_shard":
+		return s.executeMultiShard(spec, cmd)
+	}
+
+	keyIndexes := spec.KeyIndexes(cmd.Args)
+	if len(keyIndexes) == 0 {
+		// Commands like PING etc., don't have a key.
+		// We can send them to any worker.
+		return s.executeOn(rand.Intn(s.numWorkers), cmd)
+	}
+	workerID := s.getPartitionID(cmd.Args[keyIndexes[0]])
+	for _, i := range keyIndexes[1:] {
+		if s.getPartitionID(cmd.Args[i]) != workerID {
+			handler, ok := crossPartitionHandlers[cmd.Cmd]
+			if !ok {
+				return core.Encode(errCrossPartition, false)
+			}
+			keys := make([]string, len(keyIndexes))
+			for j, idx := range keyIndexes {
+				keys[j] = cmd.Args[idx]
+			}
+			return handler(s, cmd, keys)
+		}
+	}
+	return s.executeOn(workerID, cmd)
+}
+
+// executeBlocking runs a blocking command, like BLPOP, on the worker owning its keys, which must
+// all be owned by the same worker. The reply is nil if the client is blocked in this worker: bc
+// then receives the reply from the worker, once the command is served or times out.
+func (s *Server) executeBlocking(cmd *core.Command, bc *core.BlockedClient) (workerID int, res []byte) {
+	keyIndexes := core.LookupCommand(cmd.Cmd).KeyIndexes(cmd.Args)
+	if len(keyIndexes) == 0 {
+		// e.g. XREAD without STREAMS, let a worker report the syntax error
+		workerID = rand.Intn(s.numWorkers)
+		return workerID, s.executeOn(workerID, cmd)
+	}
+	workerID = s.getPartitionID(cmd.Args[keyIndexes[0]])
+	for _, i := range keyIndexes[1:] {
+		if s.getPartitionID(cmd.Args[i]) != workerID {
+			return workerID, core.Encode(errCrossPartition, false)
+		}
+	}
+	replyCh := make(chan []byte, 1)
+	s.workers[workerID].TaskCh <- &core.Task{Command: cmd, ReplyCh: replyCh, Blocked: bc}
+	return workerID, <-replyCh
+}
+
+// unblock removes a client blocked in a worker, when it disconnects
+func (s *Server) unblock(workerID int, bc *core.BlockedClient) {
+	s.workers[workerID].TaskCh <- &core.Task{Fn: func(storage *core.Storage) {
+		storage.UnblockClient(bc)
+	}}
+}
+
+// executeOn runs the command on one worker and waits for the reply
+func (s *Server) executeOn(workerID int, cmd *core.Command) []byte {
+	return s.scatter([]*subCommand{{workerID: workerID, cmd: cmd}})[0]
+}
+
+// hold puts the workers on hold and returns their storages, which can then be accessed by the
+// caller until release is called. The workers are held one by one in increasing order, like
+// locks, so that two concurrent holds never wait for each other's workers.
+func (s *Server) hold(workerIDs []int) (map[int]*core.Storage, func()) {
+	ids := slices.Clone(workerIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	storages := make(map[int]*core.Storage, len(ids))
+	releases := make([]chan struct{}, 0, len(ids))
+	release := func() {
+		for _, ch := range releases {
+			close(ch)
+		}
+	}
+	for _, id := range ids {
+		held := make(chan *core.Storage)
+		releaseCh := make(chan struct{})
+		s.workers[id].TaskCh <- &core.Task{Fn: func(storage *core.Storage) {
+			held <- storage
+			<-releaseCh
+		}}
+		storages[id] = <-held
+		releases = append(releases, releaseCh)
+	}
+	return storages, release
+}
+
+// executeExclusive runs the command while all the workers are on hold,
+// for the commands changing the state shared by the workers
+func (s *Server) executeExclusive(cmd *core.Command) []byte {
+	ids := make([]int, s.numWorkers)
+	for i := range ids {
+		ids[i] = i
+	}
+	storages, release := s.hold(ids)
+	defer release()
+	return storages[0].Execute(cmd)
+}
+
+// executeAtomic runs the parts of a multi-key command while all their workers are on hold,
+// so that no other command sees the keys of some parts updated and the others not
+func (s *Server) executeAtomic(parts []*subCommand) [][]byte {
+	storages, release := s.hold(workerIDs(parts))
+	defer release()
+	replies := make([][]byte, len(parts))
+	for i, part := range parts {
+		replies[i] = storages[part.workerID].Execute(part.cmd)
+	}
+	return replies
+}
+
+func workerIDs(parts []*subCommand) []int {
+	ids := make([]int, len(parts))
+	for i, part := range parts {
+		ids[i] = part.workerID
+	}
+	return ids
+}
+
+// scatter sends every part to its worker, so that they run in parallel,
+// then waits for all the replies. The replies are in the order of parts.
+func (s *Server) scatter(parts []*subCommand) [][]byte {
+	replyChs := make([]chan []byte, len(parts))
+	for i, part := range parts {
+		replyChs[i] = make(chan []byte, 1)
+		s.workers[part.workerID].TaskCh <- &core.Task{
+			Command: part.cmd,
+			ReplyCh: replyChs[i],
+		}
+	}
+	replies := make([][]byte, len(parts))
+	for i := range parts {
+		replies[i] = <-replyChs[i]
+	}
+	return replies
+}
+
+// broadcast runs the command on every worker and merges the replies
+func (s *Server) broadcast(spec *core.CommandSpec, cmd *core.Command) []byte {
+	parts := make([]*subCommand, s.numWorkers)
+	for i := range parts {
+		parts[i] = &subCommand{workerID: i, cmd: cmd}
+	}
+	replies := s.scatter(parts)
+	switch spec.Tip("response_policy") {
+	case "agg_sum":
+		return sumReplies(replies)
+	case "all_succeeded":
+		return firstErrorOr(replies, replies[0])
+	case "special":
+		if cmd.Cmd == "INFO" {
+			return mergeInfo(replies, []string{
+				fmt.Sprintf("workers:%d", s.numWorkers),
+				fmt.Sprintf("io_handlers:%d", s.numIOHandlers),
+			})
+		}
+		if cmd.Cmd == "RANDOMKEY" {
+			return randomNonNil(replies)
+		}
+		return replies[0]
+	}
+	return concatArrays(replies)
+}
+
+// splitByPartition groups the keys of a multi-key command by the worker owning them.
+// Each key is sent with the spec.Step-1 arguments following it (e.g. the value of MSET),
+// and the arguments before the first key or after the last one are sent to every worker.
+func (s *Server) splitByPartition(spec *core.CommandSpec, cmd *core.Command) []*subCommand {
+	keyIndexes := spec.KeyIndexes(cmd.Args)
+	step := spec.Step
+	prefix := cmd.Args[:keyIndexes[0]]
+	suffix := cmd.Args[keyIndexes[len(keyIndexes)-1]+step:]
+
+	partByWorker := make(map[int]*subCommand)
+	var parts []*subCommand
+	for pos, idx := range keyIndexes {
+		workerID := s.getPartitionID(cmd.Args[idx])
+		part, ok := partByWorker[workerID]
+		if !ok {
+			args := make([]string, len(prefix), len(prefix)+len(cmd.Args))
+			copy(args, prefix)
+			part = &subCommand{workerID: workerID, cmd: &core.Command{Cmd: cmd.Cmd, Args: args}}
+			partByWorker[workerID] = part
+			parts = append(parts, part)
+		}
+		part.cmd.Args = append(part.cmd.Args, cmd.Args[idx:idx+step]...)
+		part.keyPos = append(part.keyPos, pos)
+	}
+	for _, part := range parts {
+		part.cmd.Args = append(part.cmd.Args, suffix...)
+	}
+	return parts
+}
+
+// executeMultiShard runs a multi-key command on the workers owning the keys and merges the replies
+func (s *Server) executeMultiShard(spec *core.CommandSpec, cmd *core.Command) []byte {
+	keyIndexes := spec.KeyIndexes(cmd.Args)
+	if len(keyIndexes) == 0 || (len(cmd.Args)-keyIndexes[0])%spec.Step != 0 {
+		// let a worker report the syntax error
+		return s.executeOn(rand.Intn(s.numWorkers), cmd)
+	}
+	parts := s.splitByPartition(spec, cmd)
+	if len(parts) == 1 {
+		return s.executeOn(parts[0].workerID, cmd)
+	}
+	var replies [][]byte
+	if atomicCommands[cmd.Cmd] {
+		replies = s.executeAtomic(parts)
+	} else {
+		replies = s.scatter(parts)
+	}
+	switch spec.Tip("response_policy") {
+	case "agg_sum":
+		return sumReplies(replies)
+	case "all_succeeded":
+		return firstErrorOr(replies, replies[0])
+	}
+	return reorderArrays(parts, replies, len(keyIndexes))
+}
+
+// executeMSETNX sets the keys of MSETNX only if none of them exists on any worker
+func (s *Server) executeMSETNX(cmd *core.Command) []byte {
+	if len(cmd.Args)%2 != 0 {
+		// let a worker report the syntax error
+		return s.executeOn(rand.Intn(s.numWorkers), cmd)
+	}
+	parts := s.splitByPartition(core.LookupCommand(cmd.Cmd), cmd)
+	if len(parts) == 1 {
+		return s.executeOn(parts[0].workerID, cmd)
+	}
+	storages, release := s.hold(workerIDs(parts))
+	defer release()
+	for _, part := range parts {
+		keys := make([]string, 0, len(part.cmd.Args)/2)
+		for i := 0; i < len(part.cmd.Args); i += 2 {
+			keys = append(keys, part.cmd.Args[i])
+		}
+		if storages[part.workerID].AnyKeyExists(keys) {
+			return core.Encode(0, false)
+		}
+	}
+	for _, part := range parts {
+		storages[part.workerID].Execute(part.cmd)
+	}
+	return core.Encode(1, false)
+}
+
+// executeKEYS runs KEYS on every worker, or only on the worker owning the matching keys when
+// the literal prefix of the pattern holds their hash tag, e.g. "user:{42}:*"
+func (s *Server) executeKEYS(cmd *core.Command) []byte {
+	prefix, _ := glob.LiteralPrefix(cmd.Args[0])
+	if hashTag(prefix) != prefix {
+		return s.executeOn(s.getPartitionID(prefix), cmd)
+	}
+	return s.broadcast(core.LookupCommand(cmd.Cmd), cmd)
+}
+
+// executeSCAN scans the keyspaces of the workers one after the other. The cursor of the client
+// combines the id of the worker being scanned and the cursor in its keyspace:
+// cursor = workerCursor*numWorkers + workerID, so that it is 0 only before the first call
+// and after the last one.
+func (s *Server) executeSCAN(cmd *core.Command) []byte {
+	cursor, err := strconv.ParseUint(cmd.Args[0], 10, 64)
+	if err != nil {
+		// let a worker report the invalid cursor
+		return s.executeOn(rand.Intn(s.numWorkers), cmd)
+	}
+	n := uint64(s.numWorkers)
+	workerID, workerCursor := int(cursor%n), cursor/n
+
+	args := slices.Clone(cmd.Args)
+	args[0] = strconv.FormatUint(workerCursor, 10)
+	reply := s.executeOn(workerID, &core.Command{Cmd: cmd.Cmd, Args: args})
+	elems, err := core.SplitArray(reply)
+	if err != nil || len(elems) != 2 {
+		return reply
+	}
+	next, _ := core.Decode(elems[0])
+	workerCursor, _ = strconv.ParseUint(next.(string), 10, 64)
+	if workerCursor == 0 {
+		// this worker is done, the next call starts the scan of the next worker
+		workerID++
+		if workerID == s.numWorkers {
+			return core.EncodeRawArray([][]byte{core.Encode("0", false), elems[1]})
+		}
+	}
+	cursor = workerCursor*n + uint64(workerID)
+	return core.EncodeRawArray([][]byte{core.Encode(strconv.FormatUint(cursor, 10), false), elems[1]})
+}
+
+// executeSetAlgebra computes SINTER, SUNION and SDIFF from the members of every set
+func (s *Server) executeSetAlgebra(cmd *core.Command, keys []string) []byte {
+	parts := make([]*subCommand, len(keys))
+	for i, key := range keys {
+		parts[i] = &subCommand{
+			workerID: s.getPartitionID(key),
+			cmd:      &core.Command{Cmd: "SMEMBERS", Args: []string{key}},
+		}
+	}
+	replies := s.scatter(parts)
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	sets := make([]*data_structure.SimpleSet, len(replies))
+	for i, reply := range replies {
+		sets[i] = data_structure.NewSimpleSet(keys[i])
+		members, _ := core.Decode(reply)
+		for _, m := range members.([]interface{}) {
+			sets[i].Add(m.(string))
+		}
+	}
+	var res *data_structure.SimpleSet
+	switch cmd.Cmd {
+	case "SINTER":
+		res = data_structure.Intersection(sets...)
+	case "SUNION":
+		res = data_structure.Union(sets...)
+	default:
+		res = data_structure.Difference(sets[0], sets[1:]...)
+	}
+	return core.Encode(res.Members(), false)
+}
+
+// executeOnValues runs a read-only command on string keys, like LCS, on a temporary keyspace
+// holding a copy of the values of its keys
+func (s *Server) executeOnValues(cmd *core.Command, keys []string) []byte {
+	parts := make([]*subCommand, len(keys))
+	for i, key := range keys {
+		parts[i] = &subCommand{
+			workerID: s.getPartitionID(key),
+			cmd:      &core.Command{Cmd: "GET", Args: []string{key}},
+		}
+	}
+	replies := s.scatter(parts)
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	storage := core.NewStorage()
+	for i, reply := range replies {
+		if value, _ := core.Decode(reply); value != nil {
+			storage.Execute(&core.Command{Cmd: "SET", Args: []string{keys[i], value.(string)}})
+		}
+	}
+	return storage.Execute(cmd)
+}
+
+// cmdKEYPARTITION returns the id of the worker owning the key
+func (s *Server) cmdKEYPARTITION(cmd *core.Command) []byte {
+	return core.Encode(s.getPartitionID(cmd.Args[0]), false)
+}
+
+func isErrorReply(reply []byte) bool {
+	return len(reply) > 0 && reply[0] == '-'
+}
+
+// firstError returns the first error reply, or nil if there is none
+func firstError(replies [][]byte) []byte {
+	for _, reply := range replies {
+		if isErrorReply(reply) {
+			return reply
+		}
+	}
+	return nil
+}
+
+func firstErrorOr(replies [][]byte, res []byte) []byte {
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	return res
+}
+
+// sumReplies sums integer replies
+func sumReplies(replies [][]byte) []byte {
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	var sum int64
+	for _, reply := range replies {
+		n, _ := core.Decode(reply)
+		if v, ok := n.(int64); ok {
+			sum += v
+		}
+	}
+	return core.Encode(sum, false)
+}
+
+// randomNonNil returns one of the replies that are not nil, picked at random,
+// so that RANDOMKEY returns nil only if every worker has an empty keyspace
+func randomNonNil(replies [][]byte) []byte {
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	var candidates [][]byte
+	for _, reply := range replies {
+		if !bytes.Equal(reply, constant.RespNil) {
+			candidates = append(candidates, reply)
+		}
+	}
+	if len(candidates) == 0 {
+		return constant.RespNil
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// concatArrays concatenates array replies
+func concatArrays(replies [][]byte) []byte {
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	var items [][]byte
+	for _, reply := range replies {
+		elems, err := core.SplitArray(reply)
+		if err != nil {
+			return reply
+		}
+		items = append(items, elems...)
+	}
+	return core.EncodeRawArray(items)
+}
+
+// reorderArrays merges the array replies of the parts of a multi-key command, with one
+// element per key, into a single array in the order of the keys of the original command
+func reorderArrays(parts []*subCommand, replies [][]byte, numKeys int) []byte {
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	items := make([][]byte, numKeys)
+	for i, reply := range replies {
+		elems, err := core.SplitArray(reply)
+		if err != nil || len(elems) != len(parts[i].keyPos) {
+			return reply
+		}
+		for j, pos := range parts[i].keyPos {
+			items[pos] = elems[j]
+		}
+	}
+	return core.EncodeRawArray(items)
+}
+
+// mergeInfo merges the INFO replies of the workers: the counters of the keyspace lines
+// ("db0:keys=1,expires=0,avg_ttl=0") are summed, the other lines are taken from the first worker.
+// The serverLines known by the coordinator only are added after the io_mode line.
+func mergeInfo(replies [][]byte, serverLines []string) []byte {
+	if err := firstError(replies); err != nil {
+		return err
+	}
+	var lines []string
+	dbFields := make(map[string][][2]string) // db name -> merged fields, in order
+	for i, reply := range replies {
+		text, _ := core.Decode(reply)
+		str, _ := text.(string)
+		scanner := bufio.NewScanner(strings.NewReader(str))
+		for scanner.Scan() {
+			line := scanner.Text()
+			name, fields, isDb := parseKeyspaceLine(line)
+			if !isDb {
+				if i == 0 {
+					lines = append(lines, line)
+					if strings.HasPrefix(line, "io_mode:") {
+						lines = append(lines, serverLines...)
+					}
+				}
+				continue
+			}
+			merged, seen := dbFields[name]
+			if !seen {
+				// placeholder replaced by the merged fields
+				lines = append(lines, name+":")
+				dbFields[name] = fields
+				continue
+			}
+			for j := range merged {
+				if j < len(fields) && (merged[j][0] == "keys" || merged[j][0] == "expires") {
+					a, _ := strconv.ParseInt(merged[j][1], 10, 64)
+					b, _ := strconv.ParseInt(fields[j][1], 10, 64)
+					merged[j][1] = strconv.FormatInt(a+b, 10)
+				}
+			}
+		}
+	}
+	var buf strings.Builder
+	for _, line := range lines {
+		if name, ok := strings.CutSuffix(line, ":"); ok && dbFields[name] != nil {
+			fields := make([]string, len(dbFields[name]))
+			for i, f := range dbFields[name] {
+				fields[i] = f[0] + "=" + f[1]
+			}
+			line = name + ":" + strings.Join(fields, ",")
+		}
+		buf.WriteString(line)
+		buf.WriteString(core.CRLF)
+	}
+	return core.Encode(buf.String(), false)
+}
+
+// parseKeyspaceLine parses a line like "db0:keys=1,expires=0,avg_ttl=0"
+func parseKeyspaceLine(line string) (string, [][2]string, bool) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || !strings.HasPrefix(name, "db") {
+		return "", nil, false
+	}
+	var fields [][2]string
+	for _, field := range strings.Split(rest, ",") {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return "", nil, false
+		}
+		fields = append(fields, [2]string{k, v})
+	}
+	return name, fields, true
+}
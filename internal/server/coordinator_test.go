@@ -0,0 +1,325 @@
+package server
+
+import (
+	"Nietzsche/internal/core"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestServer(numWorkers int) *Server {
+	s := &Server{
+		workers:    make([]*core.Worker, numWorkers),
+		numWorkers: numWorkers,
+	}
+	for i := range s.workers {
+		s.workers[i] = core.NewWorker(i, 16)
+	}
+	return s
+}
+
+func (s *Server) run(cmd string, args ...string) interface{} {
+	res, _ := core.Decode(s.execute(&core.Command{Cmd: cmd, Args: args}))
+	return res
+}
+
+// keysOnDistinctWorkers returns n keys owned by n different workers
+func (s *Server) keysOnDistinctWorkers(n int) []string {
+	var keys []string
+	used := make(map[int]bool)
+	for i := 0; len(keys) < n; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if id := s.getPartitionID(key); !used[id] {
+			used[id] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestCoordinator_MultiShardAggSum(t *testing.T) {
+	s := newTestServer(3)
+	keys := s.keysOnDistinctWorkers(3)
+	for _, key := range keys {
+		assert.EqualValues(t, "OK", s.run("SET", key, "v"))
+	}
+	assert.EqualValues(t, 3, s.run("DBSIZE"))
+	assert.EqualValues(t, 2, s.run("DEL", keys[0], "missing", keys[2]))
+	assert.EqualValues(t, 1, s.run("DBSIZE"))
+	assert.Nil(t, s.run("GET", keys[0]))
+	assert.EqualValues(t, "v", s.run("GET", keys[1]))
+}
+
+func TestCoordinator_Broadcast(t *testing.T) {
+	s := newTestServer(3)
+	for _, key := range s.keysOnDistinctWorkers(3) {
+		s.run("SET", key, "v")
+	}
+	info := s.run("INFO")
+	assert.Contains(t, info, "db0:keys=3,expires=0,avg_ttl=0\r\n")
+	assert.Contains(t, info, "io_mode:single\r\nworkers:3\r\nio_handlers:0\r\n")
+	assert.EqualValues(t, "PONG", s.run("PING"))
+	assert.EqualValues(t, "OK", s.run("FLUSHALL"))
+	assert.EqualValues(t, 0, s.run("DBSIZE"))
+}
+
+func TestCoordinator_CrossPartitionSetAlgebra(t *testing.T) {
+	s := newTestServer(3)
+	keys := s.keysOnDistinctWorkers(2)
+	s.run("SADD", keys[0], "a", "b", "c")
+	s.run("SADD", keys[1], "b", "c", "d")
+
+	members := func(res interface{}) []string {
+		var m []string
+		for _, v := range res.([]interface{}) {
+			m = append(m, v.(string))
+		}
+		sort.Strings(m)
+		return m
+	}
+	assert.EqualValues(t, []string{"b", "c"}, members(s.run("SINTER", keys[0], keys[1])))
+	assert.EqualValues(t, []string{"a", "b", "c", "d"}, members(s.run("SUNION", keys[0], keys[1])))
+	assert.EqualValues(t, []string{"a"}, members(s.run("SDIFF", keys[0], keys[1])))
+}
+
+func TestCoordinator_SplitByPartition(t *testing.T) {
+	s := newTestServer(3)
+	keys := s.keysOnDistinctWorkers(2)
+	spec := &core.CommandSpec{Name: "mset", Arity: -3, FirstKey: 1, LastKey: -1, Step: 2}
+	cmd := &core.Command{Cmd: "MSET", Args: []string{keys[0], "1", keys[1], "2", keys[0], "3"}}
+	parts := s.splitByPartition(spec, cmd)
+	assert.Len(t, parts, 2)
+	assert.EqualValues(t, []string{keys[0], "1", keys[0], "3"}, parts[0].cmd.Args)
+	assert.EqualValues(t, []int{0, 2}, parts[0].keyPos)
+	assert.EqualValues(t, []string{keys[1], "2"}, parts[1].cmd.Args)
+	assert.EqualValues(t, []int{1}, parts[1].keyPos)
+
+	// the replies are put back in the order of the keys
+	replies := [][]byte{
+		core.Encode([]string{"a", "c"}, false),
+		core.Encode([]string{"b"}, false),
+	}
+	merged, _ := core.Decode(reorderArrays(parts, replies, 3))
+	assert.EqualValues(t, []interface{}{"a", "b", "c"}, merged)
+}
+
+func TestCoordinator_ErrorReplies(t *testing.T) {
+	s := newTestServer(2)
+	assert.EqualValues(t, "ERR wrong number of arguments for 'del' command", fmt.Sprint(s.run("DEL")))
+	assert.Contains(t, fmt.Sprint(s.run("NOPE")), "unknown command")
+}
+
+func TestHashTag(t *testing.T) {
+	assert.EqualValues(t, "42", hashTag("user:{42}:profile"))
+	assert.EqualValues(t, "42", hashTag("{42}"))
+	assert.EqualValues(t, "a", hashTag("{a}{b}"))
+	assert.EqualValues(t, "{", hashTag("x{{}"))
+	assert.EqualValues(t, "nokey", hashTag("nokey"))
+	assert.EqualValues(t, "{}key", hashTag("{}key"))
+	assert.EqualValues(t, "key{", hashTag("key{"))
+	assert.EqualValues(t, "}key{", hashTag("}key{"))
+}
+
+func TestCoordinator_HashTagColocation(t *testing.T) {
+	s := newTestServer(4)
+	owner := s.getPartitionID("42")
+	for _, key := range []string{"user:{42}:profile", "user:{42}:sessions", "{42}"} {
+		assert.EqualValues(t, owner, s.getPartitionID(key))
+		assert.EqualValues(t, owner, s.run("KEYPARTITION", key))
+	}
+
+	// colocated keys are not a cross partition request
+	s.run("SADD", "{42}:a", "x", "y")
+	s.run("SADD", "{42}:b", "y")
+	assert.EqualValues(t, []interface{}{"y"}, s.run("SINTER", "{42}:a", "{42}:b"))
+	assert.EqualValues(t, "ERR wrong number of arguments for 'keypartition' command", fmt.Sprint(s.run("KEYPARTITION")))
+}
+
+func TestCoordinator_ExclusiveConfigSet(t *testing.T) {
+	s := newTestServer(4)
+	t.Cleanup(func() { s.run("CONFIG", "SET", "eviction-ratio", "0.1") })
+
+	// concurrent CONFIG SET hold all the workers in turn while other commands keep running
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			assert.EqualValues(t, "OK", s.run("CONFIG", "SET", "eviction-ratio", fmt.Sprintf("0.%d", i+1)))
+			done <- true
+		}(i)
+		go func(i int) {
+			key := fmt.Sprintf("key%d", i)
+			assert.EqualValues(t, "OK", s.run("SET", key, "v"))
+			assert.EqualValues(t, "v", s.run("GET", key))
+			done <- true
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+	assert.Len(t, s.run("CONFIG", "GET", "eviction-ratio"), 2)
+}
+
+func TestCoordinator_CrossPartitionLCS(t *testing.T) {
+	s := newTestServer(3)
+	keys := s.keysOnDistinctWorkers(2)
+	s.run("SET", keys[0], "ohmytext")
+	s.run("SET", keys[1], "mynewtext")
+	assert.EqualValues(t, "mytext", s.run("LCS", keys[0], keys[1]))
+	assert.EqualValues(t, 6, s.run("LCS", keys[0], keys[1], "LEN"))
+	assert.EqualValues(t, "", s.run("LCS", keys[0], "missing"))
+}
+
+func TestCoordinator_MultiKeyStrings(t *testing.T) {
+	s := newTestServer(3)
+	keys := s.keysOnDistinctWorkers(3)
+	assert.EqualValues(t, "OK", s.run("MSET", keys[0], "a", keys[1], "b", keys[2], "c"))
+	assert.EqualValues(t, []interface{}{"c", nil, "a", "b"}, s.run("MGET", keys[2], "missing", keys[0], keys[1]))
+
+	// MSETNX sets no key when one of them exists on any worker
+	assert.EqualValues(t, 0, s.run("MSETNX", keys[0]+"x", "1", keys[1], "2"))
+	assert.Nil(t, s.run("GET", keys[0]+"x"))
+	assert.EqualValues(t, "b", s.run("GET", keys[1]))
+	assert.EqualValues(t, 1, s.run("MSETNX", keys[0]+"x", "1", keys[1]+"x", "2", keys[2]+"x", "3"))
+	assert.EqualValues(t, []interface{}{"1", "2", "3"}, s.run("MGET", keys[0]+"x", keys[1]+"x", keys[2]+"x"))
+	assert.EqualValues(t, "ERR wrong number of arguments for 'msetnx' command", s.run("MSETNX", keys[0], "1", keys[1]))
+}
+
+func TestCoordinator_AtomicMultiKeyStrings(t *testing.T) {
+	s := newTestServer(4)
+	keys := s.keysOnDistinctWorkers(4)
+	s.run("MSET", keys[0], "0", keys[1], "0", keys[2], "0", keys[3], "0")
+
+	// the writers hold the workers in different key orders, concurrently with CONFIG SET
+	// holding all of them, and the readers never see a partial MSET
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				v := strconv.Itoa(i*100 + j)
+				k := []string{keys[i], keys[(i+1)%4], keys[(i+2)%4], keys[(i+3)%4]}
+				assert.EqualValues(t, "OK", s.run("MSET", k[0], v, k[1], v, k[2], v, k[3], v))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				values := s.run("MGET", keys[3], keys[1], keys[2], keys[0]).([]interface{})
+				for _, v := range values[1:] {
+					assert.Equal(t, values[0], v)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.EqualValues(t, "OK", s.run("CONFIG", "SET", "eviction-ratio", "0.1"))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCoordinator_RandomKey(t *testing.T) {
+	s := newTestServer(3)
+	assert.Nil(t, s.run("RANDOMKEY"))
+	key := s.keysOnDistinctWorkers(1)[0]
+	s.run("SADD", key, "a")
+	for i := 0; i < 10; i++ {
+		assert.EqualValues(t, key, s.run("RANDOMKEY"))
+	}
+	assert.EqualValues(t, 1, s.run("EXISTS", key, "missing"))
+}
+
+func TestCoordinator_Scan(t *testing.T) {
+	s := newTestServer(3)
+	var keys []string
+	for i := 0; i < 60; i++ {
+		keys = append(keys, "k"+strconv.Itoa(i))
+		s.run("SET", keys[i], "v")
+	}
+	var scanned []string
+	cursor := "0"
+	for {
+		res := s.run("SCAN", cursor, "COUNT", "5").([]interface{})
+		for _, key := range res[1].([]interface{}) {
+			scanned = append(scanned, key.(string))
+		}
+		if cursor = res[0].(string); cursor == "0" {
+			break
+		}
+	}
+	assert.ElementsMatch(t, keys, scanned)
+	assert.EqualValues(t, "ERR invalid cursor", s.run("SCAN", "x"))
+}
+
+func TestCoordinator_Keys(t *testing.T) {
+	s := newTestServer(3)
+	keys := s.keysOnDistinctWorkers(3)
+	for _, key := range keys {
+		s.run("SET", key, "v")
+	}
+	s.run("MSET", "user:{42}:name", "n", "user:{42}:mail", "m")
+	assert.ElementsMatch(t, append([]interface{}{"user:{42}:name", "user:{42}:mail"}, keys[0], keys[1], keys[2]),
+		s.run("KEYS", "*"))
+	assert.ElementsMatch(t, []interface{}{"user:{42}:name", "user:{42}:mail"}, s.run("KEYS", "user:{42}:*"))
+	assert.ElementsMatch(t, []interface{}{"user:{42}:mail"}, s.run("KEYS", "user:{42}:m*"))
+	assert.ElementsMatch(t, []interface{}{"user:{42}:name", "user:{42}:mail"}, s.run("KEYS", "user:{4*"))
+}
+
+func TestCoordinator_Blocking(t *testing.T) {
+	s := newTestServer(3)
+	keys := s.keysOnDistinctWorkers(2)
+	replies := make(chan []byte, 1)
+	bc := core.NewBlockedClient(func(res []byte) { replies <- res })
+
+	_, res := s.executeBlocking(&core.Command{Cmd: "BLPOP", Args: []string{keys[0], keys[1], "0"}}, bc)
+	assert.EqualValues(t, "-CROSSSLOT Keys in request don't hash to the same slot\r\n", res)
+
+	workerID, res := s.executeBlocking(&core.Command{Cmd: "BLPOP", Args: []string{keys[0], "0"}}, bc)
+	assert.Nil(t, res)
+	assert.EqualValues(t, s.getPartitionID(keys[0]), workerID)
+	assert.EqualValues(t, 1, s.run("RPUSH", keys[0], "v"))
+	assert.EqualValues(t, "*2\r\n$4\r\nkey0\r\n$1\r\nv\r\n", <-replies)
+
+	// the worker times the client out
+	_, res = s.executeBlocking(&core.Command{Cmd: "BLPOP", Args: []string{keys[0], "0.05"}}, bc)
+	assert.Nil(t, res)
+	select {
+	case res = <-replies:
+		assert.EqualValues(t, "*-1\r\n", res)
+	case <-time.After(time.Second):
+		assert.Fail(t, "no timeout")
+	}
+
+	// a client unblocked on disconnect is not served
+	workerID, _ = s.executeBlocking(&core.Command{Cmd: "BLPOP", Args: []string{keys[0], "0"}}, bc)
+	s.unblock(workerID, bc)
+	assert.EqualValues(t, 1, s.run("RPUSH", keys[0], "v"))
+	assert.EqualValues(t, 1, s.run("LLEN", keys[0]))
+	assert.Empty(t, replies)
+}
+
+func TestCoordinator_XREAD(t *testing.T) {
+	s := newTestServer(3)
+	keys := s.keysOnDistinctWorkers(2)
+	replies := make(chan []byte, 1)
+	bc := core.NewBlockedClient(func(res []byte) { replies <- res })
+
+	// the keys of XREAD follow STREAMS
+	_, res := s.executeBlocking(&core.Command{Cmd: "XREAD", Args: []string{"STREAMS", keys[0], keys[1], "$", "$"}}, bc)
+	assert.EqualValues(t, "-CROSSSLOT Keys in request don't hash to the same slot\r\n", res)
+	_, res = s.executeBlocking(&core.Command{Cmd: "XREAD", Args: []string{"COUNT", "1", "a"}}, bc)
+	assert.EqualValues(t, "-ERR syntax error\r\n", res)
+
+	workerID, res := s.executeBlocking(&core.Command{Cmd: "XREAD", Args: []string{"BLOCK", "0", "STREAMS", keys[1], "$"}}, bc)
+	assert.Nil(t, res)
+	assert.EqualValues(t, s.getPartitionID(keys[1]), workerID)
+	assert.EqualValues(t, "1-0", s.run("XADD", keys[1], "1-0", "f", "v"))
+	assert.EqualValues(t, "*1\r\n*2\r\n$4\r\nkey1\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n", <-replies)
+}
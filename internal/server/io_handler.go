@@ -3,6 +3,7 @@ package server
 import (
 	"Nietzsche/internal/core"
 	"Nietzsche/internal/core/io_multiplexing"
+	"errors"
 	"io"
 	"log"
 	"net"
@@ -16,19 +17,47 @@ type IOHandler struct {
 	mu            sync.Mutex
 	server        *Server
 	conns         map[int]net.Conn // map from fd -> connection
+	clients       map[int]*client  // map from fd -> client state (input buffer)
+	stopped       bool             // no connection is added once the handler is stopped
+	// The replies of the blocked clients are sent by the workers to unblocked, and a byte is
+	// written to the wake-up pipe so that the event loop resumes the clients.
+	unblocked    []unblockedReply
+	wakeR, wakeW int
+}
+
+// unblockedReply is the reply of the blocking command a client was blocked on
+type unblockedReply struct {
+	c   *client
+	res []byte
 }
 
 func NewIOHandler(id int, server *Server) (*IOHandler, error) {
-	multiplexer, err := io_multiplexing.CreateIOMultiplexer()
+	multiplexer, err := createIOMultiplexer()
 	if err != nil {
 		return nil, err
 	}
+	var pipe [2]int
+	if err := syscall.Pipe(pipe[:]); err != nil {
+		multiplexer.Close()
+		return nil, err
+	}
+	for _, fd := range pipe {
+		if err := syscall.SetNonblock(fd, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := multiplexer.Monitor(io_multiplexing.Event{Fd: pipe[0], Op: io_multiplexing.OpRead}); err != nil {
+		return nil, err
+	}
 
 	return &IOHandler{
 		id:            id,
 		ioMultiplexer: multiplexer,
 		server:        server,
 		conns:         make(map[int]net.Conn), // map from fd to corresponding connection
+		clients:       make(map[int]*client),
+		wakeR:         pipe[0],
+		wakeW:         pipe[1],
 	}, nil
 }
 
@@ -36,6 +65,9 @@ func NewIOHandler(id int, server *Server) (*IOHandler, error) {
 func (h *IOHandler) AddConn(conn net.Conn) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if h.stopped {
+		return errors.New("the I/O handler is stopped")
+	}
 	tcpConn := conn.(*net.TCPConn)
 	rawConn, err := tcpConn.SyscallConn()
 	if err != nil {
@@ -49,6 +81,7 @@ func (h *IOHandler) AddConn(conn net.Conn) error {
 		log.Printf("I/O Handler %d is monitoring fd %d", h.id, connFd)
 		// Store the connection object so it's not garbage collected
 		h.conns[connFd] = conn
+		h.clients[connFd] = newClient(connFd)
 		// Add to epoll
 		h.ioMultiplexer.Monitor(io_multiplexing.Event{
 			Fd: connFd,
@@ -61,53 +94,131 @@ func (h *IOHandler) AddConn(conn net.Conn) error {
 
 func (h *IOHandler) closeConn(fd int) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
+	c := h.clients[fd]
 	if conn, ok := h.conns[fd]; ok {
+		_ = h.ioMultiplexer.Unmonitor(fd)
 		conn.Close()
 		delete(h.conns, fd)
+		delete(h.clients, fd)
+	}
+	h.mu.Unlock()
+
+	// without holding the lock, which the worker may need to send a reply to unblocked
+	if c != nil && c.blocked {
+		h.server.unblock(c.blockedOn, c.bc)
+	}
+}
+
+// execute runs the command on the Workers owning its keys and waits for the reply. A blocking
+// command does not wait while the client is blocked: the worker sends the reply to wakeUp.
+func (h *IOHandler) execute(c *client, cmd *core.Command) []byte {
+	if !isBlocking(cmd) {
+		return h.server.execute(cmd)
+	}
+	if c.bc == nil {
+		c.bc = core.NewBlockedClient(func(res []byte) {
+			h.wakeUp(c, res)
+		})
+	}
+	workerID, res := h.server.executeBlocking(cmd, c.bc)
+	if res == nil {
+		c.blocked, c.blockedOn = true, workerID
+	}
+	return res
+}
+
+// wakeUp is called by a worker with the reply of a blocked client, it wakes the event loop up
+func (h *IOHandler) wakeUp(c *client, res []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return
+	}
+	h.unblocked = append(h.unblocked, unblockedReply{c, res})
+	// a full pipe already wakes the event loop up
+	_, _ = syscall.Write(h.wakeW, []byte{0})
+}
+
+// resumeUnblocked sends the replies of the clients unblocked by the workers, then executes
+// the commands they sent while blocked
+func (h *IOHandler) resumeUnblocked() {
+	buf := make([]byte, 64)
+	for {
+		if n, err := syscall.Read(h.wakeR, buf); n <= 0 || err != nil {
+			break
+		}
+	}
+	h.mu.Lock()
+	replies := h.unblocked
+	h.unblocked = nil
+	h.mu.Unlock()
+	for _, r := range replies {
+		h.mu.Lock()
+		current := h.clients[r.c.fd]
+		h.mu.Unlock()
+		if current != r.c {
+			// disconnected after being served
+			continue
+		}
+		if err := r.c.resume(r.res, h.ioMultiplexer, h.execute); err != nil {
+			log.Printf("Error on fd %d: %v", r.c.fd, err)
+			h.closeConn(r.c.fd)
+		}
 	}
 }
 
 func (h *IOHandler) Run() {
 	log.Printf("I/O Handler %d started", h.id)
-	for {
+	for !isShuttingDown() {
 		// wait for data from any of the fd in the monitoring list
-		events, err := h.ioMultiplexer.Wait()
+		events, err := h.ioMultiplexer.WaitTimeout(shutdownCheckInterval)
 		if err != nil {
 			continue
 		}
 
 		for _, event := range events {
 			connFd := event.Fd
+			if connFd == h.wakeR {
+				h.resumeUnblocked()
+				continue
+			}
 			h.mu.Lock()
-			conn, ok := h.conns[connFd]
+			c, ok := h.clients[connFd]
 			h.mu.Unlock()
 			if !ok {
 				// Connection might have been closed by a concurrent write error
 				continue
 			}
-			//cmd, err := readCommand(connFd)
-			cmd, err := readCommandConn(conn)
-			if err != nil {
+			// The fd is owned by the Go runtime and already non-blocking,
+			// so it is read and written directly instead of going through net.Conn,
+			// which would park this goroutine and stall every other connection of the handler
+			if err := c.handleEvent(event.Op, h.ioMultiplexer, h.execute); err != nil {
 				if err == io.EOF || err == syscall.ECONNRESET {
 					//log.Printf("Client disconnected (fd: %d)", connFd)
 				} else {
-					log.Printf("Read error on fd %d: %v", connFd, err)
+					log.Printf("Error on fd %d: %v", connFd, err)
 				}
 				h.closeConn(connFd) // <-- Use our new closing function
-				continue
-			}
-
-			replyCh := make(chan []byte, 1)
-			task := &core.Task{
-				Command: cmd,
-				ReplyCh: replyCh,
 			}
-			// dispatch the command to the corresponding Worker
-			h.server.dispatch(task)
-			res := <-replyCh
-			conn.Write(res)
 		}
 	}
+	h.stop()
+}
+
+// stop writes the pending replies, then closes the connections and the multiplexer of the handler
+func (h *IOHandler) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopped = true
+	closeClients(h.clients, h.ioMultiplexer, func(c *client) {
+		_ = h.ioMultiplexer.Unmonitor(c.fd)
+		h.conns[c.fd].Close()
+	})
+	clear(h.conns)
+	clear(h.clients)
+	_ = h.ioMultiplexer.Unmonitor(h.wakeR)
+	_ = syscall.Close(h.wakeR)
+	_ = syscall.Close(h.wakeW)
+	_ = h.ioMultiplexer.Close()
+	log.Printf("I/O Handler %d stopped", h.id)
 }
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"Nietzsche/internal/core"
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// testConn is a connection to an I/O handler
+type testConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *testConn) send(args ...string) {
+	_, err := c.conn.Write(core.Encode(args, false))
+	assert.Nil(c.t, err)
+}
+
+// expect reads a reply, which must be expected
+func (c *testConn) expect(expected string) {
+	c.t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, len(expected))
+	_, err := io.ReadFull(c.r, buf)
+	assert.Nil(c.t, err)
+	assert.EqualValues(c.t, expected, string(buf))
+}
+
+// startIOHandler runs an I/O handler of the server serving the connections of a listener
+func startIOHandler(t *testing.T, s *Server) func() *testConn {
+	h, err := NewIOHandler(0, s)
+	assert.Nil(t, err)
+	s.ioHandlers, s.numIOHandlers = []*IOHandler{h}, 1
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go s.acceptConnections(listener)
+	go h.Run()
+	return func() *testConn {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		assert.Nil(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return &testConn{t: t, conn: conn, r: bufio.NewReader(conn)}
+	}
+}
+
+func TestIOHandler_Blocking(t *testing.T) {
+	s := newTestServer(2)
+	dial := startIOHandler(t, s)
+	blocked, other := dial(), dial()
+
+	// the commands following the blocking one wait for it, while the handler serves the other clients
+	blocked.send("BLPOP", "list", "0")
+	blocked.send("PING")
+	other.send("PING")
+	other.expect("+PONG\r\n")
+	other.send("RPUSH", "list", "a", "b")
+	other.expect(":2\r\n")
+	blocked.expect("*2\r\n$4\r\nlist\r\n$1\r\na\r\n+PONG\r\n")
+	other.send("LLEN", "list")
+	other.expect(":1\r\n")
+
+	start := time.Now()
+	blocked.send("BRPOP", "missing", "0.1")
+	blocked.expect("*-1\r\n")
+	assert.WithinDuration(t, start.Add(100*time.Millisecond), time.Now(), 100*time.Millisecond)
+
+	// a disconnected client is not served
+	gone := dial()
+	gone.send("BLPOP", "later", "0")
+	gone.send("PING")
+	time.Sleep(50 * time.Millisecond)
+	gone.conn.Close()
+	time.Sleep(50 * time.Millisecond)
+	other.send("RPUSH", "later", "x")
+	other.expect(":1\r\n")
+	other.send("LLEN", "later")
+	other.expect(":1\r\n")
+}
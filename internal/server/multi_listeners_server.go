@@ -25,34 +25,19 @@ func createReusablePortListener(network, addr string) (net.Listener, error) {
 func (s *Server) StartMultiListeners(wg *sync.WaitGroup) {
 	defer wg.Done()
 	// Start all I/O handler event loops
-	for _, handler := range s.ioHandlers {
-		go handler.Run()
-	}
-
-	for i := 0; i < config.ListenerNumber; i++ {
-		go func() {
-			listener, err := createReusablePortListener(config.Protocol, config.Port)
-			log.Printf("Listener %d started listening on %s", i, config.Port)
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer listener.Close()
-			for {
-				conn, err := listener.Accept()
-				if err != nil {
-					log.Printf("Failed to acccept connection: %v", err)
-					continue
-				}
+	s.startIOHandlers()
 
-				handler := s.ioHandlers[s.nextIOHandler%s.numIOHandlers]
-				s.nextIOHandler++
-
-				if err := handler.AddConn(conn); err != nil {
-					log.Printf("Failed to add connection to I/O handler %d: %v", handler.id, err)
-					// If adding fails, close the connection properly
-					conn.Close()
-				}
-			}
-		}()
+	listeners := make([]net.Listener, config.ListenerNumber)
+	for i := range listeners {
+		listener, err := createReusablePortListener(config.Protocol, config.Address())
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Listener %d started listening on %s", i, config.Address())
+		listeners[i] = listener
+		go s.acceptConnections(listener)
 	}
+
+	<-shutdownRequested
+	s.shutdown(listeners)
 }
@@ -5,64 +5,19 @@ import (
 	"Nietzsche/internal/constant"
 	"Nietzsche/internal/core"
 	"Nietzsche/internal/core/io_multiplexing"
+	"errors"
 	"hash/fnv"
 	"io"
 	"log"
-	"math/rand"
 	"net"
-	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 )
 
-var serverStatus int32 = constant.ServerStatusIdle
-
-func readCommand(fd int) (*core.Command, error) {
-	var buf = make([]byte, 512)
-	n, err := syscall.Read(fd, buf)
-	if err != nil {
-		return nil, err
-	}
-	if n == 0 {
-		return nil, io.EOF
-	}
-	return core.ParseCmd(buf)
-}
-
-func readCommandConn(conn net.Conn) (*core.Command, error) {
-	var buf = make([]byte, 512)
-	// Use the Read method from the net.Conn interface
-	n, err := conn.Read(buf)
-	if err != nil {
-		return nil, err // This will properly handle io.EOF
-	}
-	return core.ParseCmd(buf[:n])
-}
-
-func respond(data string, fd int) error {
-	if _, err := syscall.Write(fd, []byte(data)); err != nil {
-		return err
-	}
-	return nil
-}
-
-func WaitForSignal(wg *sync.WaitGroup, signals chan os.Signal) {
-	defer wg.Done()
-	// Wait for signal in channel, it not available then wait
-	<-signals
-	// Busy loop
-	for {
-		if atomic.CompareAndSwapInt32(&serverStatus, constant.ServerStatusIdle, constant.ServerStatusShuttingDown) {
-			// The swap was successful! We have now claimed the shutdown state.
-			log.Println("Shutting down gracefully")
-			os.Exit(0)
-		}
-	}
-}
-
 type Server struct {
 	workers       []*core.Worker
 	ioHandlers    []*IOHandler
@@ -70,38 +25,43 @@ type Server struct {
 	numIOHandlers int
 
 	// For round-robin assigment of new connection to I/O handlers
-	nextIOHandler int
+	nextIOHandler atomic.Uint32
+	ioHandlersWG  sync.WaitGroup // running I/O handlers
+}
+
+// hashTag returns the part of the key used for partitioning. Like Redis Cluster, if the key
+// contains a non-empty substring between the first '{' and the next '}', only this substring
+// is hashed, so that "user:{42}:profile" and "user:{42}:sessions" are owned by the same worker.
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		// no '}' or empty tag "{}": the whole key is hashed
+		return key
+	}
+	return key[start+1 : start+1+end]
 }
 
 func (s *Server) getPartitionID(key string) int {
 	hasher := fnv.New32a()
-	hasher.Write([]byte(key))
-	return int(hasher.Sum32()) % s.numWorkers
+	hasher.Write([]byte(hashTag(key)))
+	return int(hasher.Sum32() % uint32(s.numWorkers))
 }
 
-// set abc 123
-// abc -> 1
-// get abc
-// abc -> 1
-func (s *Server) dispatch(task *core.Task) {
-	// Commands like PING etc., don't have a key.
-	// We can send them to any worker.
-	var key string
-	var workerID int
-	if len(task.Command.Args) > 0 {
-		key = task.Command.Args[0]
-		workerID = s.getPartitionID(key)
-	} else {
-		workerID = rand.Intn(s.numWorkers)
-	}
-
-	s.workers[workerID].TaskCh <- task
+// threadCount returns the configured number of threads, or half the number of CPUs if it is 0
+func threadCount(configured int) int {
+	if configured > 0 {
+		return configured
+	}
+	return max(1, runtime.NumCPU()/2)
 }
 
 func NewServer() *Server {
-	numCores := runtime.NumCPU()  // 8
-	numIOHandlers := numCores / 2 // 4
-	numWorkers := numCores / 2    // 4
+	numIOHandlers := threadCount(config.IOHandlerNumber)
+	numWorkers := threadCount(config.WorkerNumber)
 	log.Printf("Initializing server with %d workers and %d io handler\n", numWorkers, numIOHandlers)
 
 	s := &Server{
@@ -125,14 +85,66 @@ func NewServer() *Server {
 	return s
 }
 
+// startIOHandlers starts the event loops of the I/O handlers
+func (s *Server) startIOHandlers() {
+	for _, handler := range s.ioHandlers {
+		s.ioHandlersWG.Add(1)
+		go func() {
+			defer s.ioHandlersWG.Done()
+			handler.Run()
+		}()
+	}
+}
+
+// acceptConnections forwards the new connections of the listener to the I/O handlers
+// in a round-robin manner, until the listener is closed
+func (s *Server) acceptConnections(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Failed to acccept connection: %v", err)
+			continue
+		}
+
+		handler := s.ioHandlers[int(s.nextIOHandler.Add(1)-1)%s.numIOHandlers]
+		if err := handler.AddConn(conn); err != nil {
+			log.Printf("Failed to add connection to I/O handler %d: %v", handler.id, err)
+			conn.Close()
+		}
+	}
+}
+
+// shutdown closes the listeners, then waits for the I/O handlers to close their clients
+// and for the workers to run their queued tasks, see shutdown.go
+func (s *Server) shutdown(listeners []net.Listener) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
+	s.ioHandlersWG.Wait()
+	// the I/O handlers sending the tasks are stopped
+	for _, worker := range s.workers {
+		worker.Stop()
+	}
+}
+
+// createIOMultiplexer creates a multiplexer in the trigger mode set in the config
+func createIOMultiplexer() (io_multiplexing.IOMultiplexer, error) {
+	if config.EdgeTriggered {
+		return io_multiplexing.CreateIOMultiplexerWithMode(io_multiplexing.EdgeTriggered)
+	}
+	return io_multiplexing.CreateIOMultiplexer()
+}
+
 func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 	defer wg.Done()
-	log.Println("starting an I/O Multiplexing TCP server on", config.Port)
-	listener, err := net.Listen(config.Protocol, config.Port)
+	log.Println("starting an I/O Multiplexing TCP server on", config.Address())
+	listener, err := net.Listen(config.Protocol, config.Address())
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer listener.Close()
 
 	// Get the file descriptor from the listener
 	tcpListener, ok := listener.(*net.TCPListener)
@@ -143,12 +155,16 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer listenerFile.Close()
 
 	serverFd := int(listenerFile.Fd())
 
+	// accept in a loop until EAGAIN, as an edge-triggered multiplexer reports a burst of connections once
+	if err = syscall.SetNonblock(serverFd, true); err != nil {
+		log.Fatal(err)
+	}
+
 	// Create an ioMultiplexer instance (epoll in Linux, kqueue in MacOS)
-	ioMultiplexer, err := io_multiplexing.CreateIOMultiplexer()
+	ioMultiplexer, err := createIOMultiplexer()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -162,70 +178,113 @@ func RunIoMultiplexingServer(wg *sync.WaitGroup) {
 		log.Fatal(err)
 	}
 
-	var events = make([]io_multiplexing.Event, config.MaxConnection)
+	var events = make([]io_multiplexing.Event, config.MaxEventsPerLoop)
+	var clients = make(map[int]*client)
 	var lastActiveExpireExecTime = time.Now()
-	for atomic.LoadInt32(&serverStatus) != constant.ServerStatusShuttingDown {
+	// the blocked clients served or timed out, with their reply. They are resumed once the
+	// events are handled, as they are unblocked while another client's command runs.
+	type unblockedClient struct {
+		c   *client
+		res []byte
+	}
+	var unblocked []unblockedClient
+	execute := func(c *client, cmd *core.Command) []byte {
+		if !isBlocking(cmd) {
+			return core.Execute(cmd)
+		}
+		if c.bc == nil {
+			c.bc = core.NewBlockedClient(func(res []byte) {
+				unblocked = append(unblocked, unblockedClient{c, res})
+			})
+		}
+		res := core.ExecuteBlocking(cmd, c.bc)
+		c.blocked = res == nil
+		return res
+	}
+	closeClient := func(c *client) {
+		if c.blocked {
+			core.UnblockClient(c.bc)
+		}
+		delete(clients, c.fd)
+		c.close(ioMultiplexer)
+	}
+	for !isShuttingDown() {
 		// Check last execution time and call if it is more than 100ms ago.
 		if time.Now().After(lastActiveExpireExecTime.Add(constant.ActiveExpireFrequency)) {
-			if !atomic.CompareAndSwapInt32(&serverStatus, constant.ServerStatusIdle, constant.ServerStatusBusy) {
-				if serverStatus == constant.ServerStatusShuttingDown {
-					return
-				}
-			}
-			core.ActiveDeleteExpiredKeys() // Busy
-			atomic.SwapInt32(&serverStatus, constant.ServerStatusIdle)
-			// Idle
+			core.ActiveDeleteExpiredKeys()
 			lastActiveExpireExecTime = time.Now()
 		}
-		// wait for file descriptors in the monitoring list to be ready for I/O
-		// it is a blocking call.
-		// Idle
-		events, err = ioMultiplexer.Wait()
+		core.UnblockTimedOutClients()
+		for len(unblocked) > 0 {
+			u := unblocked[0]
+			unblocked = unblocked[1:]
+			if clients[u.c.fd] != u.c {
+				// disconnected after being served
+				continue
+			}
+			if err := u.c.resume(u.res, ioMultiplexer, execute); err != nil {
+				log.Println("client error:", err)
+				closeClient(u.c)
+			}
+		}
+		// wait for file descriptors in the monitoring list to be ready for I/O,
+		// at most until the next active expire cycle or the next timeout of a blocked client
+		timeout := constant.ActiveExpireFrequency
+		if deadline, ok := core.NextBlockedTimeout(); ok {
+			timeout = max(min(timeout, time.Until(deadline)), time.Millisecond)
+		}
+		events, err = ioMultiplexer.WaitTimeout(timeout)
 		if err != nil {
 			continue
 		}
-		// Goroutine #2 is gracefully shutdown
-		// means: serverStatus == ServerStatusShuttingDown
-		if !atomic.CompareAndSwapInt32(&serverStatus, constant.ServerStatusIdle, constant.ServerStatusBusy) {
-			if serverStatus == constant.ServerStatusShuttingDown {
-				return
-			}
-		}
-		// Busy
 		for i := 0; i < len(events); i++ {
 			if events[i].Fd == serverFd {
 				log.Printf("new client is trying to connect")
-				// set up new connection
-				connFd, _, err := syscall.Accept(serverFd)
-				if err != nil {
-					log.Println("err", err)
-					continue
-				}
-				log.Printf("set up a new connection")
-				// ask epoll to monitor this connection
-				if err = ioMultiplexer.Monitor(io_multiplexing.Event{
-					Fd: connFd,
-					Op: io_multiplexing.OpRead,
-				}); err != nil {
-					log.Fatal(err)
+				for {
+					// set up new connection
+					connFd, _, err := syscall.Accept(serverFd)
+					if err != nil {
+						if err != syscall.EAGAIN {
+							log.Println("err", err)
+						}
+						break
+					}
+					log.Printf("set up a new connection")
+					// replies are written without blocking, the rest is kept in the client's output buffer
+					if err = syscall.SetNonblock(connFd, true); err != nil {
+						log.Println("err", err)
+						_ = syscall.Close(connFd)
+						continue
+					}
+					// ask epoll to monitor this connection
+					if err = ioMultiplexer.Monitor(io_multiplexing.Event{
+						Fd: connFd,
+						Op: io_multiplexing.OpRead,
+					}); err != nil {
+						log.Fatal(err)
+					}
+					clients[connFd] = newClient(connFd)
 				}
 			} else {
-				cmd, err := readCommand(events[i].Fd)
-				if err != nil {
+				c, ok := clients[events[i].Fd]
+				if !ok {
+					continue
+				}
+				if err := c.handleEvent(events[i].Op, ioMultiplexer, execute); err != nil {
 					if err == io.EOF || err == syscall.ECONNRESET {
 						log.Println("client disconnected")
-						_ = syscall.Close(events[i].Fd)
-						continue
+					} else {
+						log.Println("client error:", err)
 					}
-					log.Println("read error:", err)
-					continue
-				}
-				if err = core.ExecuteAndResponse(cmd, events[i].Fd); err != nil {
-					log.Println("err write:", err)
+					closeClient(c)
 				}
 			}
 		}
-		// Idle
-		atomic.SwapInt32(&serverStatus, constant.ServerStatusIdle)
 	}
+
+	// stop accepting, then send the pending replies and close the clients
+	_ = ioMultiplexer.Unmonitor(serverFd)
+	listenerFile.Close()
+	listener.Close()
+	closeClients(clients, ioMultiplexer, func(c *client) { c.close(ioMultiplexer) })
 }
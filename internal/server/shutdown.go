@@ -0,0 +1,132 @@
+package server
+
+import (
+	"Nietzsche/internal/config"
+	"Nietzsche/internal/core"
+	"Nietzsche/internal/core/io_multiplexing"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The shutdown sequence is started by SIGINT/SIGTERM or by the SHUTDOWN command:
+//  1. the listeners are closed, no new connection is accepted
+//  2. every event loop stops reading commands, the commands already read are executed
+//  3. the pending replies are written until config.ShutdownTimeout, then the client fds are closed
+//  4. the workers run the tasks left in their queue and exit
+//  5. the final snapshot is taken by SnapshotHook, see FinishShutdown
+//
+// A watchdog exits the process if the steps 1-4 take a second longer than config.ShutdownTimeout.
+
+// SnapshotHook, if set, persists the keyspace at the end of the shutdown sequence
+var SnapshotHook func() error
+
+// shutdownCheckInterval is how often an idle event loop checks whether a shutdown is requested
+const shutdownCheckInterval = 100 * time.Millisecond
+
+var (
+	shutdownOnce      sync.Once
+	shutdownRequested = make(chan struct{}) // closed when the shutdown starts
+	shutdownMode      core.ShutdownMode
+	shutdownDeadline  time.Time
+	snapshotStarted   atomic.Bool
+)
+
+func init() {
+	core.ShutdownHandler = RequestShutdown
+}
+
+// RequestShutdown starts the shutdown sequence, the later requests are ignored
+func RequestShutdown(mode core.ShutdownMode) {
+	shutdownOnce.Do(func() {
+		log.Println("Shutting down gracefully")
+		timeout := time.Duration(config.ShutdownTimeout) * time.Second
+		shutdownMode = mode
+		shutdownDeadline = time.Now().Add(timeout)
+		close(shutdownRequested)
+
+		time.AfterFunc(timeout+time.Second, func() {
+			if !snapshotStarted.Load() {
+				log.Println("Shutdown timed out, exiting now")
+				os.Exit(1)
+			}
+		})
+	})
+}
+
+func isShuttingDown() bool {
+	select {
+	case <-shutdownRequested:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitForSignal starts the shutdown on the first signal, and exits right away on the second one
+func WaitForSignal(signals chan os.Signal) {
+	<-signals
+	RequestShutdown(core.ShutdownDefault)
+	<-signals
+	log.Println("Received a second signal, exiting now")
+	os.Exit(1)
+}
+
+// FinishShutdown takes the final snapshot, unless SHUTDOWN NOSAVE was used.
+// It is called once the server has stopped.
+func FinishShutdown() error {
+	snapshotStarted.Store(true)
+	if shutdownMode == core.ShutdownNoSave {
+		return nil
+	}
+	if SnapshotHook == nil {
+		if shutdownMode == core.ShutdownSave {
+			log.Println("No snapshot hook is set, nothing is saved")
+		}
+		return nil
+	}
+	log.Println("Taking the final snapshot")
+	return SnapshotHook()
+}
+
+// closeClients writes the pending replies of the clients until they are all sent or the shutdown
+// deadline is reached, then closes them. The input of the clients is not read anymore.
+func closeClients(clients map[int]*client, multiplexer io_multiplexing.IOMultiplexer, closeFn func(c *client)) {
+	pending := 0
+	for _, c := range clients {
+		if err := c.flushOutput(); err != nil || !c.hasPendingOutput() {
+			continue
+		}
+		if err := multiplexer.Modify(io_multiplexing.Event{Fd: c.fd, Op: io_multiplexing.OpWrite}); err != nil {
+			continue
+		}
+		c.waitingWritable = true
+		pending++
+	}
+
+	for pending > 0 && time.Now().Before(shutdownDeadline) {
+		events, err := multiplexer.WaitTimeout(time.Until(shutdownDeadline))
+		if err != nil {
+			continue
+		}
+		for _, event := range events {
+			c, ok := clients[event.Fd]
+			if !ok || !c.waitingWritable {
+				continue
+			}
+			// a hang up is reported too, then the write fails
+			if err := c.flushOutput(); err != nil || !c.hasPendingOutput() {
+				c.waitingWritable = false
+				pending--
+			}
+		}
+	}
+	if pending > 0 {
+		log.Printf("Closing %d clients with unsent replies", pending)
+	}
+	for _, c := range clients {
+		closeFn(c)
+	}
+}
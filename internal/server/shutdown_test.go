@@ -0,0 +1,65 @@
+package server
+
+import (
+	"Nietzsche/internal/core"
+	"Nietzsche/internal/core/io_multiplexing"
+	"github.com/stretchr/testify/assert"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCloseClients(t *testing.T) {
+	multiplexer, err := io_multiplexing.CreateIOMultiplexer()
+	assert.Nil(t, err)
+	defer multiplexer.Close()
+
+	// the peers of the first client read its replies, the ones of the second client never do
+	clients := make(map[int]*client)
+	var peers []int
+	for i := 0; i < 2; i++ {
+		fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+		assert.Nil(t, err)
+		assert.Nil(t, syscall.SetNonblock(fds[0], true))
+		assert.Nil(t, multiplexer.Monitor(io_multiplexing.Event{Fd: fds[0], Op: io_multiplexing.OpRead}))
+		c := newClient(fds[0])
+		c.addReply(make([]byte, 4<<20))
+		clients[fds[0]] = c
+		peers = append(peers, fds[1])
+	}
+	defer syscall.Close(peers[0])
+	defer syscall.Close(peers[1])
+
+	received := make(chan int)
+	go func() {
+		total := 0
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := syscall.Read(peers[0], buf)
+			if n <= 0 || err != nil {
+				received <- total
+				return
+			}
+			total += n
+		}
+	}()
+
+	shutdownDeadline = time.Now().Add(200 * time.Millisecond)
+	var closed []int
+	closeClients(clients, multiplexer, func(c *client) {
+		closed = append(closed, c.fd)
+		c.close(multiplexer)
+	})
+	assert.Len(t, closed, 2)
+	assert.WithinDuration(t, shutdownDeadline, time.Now(), 100*time.Millisecond)
+	// the reply of the reading client is entirely sent before its fd is closed
+	assert.EqualValues(t, 4<<20, <-received)
+}
+
+func TestServer_ShutdownRunsQueuedTasks(t *testing.T) {
+	s := newTestServer(2)
+	replyCh := make(chan []byte, 1)
+	s.workers[1].TaskCh <- &core.Task{Command: &core.Command{Cmd: "PING"}, ReplyCh: replyCh}
+	s.shutdown(nil)
+	assert.EqualValues(t, "+PONG\r\n", <-replyCh)
+}
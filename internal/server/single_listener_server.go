@@ -10,33 +10,16 @@ import (
 func (s *Server) StartSingleListener(wg *sync.WaitGroup) {
 	defer wg.Done()
 	// Start all I/O handler event loops
-	for _, handler := range s.ioHandlers {
-		go handler.Run()
-	}
+	s.startIOHandlers()
 
 	// Set up listener socket
-	listener, err := net.Listen(config.Protocol, config.Port)
+	listener, err := net.Listen(config.Protocol, config.Address())
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer listener.Close()
-
-	log.Printf("Server listening on %s", config.Port)
-
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Printf("Failed to acccept connection: %v", err)
-			continue
-		}
+	log.Printf("Server listening on %s", config.Address())
+	go s.acceptConnections(listener)
 
-		// forward the new connection to an I/O handler in a round-robin manner
-		handler := s.ioHandlers[s.nextIOHandler%s.numIOHandlers]
-		s.nextIOHandler++
-
-		if err := handler.AddConn(conn); err != nil {
-			log.Printf("Failed to add connection to I/O handler %d: %v", handler.id, err)
-			conn.Close()
-		}
-	}
+	<-shutdownRequested
+	s.shutdown([]net.Listener{listener})
 }